@@ -0,0 +1,313 @@
+// Package chassis is a snapshot of everything we know about an M1000e chassis,
+// combining what the CMC reports with what each blade's BMC reports, so that
+// every output (metrics, JSON, the CLI) renders the same model.
+package chassis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Chassis is a snapshot of a chassis, filled in by a Collector.
+type Chassis struct {
+	// CollectedAt is when collection started.
+	CollectedAt time.Time
+
+	// Name, Model and ServiceTag are from `racadm getsysinfo`.
+	Name       string
+	Model      string
+	ServiceTag string
+
+	// AmbientTemps, Fans and PSUs are from `racadm getsensorinfo`.
+	AmbientTemps []*Sensor
+	Fans         []*Sensor
+	PSUs         []*PSU
+	IOMs         []*IOM
+	CMCs         []*CMC
+	Slots        []*Slot
+
+	// Errors are the chassis-wide sources we couldn't read, which leaves the
+	// parts of the snapshot that come from them empty. Errors reading a
+	// blade are on its Slot.
+	Errors []*SourceError `json:",omitempty"`
+}
+
+// Sources of data about the chassis. The CMC's are named for the racadm
+// subcommand they come from.
+const (
+	SourceSensorInfo  = "getsensorinfo"
+	SourcePowerBudget = "getpbinfo"
+	SourceSysInfo     = "getsysinfo"
+	SourceIOInfo      = "getioinfo"
+	SourceNICConfig   = "getniccfg"
+
+	// The rest are read from a blade's BMC by a BladeReader.
+	SourcePowerState  = "power state"
+	SourceSensors     = "sensors"
+	SourceAmbientTemp = "ambient temp"
+	SourcePower       = "power reading"
+	SourceFirmware    = "firmware"
+	SourceWatchdog    = "watchdog"
+	SourceSelfTest    = "self test"
+	SourceSEL         = "SEL"
+	SourceFRU         = "FRU"
+)
+
+// SourceError is a failure to read from one source.
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	return "failed to read " + e.Source + ": " + e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SourceError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Source string
+		Error  string
+	}{e.Source, e.Err.Error()})
+}
+
+// Err returns the error reading the given chassis-wide source, or nil if
+// there wasn't one.
+func (c *Chassis) Err(source string) *SourceError {
+	return findErr(c.Errors, source)
+}
+
+// Slot returns the slot with the given number, or nil if there isn't one.
+func (c *Chassis) Slot(num int) *Slot {
+	for _, s := range c.Slots {
+		if s.Number == num {
+			return s
+		}
+	}
+	return nil
+}
+
+func (c *Chassis) addError(source string, err error) {
+	c.Errors = append(c.Errors, &SourceError{Source: source, Err: err})
+}
+
+func findErr(errs []*SourceError, source string) *SourceError {
+	for _, e := range errs {
+		if e.Source == source {
+			return e
+		}
+	}
+	return nil
+}
+
+// Sensor is one of the CMC's sensors.
+type Sensor struct {
+	Number  int
+	Name    string
+	Status  string
+	Reading float64
+	// Units are "Celsius" for temps and "rpm" for fans.
+	Units string
+}
+
+// OK reports whether the sensor's status is OK.
+func (s *Sensor) OK() bool {
+	return strings.EqualFold(s.Status, "OK")
+}
+
+type PSU struct {
+	Number int
+	Name   string
+	// Status is e.g. "Online", "Off" or "Slot Empty".
+	Status string
+	Health string
+}
+
+// Online reports whether the PSU is supplying power.
+func (p *PSU) Online() bool {
+	return p.Status == "Online"
+}
+
+// IOM is an I/O module, i.e. a switch or pass-through.
+type IOM struct {
+	// Slot is the I/O module bay, e.g. "switch-1".
+	Slot    string
+	Name    string
+	Type    string
+	Present bool
+	POST    string
+	Power   string
+	Role    string
+}
+
+// CMC is one of the chassis' management controllers.
+type CMC struct {
+	// Location is e.g. "CMC-1".
+	Location string
+	// Primary is true for the active CMC, and false for the standby.
+	Primary  bool
+	Firmware string
+}
+
+// Slot is a server slot, and what's known about the blade in it.
+type Slot struct {
+	Number int
+
+	// Name, BladeType, PowerState, PowerAllocation and Priority are the CMC's
+	// view of the blade, from `racadm getpbinfo`. PowerState is "ON" or "OFF".
+	Name            string
+	BladeType       string
+	PowerState      string
+	PowerAllocation string
+	Priority        int
+	// IDRACIP is the blade's iDRAC address, from `racadm getniccfg`.
+	IDRACIP net.IP `json:",omitempty"`
+
+	// The rest are from the blade's BMC, and are left empty by backends that
+	// can't read them.
+
+	// Backend is how the blade's BMC was read, e.g. "ipmi".
+	Backend string `json:",omitempty"`
+	// BMCPowerOn is the BMC's view of the power state.
+	BMCPowerOn *bool `json:",omitempty"`
+	// AmbientTemp is the blade's inlet temperature, in degrees C.
+	AmbientTemp *float64       `json:",omitempty"`
+	Power       *BladePower    `json:",omitempty"`
+	Sensors     []*BladeSensor `json:",omitempty"`
+	// Firmware is the BMC's firmware revision.
+	Firmware string    `json:",omitempty"`
+	Watchdog *Watchdog `json:",omitempty"`
+	SelfTest *SelfTest `json:",omitempty"`
+	// FRU is the blade's hardware inventory. It only changes when someone
+	// swaps the blade, so readers may fill it in from a cache.
+	FRU *FRU `json:",omitempty"`
+	// NewEvents are the entries logged to the blade's SEL since it was last
+	// read. The first read has everything that's already in the SEL.
+	NewEvents []*SELEvent `json:",omitempty"`
+	// Credentials is the name of the IPMI credentials the BMC last accepted.
+	Credentials string `json:",omitempty"`
+
+	// Errors are the blade sources we couldn't read.
+	Errors []*SourceError `json:",omitempty"`
+}
+
+// On reports whether the CMC thinks the blade is on.
+func (s *Slot) On() bool {
+	return s.PowerState == "ON"
+}
+
+// Present reports whether there's a blade in the slot. The CMC lists empty
+// slots with a blade type of "N/A".
+func (s *Slot) Present() bool {
+	return s.BladeType != "" && s.BladeType != "N/A"
+}
+
+// String returns e.g. "slot 3 (web-1)".
+func (s *Slot) String() string {
+	if s.Name == "" {
+		return fmt.Sprintf("slot %d", s.Number)
+	}
+	return fmt.Sprintf("slot %d (%s)", s.Number, s.Name)
+}
+
+// Host returns the blade's iDRAC address as a string, or "" if we don't know
+// it.
+func (s *Slot) Host() string {
+	if s.IDRACIP == nil {
+		return ""
+	}
+	return s.IDRACIP.String()
+}
+
+// AddError records a failure to read the given source. BladeReaders call it
+// for whatever they couldn't read.
+func (s *Slot) AddError(source string, err error) {
+	s.Errors = append(s.Errors, &SourceError{Source: source, Err: err})
+}
+
+// Err returns the error reading the given source for this slot, or nil if
+// there wasn't one.
+func (s *Slot) Err(source string) *SourceError {
+	return findErr(s.Errors, source)
+}
+
+// BladePower is a blade's power draw in watts. Min, Max and Average are over
+// the BMC's sampling period, and are nil if the backend doesn't report them.
+type BladePower struct {
+	Current float64
+	Min     *float64 `json:",omitempty"`
+	Max     *float64 `json:",omitempty"`
+	Average *float64 `json:",omitempty"`
+}
+
+// BladeSensor is one of a blade's sensors, with its type and unit named the
+// way IPMI names them, whichever backend read it.
+type BladeSensor struct {
+	// Number is the sensor number, or "" if the backend doesn't know it.
+	Number string `json:",omitempty"`
+	Name   string
+	// Type is e.g. "Temperature", "Fan", "Voltage" or "Current".
+	Type string
+	// Unit is e.g. "degrees C", "RPM" or "Volts".
+	Unit  string
+	Value float64
+	// Status is as reported by the backend, e.g. "ok" over IPMI or "OK" over
+	// Redfish.
+	Status string `json:",omitempty"`
+}
+
+// OK reports whether the sensor's status is OK.
+func (s *BladeSensor) OK() bool {
+	return strings.EqualFold(s.Status, "OK")
+}
+
+// Watchdog is a blade's BMC watchdog timer, see ipmi.Watchdog.
+type Watchdog struct {
+	Running bool
+	// Use is what the timer was last set up for, e.g. "sms-os", and Action is
+	// what the BMC does when it expires, e.g. "hard-reset".
+	Use       string `json:",omitempty"`
+	Action    string
+	Remaining time.Duration
+	// Expired lists the uses the timer has expired for since its flags were
+	// last cleared.
+	Expired []string `json:",omitempty"`
+}
+
+// SelfTest is the result of a blade's BMC self test. Failures are named like
+// ipmi.SelfTestFailures.
+type SelfTest struct {
+	Passed   bool
+	Failures []string `json:",omitempty"`
+}
+
+// FRU is a blade's hardware inventory, see ipmi.FRU. On Dell blades, the
+// product serial is the service tag.
+type FRU struct {
+	BoardManufacturer string
+	BoardProduct      string
+	BoardSerial       string
+	BoardPartNumber   string
+	ManufactureDate   time.Time
+	ProductName       string `json:",omitempty"`
+	ProductSerial     string `json:",omitempty"`
+	AssetTag          string `json:",omitempty"`
+}
+
+// SELEvent is an entry in a blade's System Event Log.
+type SELEvent struct {
+	Timestamp time.Time
+	// SensorType is the IPMI sensor type of the sensor that logged the event,
+	// e.g. "Memory".
+	SensorType  string
+	Description string
+	// Severity is one of "info", "warning" or "critical", whichever backend
+	// read the event.
+	Severity string
+}
@@ -0,0 +1,231 @@
+package chassis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/racadm"
+)
+
+// CMCClient is what we need from the chassis' CMC, which is implemented by
+// *racadm.Client.
+type CMCClient interface {
+	GetSensorInfo() (*racadm.GetSensorInfo, error)
+	GetPowerBudgetInfo() (*racadm.GetPowerBudgetInfo, error)
+	GetNICConfig(slotNum int) (*racadm.GetNICConfig, error)
+	GetSysInfo() (*racadm.GetSysInfo, error)
+	GetIOInfo() (*racadm.GetIOInfo, error)
+}
+
+// BladeReader fills in the parts of a slot that come from the blade's BMC,
+// calling Slot.AddError for anything it can't read. It's called for every
+// slot, whether the blade is on or not, and whether or not we know its
+// iDRAC's address. It's called concurrently for different slots.
+type BladeReader interface {
+	ReadBlade(ctx context.Context, slot *Slot)
+}
+
+// BladeReaderFunc is a BladeReader that's just a function.
+type BladeReaderFunc func(ctx context.Context, slot *Slot)
+
+func (f BladeReaderFunc) ReadBlade(ctx context.Context, slot *Slot) {
+	f(ctx, slot)
+}
+
+// Collector fills in Chassis snapshots from the CMC and the blades' BMCs.
+type Collector struct {
+	cmc         CMCClient
+	blades      BladeReader
+	workers     int
+	hostTimeout time.Duration
+	now         func() time.Time
+}
+
+// Option configures optional behavior of a Collector.
+type Option func(*Collector)
+
+// WithBladeReader reads each blade's BMC with r. Without one, slots only have
+// what the CMC knows about them.
+func WithBladeReader(r BladeReader) Option {
+	return func(c *Collector) {
+		c.blades = r
+	}
+}
+
+// WithWorkers sets how many blades are read at once, 4 by default.
+func WithWorkers(n int) Option {
+	return func(c *Collector) {
+		c.workers = n
+	}
+}
+
+// WithHostTimeout limits how long reading each blade can take, 20 seconds by
+// default, so one unresponsive BMC can't hold up the rest.
+func WithHostTimeout(d time.Duration) Option {
+	return func(c *Collector) {
+		c.hostTimeout = d
+	}
+}
+
+const (
+	defaultWorkers     = 4
+	defaultHostTimeout = 20 * time.Second
+)
+
+// New returns a Collector that reads chassis-wide state from cmc, and each
+// blade's BMC with the BladeReader from WithBladeReader, if there is one.
+func New(cmc CMCClient, opts ...Option) *Collector {
+	c := &Collector{
+		cmc:         cmc,
+		workers:     defaultWorkers,
+		hostTimeout: defaultHostTimeout,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.workers < 1 {
+		c.workers = 1
+	}
+	return c
+}
+
+// Collect takes a snapshot of the chassis. It always returns one, with
+// whatever couldn't be read recorded in its Errors, and its slots' Errors.
+func (c *Collector) Collect(ctx context.Context) *Chassis {
+	ch := &Chassis{CollectedAt: c.now()}
+
+	if si, err := c.cmc.GetSensorInfo(); err != nil {
+		ch.addError(SourceSensorInfo, err)
+	} else {
+		ch.addSensorInfo(si)
+	}
+	if si, err := c.cmc.GetSysInfo(); err != nil {
+		ch.addError(SourceSysInfo, err)
+	} else {
+		ch.addSysInfo(si)
+	}
+	if io, err := c.cmc.GetIOInfo(); err != nil {
+		ch.addError(SourceIOInfo, err)
+	} else {
+		ch.addIOInfo(io)
+	}
+
+	pb, err := c.cmc.GetPowerBudgetInfo()
+	if err != nil {
+		ch.addError(SourcePowerBudget, err)
+		return ch
+	}
+	for _, s := range pb.ServerPowerInfo {
+		slot := &Slot{
+			Number:          s.SlotNumber,
+			Name:            s.ServerName,
+			BladeType:       s.BladeType,
+			PowerState:      s.PowerState,
+			PowerAllocation: s.Allocation,
+			Priority:        s.Priority,
+		}
+		ch.Slots = append(ch.Slots, slot)
+		// Empty slots don't have an iDRAC, and the CMC takes a while to tell
+		// us so.
+		if !slot.Present() {
+			continue
+		}
+		if nic, err := c.cmc.GetNICConfig(s.SlotNumber); err != nil {
+			slot.AddError(SourceNICConfig, err)
+		} else {
+			slot.IDRACIP = nic.IPAddress
+		}
+	}
+
+	if c.blades == nil {
+		return ch
+	}
+	sem := make(chan struct{}, c.workers)
+	var wg sync.WaitGroup
+	for _, slot := range ch.Slots {
+		slot := slot
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ctx, cancel := context.WithTimeout(ctx, c.hostTimeout)
+			defer cancel()
+			c.blades.ReadBlade(ctx, slot)
+		}()
+	}
+	wg.Wait()
+	return ch
+}
+
+func (ch *Chassis) addSensorInfo(si *racadm.GetSensorInfo) {
+	toSensors := func(in []*racadm.Sensor) []*Sensor {
+		var out []*Sensor
+		for _, s := range in {
+			out = append(out, &Sensor{
+				Number:  s.Number,
+				Name:    s.SensorName,
+				Status:  s.Status,
+				Reading: float64(s.Reading),
+				Units:   s.Units,
+			})
+		}
+		return out
+	}
+	ch.AmbientTemps = toSensors(si.AmbientTemp)
+	ch.Fans = toSensors(si.Fans)
+	for _, p := range si.PowerSupplies {
+		ch.PSUs = append(ch.PSUs, &PSU{
+			Number: p.Number,
+			Name:   p.SensorName,
+			Status: p.Status,
+			Health: p.Health,
+		})
+	}
+}
+
+func (ch *Chassis) addSysInfo(si *racadm.GetSysInfo) {
+	ch.Name = si.ChassisName
+	ch.Model = si.SystemModel
+	ch.ServiceTag = si.ServiceTag
+
+	ch.CMCs = append(ch.CMCs, &CMC{
+		Location: si.PrimaryCMCLocation,
+		Primary:  true,
+		Firmware: si.PrimaryCMCVersion,
+	})
+	// The CMC only tells us where the primary is, and the standby is in the
+	// other bay.
+	if si.StandbyCMCVersion == "" || si.StandbyCMCVersion == "N/A" {
+		return
+	}
+	standby := ""
+	switch si.PrimaryCMCLocation {
+	case "CMC-1":
+		standby = "CMC-2"
+	case "CMC-2":
+		standby = "CMC-1"
+	}
+	ch.CMCs = append(ch.CMCs, &CMC{
+		Location: standby,
+		Firmware: si.StandbyCMCVersion,
+	})
+}
+
+func (ch *Chassis) addIOInfo(io *racadm.GetIOInfo) {
+	for _, m := range io.IOModules {
+		ch.IOMs = append(ch.IOMs, &IOM{
+			Slot:    m.Slot,
+			Name:    m.Name,
+			Type:    m.Type,
+			Present: m.Present,
+			POST:    m.POST,
+			Power:   m.Power,
+			Role:    m.Role,
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package chassis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/racadm"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeCMC returns canned racadm output, or errors for the subcommands in
+// errs.
+type fakeCMC struct {
+	sensorInfo *racadm.GetSensorInfo
+	pbInfo     *racadm.GetPowerBudgetInfo
+	sysInfo    *racadm.GetSysInfo
+	ioInfo     *racadm.GetIOInfo
+	ips        map[int]net.IP
+	errs       map[string]error
+	// nicLookups are the slots we've been asked for the NIC config of.
+	nicLookups []int
+}
+
+func (f *fakeCMC) GetSensorInfo() (*racadm.GetSensorInfo, error) {
+	return f.sensorInfo, f.errs[SourceSensorInfo]
+}
+
+func (f *fakeCMC) GetPowerBudgetInfo() (*racadm.GetPowerBudgetInfo, error) {
+	return f.pbInfo, f.errs[SourcePowerBudget]
+}
+
+func (f *fakeCMC) GetSysInfo() (*racadm.GetSysInfo, error) {
+	return f.sysInfo, f.errs[SourceSysInfo]
+}
+
+func (f *fakeCMC) GetIOInfo() (*racadm.GetIOInfo, error) {
+	return f.ioInfo, f.errs[SourceIOInfo]
+}
+
+func (f *fakeCMC) GetNICConfig(slotNum int) (*racadm.GetNICConfig, error) {
+	f.nicLookups = append(f.nicLookups, slotNum)
+	ip, ok := f.ips[slotNum]
+	if !ok {
+		return nil, fmt.Errorf("no NIC config for slot %d", slotNum)
+	}
+	return &racadm.GetNICConfig{IPAddress: ip}, nil
+}
+
+func newFakeCMC() *fakeCMC {
+	return &fakeCMC{
+		sensorInfo: &racadm.GetSensorInfo{
+			Fans: []*racadm.Sensor{
+				{Number: 1, SensorName: "Fan-1", Status: "OK", Reading: 4800, Units: "rpm"},
+			},
+			AmbientTemp: []*racadm.Sensor{
+				{Number: 1, SensorName: "Ambient_Temp", Status: "OK", Reading: 22, Units: "Celsius"},
+			},
+			PowerSupplies: []*racadm.PowerSupplyInfo{
+				{Number: 1, SensorName: "PS-1", Status: "Online", Health: "OK"},
+				{Number: 2, SensorName: "PS-2", Status: "Slot Empty", Health: "N/A"},
+			},
+		},
+		pbInfo: &racadm.GetPowerBudgetInfo{
+			ServerPowerInfo: []*racadm.ServerPowerInfo{
+				{SlotNumber: 1, ServerName: "web-1", PowerState: "ON", Allocation: "250 W", Priority: 1, BladeType: "PowerEdgeM610"},
+				{SlotNumber: 2, ServerName: "SLOT-02", PowerState: "N/A", Allocation: "0 W", Priority: 1, BladeType: "N/A"},
+				{SlotNumber: 3, ServerName: "db-1", PowerState: "OFF", Allocation: "0 W", Priority: 1, BladeType: "PowerEdgeM630"},
+			},
+		},
+		sysInfo: &racadm.GetSysInfo{
+			ChassisName:        "CMC-ABC1234",
+			SystemModel:        "PowerEdge M1000e",
+			ServiceTag:         "ABC1234",
+			PrimaryCMCLocation: "CMC-2",
+			PrimaryCMCVersion:  "6.21",
+			StandbyCMCVersion:  "6.20",
+		},
+		ioInfo: &racadm.GetIOInfo{
+			IOModules: []*racadm.IOModule{
+				{Slot: "switch-1", Name: "M8024-k", Type: "10 GbE KR", Present: true, POST: "OK", Power: "ON", Role: "Master"},
+			},
+		},
+		ips:  map[int]net.IP{1: net.ParseIP("10.0.0.101")},
+		errs: make(map[string]error),
+	}
+}
+
+func TestCollect(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	var (
+		mu   sync.Mutex
+		read []int
+	)
+	cmc := newFakeCMC()
+	c := New(cmc, WithBladeReader(BladeReaderFunc(func(ctx context.Context, slot *Slot) {
+		mu.Lock()
+		read = append(read, slot.Number)
+		mu.Unlock()
+		slot.Backend = "fake"
+		if slot.Host() == "" {
+			return
+		}
+		temp := 21.0
+		slot.AmbientTemp = &temp
+		slot.AddError(SourcePower, errors.New("no DCMI"))
+	})))
+	c.now = func() time.Time { return now }
+
+	got := c.Collect(context.Background())
+
+	temp := 21.0
+	want := &Chassis{
+		CollectedAt: now,
+		Name:        "CMC-ABC1234",
+		Model:       "PowerEdge M1000e",
+		ServiceTag:  "ABC1234",
+		AmbientTemps: []*Sensor{
+			{Number: 1, Name: "Ambient_Temp", Status: "OK", Reading: 22, Units: "Celsius"},
+		},
+		Fans: []*Sensor{
+			{Number: 1, Name: "Fan-1", Status: "OK", Reading: 4800, Units: "rpm"},
+		},
+		PSUs: []*PSU{
+			{Number: 1, Name: "PS-1", Status: "Online", Health: "OK"},
+			{Number: 2, Name: "PS-2", Status: "Slot Empty", Health: "N/A"},
+		},
+		IOMs: []*IOM{
+			{Slot: "switch-1", Name: "M8024-k", Type: "10 GbE KR", Present: true, POST: "OK", Power: "ON", Role: "Master"},
+		},
+		CMCs: []*CMC{
+			{Location: "CMC-2", Primary: true, Firmware: "6.21"},
+			{Location: "CMC-1", Firmware: "6.20"},
+		},
+		Slots: []*Slot{
+			{
+				Number:          1,
+				Name:            "web-1",
+				BladeType:       "PowerEdgeM610",
+				PowerState:      "ON",
+				PowerAllocation: "250 W",
+				Priority:        1,
+				IDRACIP:         net.ParseIP("10.0.0.101"),
+				Backend:         "fake",
+				AmbientTemp:     &temp,
+				Errors:          []*SourceError{{Source: SourcePower, Err: errors.New("no DCMI")}},
+			},
+			{
+				Number:          2,
+				Name:            "SLOT-02",
+				BladeType:       "N/A",
+				PowerState:      "N/A",
+				PowerAllocation: "0 W",
+				Priority:        1,
+				Backend:         "fake",
+			},
+			{
+				Number:          3,
+				Name:            "db-1",
+				BladeType:       "PowerEdgeM630",
+				PowerState:      "OFF",
+				PowerAllocation: "0 W",
+				Priority:        1,
+				Backend:         "fake",
+				Errors:          []*SourceError{{Source: SourceNICConfig, Err: errors.New("no NIC config for slot 3")}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(sameError)); diff != "" {
+		t.Errorf("unexpected snapshot (-want +got)\n%s", diff)
+	}
+	if len(read) != 3 {
+		t.Errorf("read %d blades, want 3", len(read))
+	}
+	// The empty slot doesn't have an iDRAC to look up.
+	if diff := cmp.Diff([]int{1, 3}, cmc.nicLookups); diff != "" {
+		t.Errorf("unexpected NIC config lookups (-want +got)\n%s", diff)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	cmc := newFakeCMC()
+	cmc.errs[SourceSensorInfo] = errors.New("ssh: handshake failed")
+	cmc.errs[SourceIOInfo] = errors.New("timed out")
+	got := New(cmc).Collect(context.Background())
+
+	// One failing subcommand doesn't stop us reading the rest.
+	if got.Err(SourceSensorInfo) == nil || got.Err(SourceIOInfo) == nil {
+		t.Errorf("Errors = %v, want getsensorinfo and getioinfo errors", got.Errors)
+	}
+	if got.Err(SourceSysInfo) != nil || got.Name != "CMC-ABC1234" {
+		t.Errorf("failed to read sys info: %v", got.Errors)
+	}
+	if got.Fans != nil || got.IOMs != nil {
+		t.Errorf("got fans %v and IOMs %v from failed subcommands", got.Fans, got.IOMs)
+	}
+	if s := got.Slot(1); s == nil || s.Host() != "10.0.0.101" {
+		t.Errorf("Slot(1) = %+v, want slot 1 at 10.0.0.101", s)
+	}
+
+	// Without the power budget, we don't know what's in the slots.
+	cmc.errs[SourcePowerBudget] = errors.New("timed out")
+	got = New(cmc, WithBladeReader(BladeReaderFunc(func(ctx context.Context, slot *Slot) {
+		t.Errorf("read slot %d without a power budget", slot.Number)
+	}))).Collect(context.Background())
+	if got.Err(SourcePowerBudget) == nil || got.Slots != nil {
+		t.Errorf("got slots %v and errors %v, want a getpbinfo error and no slots", got.Slots, got.Errors)
+	}
+}
+
+func TestCollectHostTimeout(t *testing.T) {
+	cmc := newFakeCMC()
+	c := New(cmc, WithHostTimeout(10*time.Millisecond), WithBladeReader(BladeReaderFunc(func(ctx context.Context, slot *Slot) {
+		if slot.Number != 1 {
+			return
+		}
+		<-ctx.Done()
+		slot.AddError(SourceSensors, ctx.Err())
+	})))
+
+	got := c.Collect(context.Background())
+	if err := got.Slot(1).Err(SourceSensors); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slot 1 sensors error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if errs := got.Slot(3).Errors; len(errs) != 1 {
+		t.Errorf("slot 3 errors = %v, want just its NIC config", errs)
+	}
+}
+
+func sameError(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Error() == b.Error()
+}
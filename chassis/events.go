@@ -0,0 +1,295 @@
+package chassis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType is what changed between two snapshots.
+type EventType string
+
+const (
+	// BladePoweredOn and BladePoweredOff are the CMC's view of the power state
+	// changing.
+	BladePoweredOn  EventType = "blade_powered_on"
+	BladePoweredOff EventType = "blade_powered_off"
+	BladeInserted   EventType = "blade_inserted"
+	BladeRemoved    EventType = "blade_removed"
+	// FanStatusChanged is any change in a chassis fan's status, e.g. from "OK"
+	// to "Failed" or back.
+	FanStatusChanged EventType = "fan_status_changed"
+	// PSUOffline is a PSU going from "Online" to anything else, including
+	// being pulled.
+	PSUOffline EventType = "psu_offline"
+	// SensorStatusLeftOK is a chassis ambient temp sensor or blade sensor
+	// going from OK to anything else.
+	SensorStatusLeftOK EventType = "sensor_status_left_ok"
+	// FirmwareChanged is a blade's BMC or a CMC reporting a different
+	// firmware version.
+	FirmwareChanged EventType = "firmware_changed"
+)
+
+// Event is a change between two successive snapshots of a chassis.
+type Event struct {
+	Type EventType
+	// Time is when the snapshot the change showed up in was collected.
+	Time time.Time
+	// Slot is the blade's slot, for events about a blade or one of its
+	// sensors, and 0 otherwise.
+	Slot int `json:",omitempty"`
+	// Component is what changed, e.g. "Fan-1", "PS-2", "CMC-1" or a sensor's
+	// name, or "" for events about a blade itself.
+	Component string `json:",omitempty"`
+	// From and To are the old and new status or firmware version, where
+	// that's what changed.
+	From string `json:",omitempty"`
+	To   string `json:",omitempty"`
+	// Message describes the event, e.g. "slot 3 (web-1) powered off".
+	Message string
+}
+
+// Diff returns the events between two successive snapshots of a chassis,
+// which are empty if old is nil. Anything that couldn't be read for either
+// snapshot is skipped, so a failed read doesn't look like every blade being
+// removed.
+func Diff(old, cur *Chassis) []*Event {
+	if old == nil || cur == nil {
+		return nil
+	}
+	d := &differ{at: cur.CollectedAt}
+	if old.Err(SourceSensorInfo) == nil && cur.Err(SourceSensorInfo) == nil {
+		d.diffFans(old.Fans, cur.Fans)
+		d.diffPSUs(old.PSUs, cur.PSUs)
+		for _, s := range cur.AmbientTemps {
+			if o := findSensor(old.AmbientTemps, s.Number); o != nil && o.OK() && !s.OK() {
+				d.add(&Event{
+					Type:      SensorStatusLeftOK,
+					Component: s.Name,
+					From:      o.Status,
+					To:        s.Status,
+					Message:   fmt.Sprintf("chassis sensor %s is %s", s.Name, s.Status),
+				})
+			}
+		}
+	}
+	if old.Err(SourceSysInfo) == nil && cur.Err(SourceSysInfo) == nil {
+		d.diffCMCs(old.CMCs, cur.CMCs)
+	}
+	if old.Err(SourcePowerBudget) == nil && cur.Err(SourcePowerBudget) == nil {
+		for _, s := range cur.Slots {
+			d.diffSlot(old.Slot(s.Number), s)
+		}
+		// Slots can't disappear, but just in case.
+		for _, o := range old.Slots {
+			if o.Present() && cur.Slot(o.Number) == nil {
+				d.add(&Event{Type: BladeRemoved, Slot: o.Number, Message: fmt.Sprintf("%s was removed", o)})
+			}
+		}
+	}
+	return d.events
+}
+
+type differ struct {
+	at     time.Time
+	events []*Event
+}
+
+func (d *differ) add(e *Event) {
+	e.Time = d.at
+	d.events = append(d.events, e)
+}
+
+func (d *differ) diffFans(old, cur []*Sensor) {
+	for _, f := range cur {
+		o := findSensor(old, f.Number)
+		if o == nil || o.Status == f.Status {
+			continue
+		}
+		d.add(&Event{
+			Type:      FanStatusChanged,
+			Component: f.Name,
+			From:      o.Status,
+			To:        f.Status,
+			Message:   fmt.Sprintf("fan %s went from %s to %s", f.Name, o.Status, f.Status),
+		})
+	}
+}
+
+func (d *differ) diffPSUs(old, cur []*PSU) {
+	for _, o := range old {
+		if !o.Online() {
+			continue
+		}
+		var p *PSU
+		for _, cp := range cur {
+			if cp.Number == o.Number {
+				p = cp
+			}
+		}
+		switch {
+		case p == nil:
+			d.add(&Event{Type: PSUOffline, Component: o.Name, From: o.Status, Message: fmt.Sprintf("PSU %s went offline", o.Name)})
+		case !p.Online():
+			d.add(&Event{
+				Type:      PSUOffline,
+				Component: p.Name,
+				From:      o.Status,
+				To:        p.Status,
+				Message:   fmt.Sprintf("PSU %s went offline: %s", p.Name, p.Status),
+			})
+		}
+	}
+}
+
+func (d *differ) diffCMCs(old, cur []*CMC) {
+	for _, c := range cur {
+		for _, o := range old {
+			if o.Location != c.Location || o.Firmware == c.Firmware || o.Firmware == "" || c.Firmware == "" {
+				continue
+			}
+			d.add(&Event{
+				Type:      FirmwareChanged,
+				Component: c.Location,
+				From:      o.Firmware,
+				To:        c.Firmware,
+				Message:   fmt.Sprintf("%s firmware changed from %s to %s", c.Location, o.Firmware, c.Firmware),
+			})
+		}
+	}
+}
+
+func (d *differ) diffSlot(o, s *Slot) {
+	wasPresent := o != nil && o.Present()
+	switch {
+	case !wasPresent && s.Present():
+		d.add(&Event{Type: BladeInserted, Slot: s.Number, Message: fmt.Sprintf("%s was inserted", s)})
+		return
+	case wasPresent && !s.Present():
+		d.add(&Event{Type: BladeRemoved, Slot: s.Number, Message: fmt.Sprintf("%s was removed", o)})
+		return
+	case !s.Present():
+		return
+	}
+
+	switch {
+	case !o.On() && s.On():
+		d.add(&Event{Type: BladePoweredOn, Slot: s.Number, From: o.PowerState, To: s.PowerState, Message: fmt.Sprintf("%s powered on", s)})
+	case o.On() && !s.On():
+		d.add(&Event{Type: BladePoweredOff, Slot: s.Number, From: o.PowerState, To: s.PowerState, Message: fmt.Sprintf("%s powered off", s)})
+	}
+
+	// Firmware is empty when we couldn't read it.
+	if o.Firmware != "" && s.Firmware != "" && o.Firmware != s.Firmware {
+		d.add(&Event{
+			Type:    FirmwareChanged,
+			Slot:    s.Number,
+			From:    o.Firmware,
+			To:      s.Firmware,
+			Message: fmt.Sprintf("%s BMC firmware changed from %s to %s", s, o.Firmware, s.Firmware),
+		})
+	}
+
+	for _, sn := range s.Sensors {
+		var prev *BladeSensor
+		for _, p := range o.Sensors {
+			if p.Number == sn.Number && p.Name == sn.Name {
+				prev = p
+			}
+		}
+		if prev == nil || !prev.OK() || sn.OK() {
+			continue
+		}
+		d.add(&Event{
+			Type:      SensorStatusLeftOK,
+			Slot:      s.Number,
+			Component: sn.Name,
+			From:      prev.Status,
+			To:        sn.Status,
+			Message:   fmt.Sprintf("%s sensor %s is %s", s, sn.Name, sn.Status),
+		})
+	}
+}
+
+func findSensor(sensors []*Sensor, number int) *Sensor {
+	for _, s := range sensors {
+		if s.Number == number {
+			return s
+		}
+	}
+	return nil
+}
+
+// eventBuffer is how many events each subscriber can fall behind by before
+// it starts missing them.
+const eventBuffer = 64
+
+// Watcher diffs each snapshot it's given against the last one, and sends the
+// events to its subscribers.
+type Watcher struct {
+	mu     sync.Mutex
+	last   *Chassis
+	subs   map[chan *Event]bool
+	closed bool
+}
+
+// NewWatcher returns a Watcher with no snapshot yet, so the first Update has
+// no events.
+func NewWatcher() *Watcher {
+	return &Watcher{subs: make(map[chan *Event]bool)}
+}
+
+// Update diffs the snapshot against the last one it was given, sends the
+// events to every subscriber, and returns them. Subscribers that have fallen
+// too far behind miss events rather than holding up the rest.
+func (w *Watcher) Update(ch *Chassis) []*Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := Diff(w.last, ch)
+	w.last = ch
+	for sub := range w.subs {
+		for _, e := range events {
+			select {
+			case sub <- e:
+			default:
+			}
+		}
+	}
+	return events
+}
+
+// Subscribe returns a channel of the events from every later Update, and a
+// function to stop receiving them, which closes the channel.
+func (w *Watcher) Subscribe() (<-chan *Event, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	sub := make(chan *Event, eventBuffer)
+	if w.closed {
+		close(sub)
+		return sub, func() {}
+	}
+	w.subs[sub] = true
+	return sub, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.subs[sub] {
+			delete(w.subs, sub)
+			close(sub)
+		}
+	}
+}
+
+// Close closes every subscriber's channel. Later subscribers get a channel
+// that's already closed.
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	for sub := range w.subs {
+		delete(w.subs, sub)
+		close(sub)
+	}
+}
@@ -0,0 +1,198 @@
+package chassis
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func testSnapshot(at time.Time) *Chassis {
+	return &Chassis{
+		CollectedAt:  at,
+		AmbientTemps: []*Sensor{{Number: 1, Name: "Ambient_Temp", Status: "OK", Reading: 22, Units: "Celsius"}},
+		Fans: []*Sensor{
+			{Number: 1, Name: "Fan-1", Status: "OK", Reading: 4800, Units: "rpm"},
+			{Number: 2, Name: "Fan-2", Status: "OK", Reading: 4800, Units: "rpm"},
+		},
+		PSUs: []*PSU{
+			{Number: 1, Name: "PS-1", Status: "Online", Health: "OK"},
+			{Number: 2, Name: "PS-2", Status: "Slot Empty", Health: "N/A"},
+		},
+		CMCs: []*CMC{{Location: "CMC-1", Primary: true, Firmware: "6.21"}},
+		Slots: []*Slot{
+			{
+				Number:     1,
+				Name:       "web-1",
+				BladeType:  "PowerEdgeM610",
+				PowerState: "ON",
+				Firmware:   "1.00",
+				Sensors: []*BladeSensor{
+					{Number: "14", Name: "Ambient Temp", Type: "Temperature", Unit: "degrees C", Value: 21, Status: "ok"},
+				},
+			},
+			{Number: 2, Name: "db-1", BladeType: "PowerEdgeM630", PowerState: "OFF"},
+			{Number: 3, Name: "SLOT-03", BladeType: "N/A", PowerState: "N/A"},
+		},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t0 := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(30 * time.Second)
+
+	tests := []struct {
+		desc   string
+		change func(ch *Chassis)
+		want   []*Event
+	}{
+		{
+			desc:   "no change",
+			change: func(ch *Chassis) {},
+		},
+		{
+			desc: "power",
+			change: func(ch *Chassis) {
+				ch.Slot(1).PowerState = "OFF"
+				ch.Slot(2).PowerState = "ON"
+			},
+			want: []*Event{
+				{Type: BladePoweredOff, Slot: 1, From: "ON", To: "OFF", Message: "slot 1 (web-1) powered off"},
+				{Type: BladePoweredOn, Slot: 2, From: "OFF", To: "ON", Message: "slot 2 (db-1) powered on"},
+			},
+		},
+		{
+			desc: "insert and remove",
+			change: func(ch *Chassis) {
+				*ch.Slot(2) = Slot{Number: 2, Name: "SLOT-02", BladeType: "N/A", PowerState: "N/A"}
+				*ch.Slot(3) = Slot{Number: 3, Name: "web-3", BladeType: "PowerEdgeM620", PowerState: "OFF"}
+			},
+			want: []*Event{
+				{Type: BladeRemoved, Slot: 2, Message: "slot 2 (db-1) was removed"},
+				{Type: BladeInserted, Slot: 3, Message: "slot 3 (web-3) was inserted"},
+			},
+		},
+		{
+			desc: "fans and PSUs",
+			change: func(ch *Chassis) {
+				ch.Fans[1].Status = "Failed"
+				ch.PSUs[0].Status = "Off"
+				// Going from empty to online isn't an event.
+				ch.PSUs[1].Status = "Online"
+			},
+			want: []*Event{
+				{Type: FanStatusChanged, Component: "Fan-2", From: "OK", To: "Failed", Message: "fan Fan-2 went from OK to Failed"},
+				{Type: PSUOffline, Component: "PS-1", From: "Online", To: "Off", Message: "PSU PS-1 went offline: Off"},
+			},
+		},
+		{
+			desc: "sensors",
+			change: func(ch *Chassis) {
+				ch.AmbientTemps[0].Status = "Critical"
+				ch.Slot(1).Sensors[0].Status = "ucr"
+			},
+			want: []*Event{
+				{Type: SensorStatusLeftOK, Component: "Ambient_Temp", From: "OK", To: "Critical", Message: "chassis sensor Ambient_Temp is Critical"},
+				{Type: SensorStatusLeftOK, Slot: 1, Component: "Ambient Temp", From: "ok", To: "ucr", Message: "slot 1 (web-1) sensor Ambient Temp is ucr"},
+			},
+		},
+		{
+			desc: "firmware",
+			change: func(ch *Chassis) {
+				ch.CMCs[0].Firmware = "6.30"
+				ch.Slot(1).Firmware = "2.00"
+			},
+			want: []*Event{
+				{Type: FirmwareChanged, Component: "CMC-1", From: "6.21", To: "6.30", Message: "CMC-1 firmware changed from 6.21 to 6.30"},
+				{Type: FirmwareChanged, Slot: 1, From: "1.00", To: "2.00", Message: "slot 1 (web-1) BMC firmware changed from 1.00 to 2.00"},
+			},
+		},
+		{
+			desc: "unreadable blade",
+			change: func(ch *Chassis) {
+				// The BMC didn't answer, which isn't a firmware change or a
+				// sensor leaving OK.
+				s := ch.Slot(1)
+				s.Firmware, s.Sensors = "", nil
+				s.AddError(SourceSensors, errors.New("timed out"))
+			},
+		},
+		{
+			desc: "unreadable CMC",
+			change: func(ch *Chassis) {
+				ch.Fans, ch.PSUs, ch.Slots = nil, nil, nil
+				ch.addError(SourceSensorInfo, errors.New("timed out"))
+				ch.addError(SourcePowerBudget, errors.New("timed out"))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cur := testSnapshot(t1)
+			test.change(cur)
+			for _, e := range test.want {
+				e.Time = t1
+			}
+			got := Diff(testSnapshot(t0), cur)
+			if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected events (-want +got)\n%s", diff)
+			}
+		})
+	}
+
+	if got := Diff(nil, testSnapshot(t0)); got != nil {
+		t.Errorf("Diff(nil, snapshot) = %v, want no events", got)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	w := NewWatcher()
+	events, stop := w.Subscribe()
+
+	t0 := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	if got := w.Update(testSnapshot(t0)); len(got) != 0 {
+		t.Errorf("first Update returned %d events, want 0", len(got))
+	}
+
+	cur := testSnapshot(t0.Add(30 * time.Second))
+	cur.Slot(1).PowerState = "OFF"
+	w.Update(cur)
+	select {
+	case e := <-events:
+		if e.Type != BladePoweredOff || e.Slot != 1 {
+			t.Errorf("got event %+v, want slot 1 powered off", e)
+		}
+	default:
+		t.Fatal("no event sent to subscriber")
+	}
+
+	// A subscriber that isn't keeping up doesn't hold up Update.
+	for i := 0; i < eventBuffer+1; i++ {
+		cur = testSnapshot(cur.CollectedAt.Add(30 * time.Second))
+		if i%2 == 0 {
+			cur.Slot(1).PowerState = "OFF"
+		}
+		w.Update(cur)
+	}
+	if n := len(events); n != eventBuffer {
+		t.Errorf("subscriber has %d events buffered, want %d", n, eventBuffer)
+	}
+
+	stop()
+	for range events {
+	}
+	stop()
+
+	// Closing the watcher closes everyone's channels.
+	events, _ = w.Subscribe()
+	w.Close()
+	if _, ok := <-events; ok {
+		t.Error("subscriber's channel is still open after Close")
+	}
+	late, _ := w.Subscribe()
+	if _, ok := <-late; ok {
+		t.Error("Subscribe after Close returned an open channel")
+	}
+}
@@ -0,0 +1,153 @@
+package chassis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/bcspragu/m1000e-prom/ipmi"
+)
+
+// IPMIReader is a BladeReader that reads blades' BMCs over IPMI.
+type IPMIReader struct {
+	client *ipmi.Client
+}
+
+// NewIPMIReader returns an IPMIReader that reads BMCs with c, which should
+// already have any per-blade credentials set.
+func NewIPMIReader(c *ipmi.Client) *IPMIReader {
+	return &IPMIReader{client: c}
+}
+
+// ReadBlade reads the BMC's view of the power state, its firmware, watchdog
+// and self test whenever we know the blade's address, since BMCs are on
+// standby power. Everything else is only read if the CMC says the blade is
+// on.
+func (r *IPMIReader) ReadBlade(ctx context.Context, slot *Slot) {
+	slot.Backend = "ipmi"
+	host := slot.Host()
+	if host == "" {
+		return
+	}
+
+	if ps, err := r.client.PowerStatus(ctx, host); err != nil {
+		slot.AddError(SourcePowerState, err)
+	} else {
+		on := ps.On
+		slot.BMCPowerOn = &on
+	}
+
+	if info, err := r.client.DeviceInfo(ctx, host); err != nil {
+		slot.AddError(SourceFirmware, err)
+	} else {
+		slot.Firmware = info.Firmware
+		if !info.Available {
+			slot.AddError(SourceFirmware, errors.New("BMC is unavailable, it may be updating its firmware"))
+		}
+	}
+
+	if wd, err := r.client.Watchdog(ctx, host); err != nil {
+		slot.AddError(SourceWatchdog, err)
+	} else {
+		slot.Watchdog = &Watchdog{
+			Running:   wd.Running,
+			Use:       wd.Use,
+			Action:    wd.Action,
+			Remaining: wd.Remaining,
+			Expired:   wd.Expired,
+		}
+	}
+
+	if st, err := r.client.SelfTest(ctx, host); err != nil {
+		slot.AddError(SourceSelfTest, err)
+	} else {
+		slot.SelfTest = &SelfTest{Passed: st.Passed, Failures: st.Failures}
+	}
+
+	// By now we've logged in to the BMC, if we're going to.
+	slot.Credentials = r.client.AcceptedCredentials(host)
+
+	if !slot.On() {
+		return
+	}
+
+	if events, err := r.client.NewEvents(ctx, host); err != nil {
+		slot.AddError(SourceSEL, err)
+	} else {
+		for _, e := range events {
+			slot.NewEvents = append(slot.NewEvents, &SELEvent{
+				Timestamp:   e.Timestamp,
+				SensorType:  e.SensorType,
+				Description: e.Description,
+				Severity:    string(e.Severity),
+			})
+		}
+	}
+
+	if sensors, err := r.client.Sensors(ctx, host); err != nil {
+		slot.AddError(SourceSensors, err)
+	} else {
+		for _, s := range sensors {
+			if !s.HasReading {
+				continue
+			}
+			slot.Sensors = append(slot.Sensors, &BladeSensor{
+				Number: strconv.Itoa(int(s.Number)),
+				Name:   s.Name,
+				Type:   s.Type,
+				Unit:   s.Unit,
+				Value:  s.Value,
+				Status: s.Status,
+			})
+		}
+	}
+
+	if pr, err := r.client.PowerReading(ctx, host); err != nil {
+		slot.AddError(SourcePower, err)
+	} else if !pr.Active {
+		slot.AddError(SourcePower, errors.New("power measurement isn't active"))
+	} else {
+		slot.Power = &BladePower{
+			Current: float64(pr.CurrentWatts),
+			Min:     wattsPtr(pr.MinWatts),
+			Max:     wattsPtr(pr.MaxWatts),
+			Average: wattsPtr(pr.AverageWatts),
+		}
+	}
+
+	if temp, err := r.client.AmbientTemp(ctx, host); err != nil {
+		slot.AddError(SourceAmbientTemp, err)
+	} else {
+		slot.AmbientTemp = &temp
+	}
+}
+
+// ReadFRU reads the blade's FRU data. It's not part of ReadBlade because it
+// takes a bunch of round trips to the BMC and only changes when someone swaps
+// the blade, so callers should cache it.
+func (r *IPMIReader) ReadFRU(ctx context.Context, slot *Slot) {
+	host := slot.Host()
+	if host == "" {
+		return
+	}
+	fru, err := r.client.FRU(ctx, host)
+	if err != nil {
+		slot.AddError(SourceFRU, err)
+		return
+	}
+	slot.FRU = &FRU{
+		BoardManufacturer: fru.BoardManufacturer,
+		BoardProduct:      fru.BoardProduct,
+		BoardSerial:       fru.BoardSerial,
+		BoardPartNumber:   fru.BoardPartNumber,
+		ManufactureDate:   fru.ManufactureDate,
+		ProductName:       fru.ProductName,
+		ProductSerial:     fru.ProductSerial,
+		AssetTag:          fru.AssetTag,
+	}
+}
+
+func wattsPtr(w uint16) *float64 {
+	f := float64(w)
+	return &f
+}
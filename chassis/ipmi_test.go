@@ -0,0 +1,150 @@
+package chassis
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/google/go-cmp/cmp"
+)
+
+// getPowerReading is DCMI's Get Power Reading, which the simulator doesn't
+// answer on its own.
+var getPowerReading = ipmisim.Command{NetFn: 0x2c, Cmd: 0x02}
+
+func TestIPMIReader(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 21)
+	bmc.Handle(getPowerReading, func(data []byte) (uint8, []byte) {
+		return ipmisim.CompletionOK, []byte{
+			0xdc,       // Group extension ID
+			0x2c, 0x01, // Current: 300
+			0xb4, 0x00, // Min: 180
+			0xc2, 0x01, // Max: 450
+			0x18, 0x01, // Average: 280
+			0x00, 0x00, 0x00, 0x60, // Timestamp
+			0xe8, 0x03, 0x00, 0x00, // Period: 1000 ms
+			0x40, // Power measurement active
+		}
+	})
+
+	logged := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	bmc.AddSELEntry(ipmisim.SELEntry{Timestamp: logged, SensorType: 0x0c, SensorNumber: 0x01, EventDirType: 0x6f, EventData: [3]byte{0x01, 0xff, 0xff}})
+
+	c := ipmi.New("root", "calvin",
+		ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { c.Close() })
+	r := NewIPMIReader(c)
+
+	slot := &Slot{Number: 1, PowerState: "ON", IDRACIP: net.ParseIP(bmc.Host)}
+	r.ReadBlade(context.Background(), slot)
+
+	on, temp := true, 21.0
+	min, max, avg := 180.0, 450.0, 280.0
+	want := &Slot{
+		Number:      1,
+		PowerState:  "ON",
+		IDRACIP:     net.ParseIP(bmc.Host),
+		Backend:     "ipmi",
+		BMCPowerOn:  &on,
+		AmbientTemp: &temp,
+		Power:       &BladePower{Current: 300, Min: &min, Max: &max, Average: &avg},
+		Sensors: []*BladeSensor{
+			{Number: "14", Name: "Ambient Temp", Type: "Temperature", Unit: "degrees C", Value: 21, Status: "ok"},
+		},
+		Firmware:    "1.00",
+		Watchdog:    &Watchdog{Action: "none"},
+		SelfTest:    &SelfTest{Passed: true},
+		NewEvents:   []*SELEvent{{Timestamp: logged, SensorType: "Memory", Description: "Uncorrectable ECC / other uncorrectable memory error", Severity: "critical"}},
+		Credentials: "default",
+	}
+	if diff := cmp.Diff(want, slot); diff != "" {
+		t.Errorf("unexpected slot (-want +got)\n%s", diff)
+	}
+
+	// We've already seen everything in the SEL.
+	slot = &Slot{Number: 1, PowerState: "ON", IDRACIP: net.ParseIP(bmc.Host)}
+	r.ReadBlade(context.Background(), slot)
+	if slot.NewEvents != nil {
+		t.Errorf("got new events %v from an unchanged SEL", slot.NewEvents)
+	}
+
+	// Blades that are off only get their BMC's power state, firmware, watchdog
+	// and self test read.
+	bmc.SetPowerOn(false)
+	slot = &Slot{Number: 1, PowerState: "OFF", IDRACIP: net.ParseIP(bmc.Host)}
+	r.ReadBlade(context.Background(), slot)
+	if slot.BMCPowerOn == nil || *slot.BMCPowerOn {
+		t.Errorf("BMC power on = %v, want false", slot.BMCPowerOn)
+	}
+	if slot.Firmware != "1.00" {
+		t.Errorf("firmware = %q, want 1.00", slot.Firmware)
+	}
+	if slot.Watchdog == nil || slot.SelfTest == nil {
+		t.Errorf("watchdog = %v and self test = %v for a blade that's off, want both", slot.Watchdog, slot.SelfTest)
+	}
+	if slot.AmbientTemp != nil || slot.Sensors != nil || slot.Power != nil {
+		t.Errorf("read sensors for a blade that's off: %+v", slot)
+	}
+	bmc.SetPowerOn(true)
+
+	// Failures are recorded by source, and don't stop us reading the rest.
+	bmc.Fail(ipmisim.GetSensorReading, ipmisim.CompletionNodeBusy)
+	bmc.Handle(getPowerReading, func(data []byte) (uint8, []byte) {
+		return ipmisim.CompletionOK, []byte{0xdc, 0x2c, 0x01, 0xb4, 0x00, 0xc2, 0x01, 0x18, 0x01, 0, 0, 0, 0x60, 0xe8, 0x03, 0, 0, 0x00}
+	})
+	slot = &Slot{Number: 1, PowerState: "ON", IDRACIP: net.ParseIP(bmc.Host)}
+	r.ReadBlade(context.Background(), slot)
+	for _, src := range []string{SourceAmbientTemp, SourcePower} {
+		if slot.Err(src) == nil {
+			t.Errorf("no %s error with a failing BMC", src)
+		}
+	}
+	if slot.Err(SourcePowerState) != nil || slot.Firmware != "1.00" {
+		t.Errorf("failed to read the rest of the BMC: %v", slot.Errors)
+	}
+}
+
+func TestIPMIReaderFRU(t *testing.T) {
+	mfgDate := time.Date(2010, time.March, 30, 14, 24, 0, 0, time.UTC)
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.SetFRU(&ipmisim.FRU{
+		BoardManufacturer: "DELL",
+		BoardProduct:      "PowerEdge M610",
+		BoardSerial:       "CN1374003T0123",
+		BoardPartNumber:   "0N582M",
+		ManufactureDate:   mfgDate,
+		ProductName:       "PowerEdge M610",
+		ProductSerial:     "ABC1234",
+	})
+
+	c := ipmi.New("root", "calvin",
+		ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { c.Close() })
+	r := NewIPMIReader(c)
+
+	slot := &Slot{Number: 1, PowerState: "ON", IDRACIP: net.ParseIP(bmc.Host)}
+	r.ReadFRU(context.Background(), slot)
+	want := &FRU{
+		BoardManufacturer: "DELL",
+		BoardProduct:      "PowerEdge M610",
+		BoardSerial:       "CN1374003T0123",
+		BoardPartNumber:   "0N582M",
+		ManufactureDate:   mfgDate,
+		ProductName:       "PowerEdge M610",
+		ProductSerial:     "ABC1234",
+	}
+	if diff := cmp.Diff(want, slot.FRU); diff != "" {
+		t.Errorf("unexpected FRU (-want +got)\n%s", diff)
+	}
+
+	bmc.Fail(ipmisim.GetFRUInventoryAreaInfo, ipmisim.CompletionNodeBusy)
+	slot = &Slot{Number: 1, PowerState: "ON", IDRACIP: net.ParseIP(bmc.Host)}
+	r.ReadFRU(context.Background(), slot)
+	if slot.FRU != nil || slot.Err(SourceFRU) == nil {
+		t.Errorf("FRU = %+v and errors = %v with a failing BMC, want just an error", slot.FRU, slot.Errors)
+	}
+}
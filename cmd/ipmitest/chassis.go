@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/racadm"
+)
+
+// runChassis prints the same snapshot of the chassis that the exporter
+// collects, reading every blade over IPMI.
+func (c *cli) runChassis() error {
+	cmc, err := c.chassis()
+	if err != nil {
+		return err
+	}
+	ipmiReader := chassis.NewIPMIReader(c.ipmi)
+	reader := chassis.BladeReaderFunc(func(ctx context.Context, s *chassis.Slot) {
+		if host := s.Host(); host != "" {
+			c.setHostCredentials(host, &racadm.ServerPowerInfo{SlotNumber: s.Number, ServerName: s.Name})
+		}
+		ipmiReader.ReadBlade(ctx, s)
+	})
+	ch := chassis.New(cmc, chassis.WithBladeReader(reader), chassis.WithHostTimeout(c.timeout)).
+		Collect(context.Background())
+
+	if c.json {
+		return printJSON(ch)
+	}
+
+	fmt.Printf("%s (%s, service tag %s)\n\n", ch.Name, ch.Model, ch.ServiceTag)
+
+	tw := newTable("SLOT", "NAME", "TYPE", "CMC POWER", "BMC POWER", "IDRAC", "TEMP", "WATTS", "FIRMWARE", "ERRORS")
+	for _, s := range ch.Slots {
+		bmcPower, temp, watts := "-", "-", "-"
+		if s.BMCPowerOn != nil {
+			bmcPower = "OFF"
+			if *s.BMCPowerOn {
+				bmcPower = "ON"
+			}
+		}
+		if s.AmbientTemp != nil {
+			temp = fmt.Sprintf("%gC", *s.AmbientTemp)
+		}
+		if s.Power != nil {
+			watts = fmt.Sprintf("%g", s.Power.Current)
+		}
+		var errs []string
+		for _, err := range s.Errors {
+			errs = append(errs, err.Error())
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			strconv.Itoa(s.Number), s.Name, s.BladeType, s.PowerState, bmcPower,
+			orDash(s.Host()), temp, watts, orDash(s.Firmware), strings.Join(errs, "; "),
+		}, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	tw = newTable("SENSOR", "STATUS", "READING")
+	for _, s := range append(append([]*chassis.Sensor{}, ch.AmbientTemps...), ch.Fans...) {
+		fmt.Fprintf(tw, "%s\t%s\t%g %s\n", s.Name, s.Status, s.Reading, s.Units)
+	}
+	for _, p := range ch.PSUs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Name, p.Status, p.Health)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	tw = newTable("MODULE", "NAME", "TYPE", "POWER", "ROLE")
+	for _, m := range ch.IOMs {
+		if !m.Present {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", m.Slot, m.Name, m.Type, m.Power, m.Role)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	tw = newTable("CMC", "ROLE", "FIRMWARE")
+	for _, cm := range ch.CMCs {
+		role := "Standby"
+		if cm.Primary {
+			role = "Primary"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", cm.Location, role, cm.Firmware)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(ch.Errors) > 0 {
+		fmt.Println()
+	}
+	for _, err := range ch.Errors {
+		fmt.Println(err)
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
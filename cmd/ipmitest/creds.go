@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/racadm"
+)
+
+// credsResult is how every set of credentials fared against one blade.
+type credsResult struct {
+	Slot int
+	Name string
+	Host string `json:",omitempty"`
+	// Results are keyed by credentials name, and are "ok", "rejected" or
+	// "unreachable".
+	Results map[string]string `json:",omitempty"`
+	// Configured is the credentials the exporter would try for this blade, in
+	// order.
+	Configured []string `json:",omitempty"`
+	Error      string   `json:",omitempty"`
+}
+
+// runCreds tries every set of credentials in the creds file against every
+// blade the CMC knows about, which is useful after changing a password on
+// some of them.
+func (c *cli) runCreds() error {
+	cmc, err := c.chassis()
+	if err != nil {
+		return err
+	}
+	pb, err := cmc.GetPowerBudgetInfo()
+	if err != nil {
+		return fmt.Errorf("failed to load power budget info: %w", err)
+	}
+
+	all := c.allCredentials()
+	results := make([]*credsResult, len(pb.ServerPowerInfo))
+	var wg sync.WaitGroup
+	for i, s := range pb.ServerPowerInfo {
+		res := &credsResult{Slot: s.SlotNumber, Name: s.ServerName}
+		results[i] = res
+
+		wg.Add(1)
+		go func(s *racadm.ServerPowerInfo) {
+			defer wg.Done()
+			// The racadm client queues commands past the CMC's session limit,
+			// so looking up every blade at once is fine.
+			nic, err := cmc.GetNICConfig(s.SlotNumber)
+			if err != nil {
+				res.Error = fmt.Sprintf("failed to get NIC config: %v", err)
+				return
+			}
+			res.Host = nic.IPAddress.String()
+			for _, cred := range c.hostCredentials(res.Host, s) {
+				res.Configured = append(res.Configured, cred.Name)
+			}
+			res.Configured = append(res.Configured, ipmi.DefaultCredentials)
+			for _, cred := range c.crds.IPMI.FallbackCredentials() {
+				res.Configured = append(res.Configured, cred.Name)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+			defer cancel()
+			res.Results = c.testCredentials(ctx, res.Host, all)
+		}(s)
+	}
+	wg.Wait()
+
+	if c.json {
+		return printJSON(results)
+	}
+
+	headers := []string{"SLOT", "NAME", "HOST"}
+	for _, cred := range all {
+		headers = append(headers, cred.Name)
+	}
+	headers = append(headers, "EXPORTER USES")
+	tw := newTable(headers...)
+	for _, res := range results {
+		row := []string{strconv.Itoa(res.Slot), res.Name, res.Host}
+		if res.Error != "" {
+			row[2] = res.Error
+		}
+		for _, cred := range all {
+			r, ok := res.Results[cred.Name]
+			if !ok {
+				r = "-"
+			}
+			row = append(row, r)
+		}
+		row = append(row, res.uses())
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// allCredentials returns every set of credentials in the creds file, with
+// the blade-specific ones sorted by name.
+func (c *cli) allCredentials() []ipmi.Credentials {
+	out := []ipmi.Credentials{{Name: ipmi.DefaultCredentials, User: c.crds.IPMI.User, Password: c.crds.IPMI.Password}}
+
+	var specific []ipmi.Credentials
+	seen := make(map[string]bool)
+	for _, cred := range c.bladeCreds {
+		// Several blades can share a set of credentials.
+		if seen[cred.Name] {
+			continue
+		}
+		seen[cred.Name] = true
+		specific = append(specific, cred)
+	}
+	sort.Slice(specific, func(i, j int) bool { return specific[i].Name < specific[j].Name })
+	out = append(out, specific...)
+
+	return append(out, c.crds.IPMI.FallbackCredentials()...)
+}
+
+// testCredentials tries each set of credentials against the host in turn. If
+// the host doesn't answer, we don't bother with the rest.
+func (c *cli) testCredentials(ctx context.Context, host string, all []ipmi.Credentials) map[string]string {
+	out := make(map[string]string)
+	for _, cred := range all {
+		err := c.ipmi.TestCredentials(ctx, host, cred)
+		switch {
+		case err == nil:
+			out[cred.Name] = "ok"
+		case ipmi.Unreachable(err):
+			log.Printf("%s didn't answer: %v", host, err)
+			out[cred.Name] = "unreachable"
+			return out
+		default:
+			out[cred.Name] = "rejected"
+		}
+	}
+	return out
+}
+
+// uses returns the credentials the exporter would end up logging in with,
+// which are the first of its configured ones that worked.
+func (res *credsResult) uses() string {
+	for _, name := range res.Configured {
+		if res.Results[name] == "ok" {
+			return name
+		}
+	}
+	return "-"
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bcspragu/m1000e-prom/ipmi"
+)
+
+// check is the result of one diagnose step.
+type check struct {
+	Name string
+	// Status is "ok", "fail", or "skipped" if an earlier check failing means
+	// there's no point trying.
+	Status string
+	Detail string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+// runDiagnose runs everything the exporter does for a blade, roughly in the
+// order it does them, so whichever check fails first is usually why a metric
+// went missing.
+func (c *cli) runDiagnose(ctx context.Context, host string, _ []string) error {
+	var checks []*check
+	failed := false
+	add := func(name, detail string, err error) {
+		ch := &check{Name: name, Status: "ok", Detail: detail}
+		if err != nil {
+			ch.Status, ch.Error = "fail", err.Error()
+			failed = true
+		}
+		checks = append(checks, ch)
+	}
+	skip := func(names ...string) {
+		for _, name := range names {
+			checks = append(checks, &check{Name: name, Status: "skipped"})
+		}
+	}
+
+	if c.blade != nil {
+		var err error
+		if c.blade.PowerState != "ON" {
+			err = fmt.Errorf("power state is %q, so the exporter won't read the blade's temp or sensors", c.blade.PowerState)
+		}
+		add("cmc", fmt.Sprintf("slot %d (%s) is at %s", c.blade.SlotNumber, c.blade.ServerName, host), err)
+	}
+
+	// Everything else needs a session, so we make sure we can get one first.
+	info, err := c.ipmi.DeviceInfo(ctx, host)
+	var detail string
+	if err == nil {
+		detail = fmt.Sprintf("logged in with %s credentials, firmware %s", c.ipmi.AcceptedCredentials(host), info.Firmware)
+	} else if ipmi.Unreachable(err) {
+		err = fmt.Errorf("BMC didn't answer: %w", err)
+	}
+	add("session", detail, err)
+	rest := []string{"power", "temp", "sensors", "sel", "fru", "self-test", "watchdog"}
+	if err != nil {
+		skip(rest...)
+		return c.printChecks(checks, failed)
+	}
+
+	ps, err := c.ipmi.PowerStatus(ctx, host)
+	detail = ""
+	if err == nil {
+		detail = fmt.Sprintf("on: %t", ps.On)
+		switch {
+		case ps.Fault || ps.ControlFault:
+			err = errors.New("BMC reports a power fault")
+		case c.blade != nil && ps.On != (c.blade.PowerState == "ON"):
+			err = fmt.Errorf("CMC says power state is %q, but BMC says on: %t", c.blade.PowerState, ps.On)
+		}
+	}
+	add("power", detail, err)
+
+	temp, err := c.ipmi.AmbientTemp(ctx, host)
+	detail = ""
+	if err == nil {
+		detail = fmt.Sprintf("%g degrees C", temp)
+	}
+	add("temp", detail, err)
+
+	sensors, err := c.ipmi.Sensors(ctx, host)
+	detail = ""
+	if err == nil {
+		var bad []string
+		for _, s := range sensors {
+			if s.HasReading && s.Unit != "discrete" && s.Status != "ok" {
+				bad = append(bad, fmt.Sprintf("%s (%s)", s.Name, s.Status))
+			}
+		}
+		detail = fmt.Sprintf("%d sensors", len(sensors))
+		if len(bad) > 0 {
+			err = fmt.Errorf("past thresholds: %s", strings.Join(bad, ", "))
+		}
+	}
+	add("sensors", detail, err)
+
+	events, err := c.ipmi.NewEvents(ctx, host)
+	detail = ""
+	if err == nil {
+		critical := 0
+		for _, e := range events {
+			if e.Severity == ipmi.SeverityCritical && !e.Deassertion {
+				critical++
+			}
+		}
+		// Old critical events aren't a failure, they might be long fixed.
+		detail = fmt.Sprintf("%d events, %d critical", len(events), critical)
+	}
+	add("sel", detail, err)
+
+	fru, err := c.ipmi.FRU(ctx, host)
+	detail = ""
+	if err == nil {
+		detail = fmt.Sprintf("%s, service tag %s", fru.ProductName, fru.ProductSerial)
+	}
+	add("fru", detail, err)
+
+	st, err := c.ipmi.SelfTest(ctx, host)
+	detail = ""
+	if err == nil {
+		detail = selfTestSummary(st)
+		if !st.Passed {
+			err = errors.New("BMC self test failed")
+		}
+	}
+	add("self-test", detail, err)
+
+	wd, err := c.ipmi.Watchdog(ctx, host)
+	detail = ""
+	if err == nil {
+		detail = watchdogSummary(wd)
+	}
+	add("watchdog", detail, err)
+
+	return c.printChecks(checks, failed)
+}
+
+func (c *cli) printChecks(checks []*check, failed bool) error {
+	if c.json {
+		if err := printJSON(checks); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, ch := range checks {
+			msg := ch.Detail
+			if ch.Error != "" {
+				if msg != "" {
+					msg += ": "
+				}
+				msg += ch.Error
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", ch.Name, strings.ToUpper(ch.Status), msg)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return errors.New("some checks failed")
+	}
+	return nil
+}
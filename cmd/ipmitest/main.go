@@ -1,13 +1,25 @@
+// Command ipmitest diagnoses blades over IPMI, using the same credentials file
+// as the exporter. It's what to run when a blade's metrics go missing.
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/bcspragu/m1000e-prom/internal/config"
 	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/racadm"
 )
 
 func main() {
@@ -20,19 +32,64 @@ type creds struct {
 	User     string
 	Password string
 	Addr     string
-	IPMI     *ipmiCreds
+	IPMI     *config.IPMICreds
 }
 
-type ipmiCreds struct {
-	User     string
-	Password string
+const usage = `usage: %s [flags] <path to creds file> <command> [args]
+
+Hosts can be given as an iDRAC IP address, or as a slot number, which is
+looked up on the CMC.
+
+Commands:
+  diagnose <host>                   Run every check below, and say which ones fail
+  temp <host>                       Print the host's ambient temp
+  sensors <host>                    List every sensor and its reading
+  sel <host>                        Dump the System Event Log
+  fru <host>                        Show FRU inventory data
+  info <host>                       Show BMC device info, self test results and watchdog state
+  power <host>                      Show power status and DCMI power readings
+  creds                             Test each set of credentials against every blade in the chassis
+  chassis                           Show everything the CMC and the blades' BMCs report, like the exporter's /chassis
+  raw <host> <netfn> <cmd> [data]   Send a raw IPMI request and print the response, e.g. raw 10.0.0.5 0x06 0x01
+  oem <host> [name]                 Run a decoded OEM command, or list them
+
+Flags:
+`
+
+// cli is everything a command needs.
+type cli struct {
+	crds    creds
+	ipmi    *ipmi.Client
+	json    bool
+	timeout time.Duration
+
+	// cmc is only dialed if a command needs it, see chassis.
+	cmc *racadm.Client
+	// bladeCreds are credentials for specific blades, keyed by slot number,
+	// server name or IP address.
+	bladeCreds map[string]ipmi.Credentials
+	// blade is what the CMC says about the blade we're looking at, if it was
+	// given by slot number.
+	blade *racadm.ServerPowerInfo
 }
 
 func run(args []string) error {
-	if len(args) < 3 {
-		return fmt.Errorf("usage: ./ipmitest <path to creds file> <host ip>")
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, "Print output as JSON instead of tables.")
+	timeout := fs.Duration("timeout", 30*time.Second, "How long to spend on each blade.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), usage, args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errors.New("not enough arguments")
 	}
-	dat, err := ioutil.ReadFile(args[1])
+
+	dat, err := ioutil.ReadFile(fs.Arg(0))
 	if err != nil {
 		return fmt.Errorf("failed to read creds file: %w", err)
 	}
@@ -40,16 +97,385 @@ func run(args []string) error {
 	if err := json.Unmarshal(dat, &crds); err != nil {
 		return fmt.Errorf("failed to unmarshal credentials: %w", err)
 	}
+	if crds.IPMI == nil {
+		return errors.New("creds file has no ipmi section")
+	}
 
-	c := ipmi.New(crds.IPMI.User, crds.IPMI.Password)
+	opts, err := crds.IPMI.Options()
+	if err != nil {
+		return fmt.Errorf("invalid IPMI config: %w", err)
+	}
+	c := &cli{
+		crds:       crds,
+		ipmi:       ipmi.New(crds.IPMI.User, crds.IPMI.Password, opts...),
+		json:       *jsonOut,
+		timeout:    *timeout,
+		bladeCreds: crds.IPMI.BladeCredentials(),
+	}
 	defer func() {
-		if err := c.Close(); err != nil {
+		if err := c.ipmi.Close(); err != nil {
 			log.Printf("error closing session: %v", err)
 		}
+		if c.cmc != nil {
+			c.cmc.Close()
+		}
 	}()
 
-	temp, err := c.AmbientTemp(args[2], 623)
-	fmt.Println(temp, err)
+	cmd, rest := fs.Arg(1), fs.Args()[2:]
+	if net.ParseIP(cmd) != nil {
+		// The old form, <creds> <host> [mode] [args], from before there were
+		// commands.
+		host := cmd
+		cmd = "temp"
+		if len(rest) > 0 {
+			cmd, rest = rest[0], rest[1:]
+		}
+		rest = append([]string{host}, rest...)
+	}
+
+	switch cmd {
+	case "creds":
+		return c.runCreds()
+	case "chassis":
+		return c.runChassis()
+	}
+
+	commands := map[string]func(ctx context.Context, host string, args []string) error{
+		"diagnose": c.runDiagnose,
+		"temp":     c.runTemp,
+		"sensors":  c.runSensors,
+		"sel":      c.runSEL,
+		"fru":      c.runFRU,
+		"info":     c.runInfo,
+		"power":    c.runPower,
+		"raw":      c.runRaw,
+		"oem":      c.runOEM,
+	}
+	fn, ok := commands[cmd]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	if len(rest) < 1 {
+		fs.Usage()
+		return fmt.Errorf("%s needs a host", cmd)
+	}
+
+	host, err := c.resolveHost(rest[0])
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return fn(ctx, host, rest[1:])
+}
+
+// chassis connects to the CMC, if we haven't already.
+func (c *cli) chassis() (*racadm.Client, error) {
+	if c.cmc != nil {
+		return c.cmc, nil
+	}
+	if c.crds.Addr == "" {
+		return nil, errors.New("creds file has no CMC address")
+	}
+	cmc, err := racadm.Dial(c.crds.User, c.crds.Password, c.crds.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to CMC: %w", err)
+	}
+	c.cmc = cmc
+	return cmc, nil
+}
 
+// resolveHost turns a slot number into its iDRAC's IP address, by asking the
+// CMC. Anything else is assumed to be a host already. Either way, the host
+// gets any credentials that are specifically for it.
+func (c *cli) resolveHost(arg string) (string, error) {
+	slot, err := strconv.Atoi(arg)
+	if err != nil {
+		c.setHostCredentials(arg, nil)
+		return arg, nil
+	}
+
+	cmc, err := c.chassis()
+	if err != nil {
+		return "", fmt.Errorf("can't look up slot %d: %w", slot, err)
+	}
+	nic, err := cmc.GetNICConfig(slot)
+	if err != nil {
+		return "", fmt.Errorf("failed to get NIC config for slot %d: %w", slot, err)
+	}
+	host := nic.IPAddress.String()
+
+	var blade *racadm.ServerPowerInfo
+	if pb, err := cmc.GetPowerBudgetInfo(); err != nil {
+		log.Printf("failed to load power budget info, so credentials for slot %d's server name won't be used: %v", slot, err)
+	} else {
+		for _, s := range pb.ServerPowerInfo {
+			if s.SlotNumber == slot {
+				blade = s
+			}
+		}
+	}
+	if blade == nil {
+		blade = &racadm.ServerPowerInfo{SlotNumber: slot}
+	}
+	c.blade = blade
+	c.setHostCredentials(host, blade)
+	log.Printf("slot %d is at %s", slot, host)
+	return host, nil
+}
+
+// setHostCredentials gives the host any credentials that are specifically
+// for it, like the exporter does. The blade is optional.
+func (c *cli) setHostCredentials(host string, blade *racadm.ServerPowerInfo) {
+	c.ipmi.SetHostCredentials(host, c.hostCredentials(host, blade)...)
+}
+
+func (c *cli) hostCredentials(host string, blade *racadm.ServerPowerInfo) []ipmi.Credentials {
+	keys := []string{host}
+	if blade != nil {
+		keys = append(keys, blade.ServerName, strconv.Itoa(blade.SlotNumber))
+	}
+	var out []ipmi.Credentials
+	for _, key := range keys {
+		if cred, ok := c.bladeCreds[key]; ok && key != "" {
+			out = append(out, cred)
+		}
+	}
+	return out
+}
+
+func (c *cli) runTemp(ctx context.Context, host string, _ []string) error {
+	temp, err := c.ipmi.AmbientTemp(ctx, host)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(temp)
+	}
+	fmt.Printf("%g\n", temp)
 	return nil
 }
+
+func (c *cli) runSensors(ctx context.Context, host string, _ []string) error {
+	sensors, err := c.ipmi.Sensors(ctx, host)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(sensors)
+	}
+	tw := newTable("NUMBER", "NAME", "TYPE", "READING", "UNIT", "STATUS")
+	for _, s := range sensors {
+		reading := "-"
+		if s.HasReading {
+			reading = strconv.FormatFloat(s.Value, 'g', -1, 64)
+		}
+		fmt.Fprintf(tw, "%#02x\t%s\t%s\t%s\t%s\t%s\n", s.Number, s.Name, s.Type, reading, s.Unit, s.Status)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) runSEL(ctx context.Context, host string, _ []string) error {
+	// The first read of a host's SEL gets everything in it.
+	events, err := c.ipmi.NewEvents(ctx, host)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(events)
+	}
+	tw := newTable("ID", "TIME", "SEVERITY", "SENSOR", "DESCRIPTION")
+	for _, e := range events {
+		desc := e.Description
+		if e.Deassertion {
+			desc += " (deasserted)"
+		}
+		fmt.Fprintf(tw, "%#04x\t%s\t%s\t%s #%#02x\t%s\n", e.RecordID, e.Timestamp.Format(time.RFC3339), e.Severity, e.SensorType, e.SensorNumber, desc)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) runFRU(ctx context.Context, host string, _ []string) error {
+	fru, err := c.ipmi.FRU(ctx, host)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(fru)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Board manufacturer\t%s\n", fru.BoardManufacturer)
+	fmt.Fprintf(tw, "Board product\t%s\n", fru.BoardProduct)
+	fmt.Fprintf(tw, "Board serial\t%s\n", fru.BoardSerial)
+	fmt.Fprintf(tw, "Board part number\t%s\n", fru.BoardPartNumber)
+	fmt.Fprintf(tw, "Manufactured\t%s\n", fru.ManufactureDate.Format("2006-01-02"))
+	fmt.Fprintf(tw, "Product name\t%s\n", fru.ProductName)
+	fmt.Fprintf(tw, "Product serial (service tag)\t%s\n", fru.ProductSerial)
+	fmt.Fprintf(tw, "Asset tag\t%s\n", fru.AssetTag)
+	return tw.Flush()
+}
+
+func (c *cli) runInfo(ctx context.Context, host string, _ []string) error {
+	// Each of these is useful on its own, so we show whatever we can get.
+	var out struct {
+		Device   *ipmi.DeviceInfo `json:",omitempty"`
+		SelfTest *ipmi.SelfTest   `json:",omitempty"`
+		Watchdog *ipmi.Watchdog   `json:",omitempty"`
+		Errors   []string         `json:",omitempty"`
+	}
+	var errs []error
+	var err error
+	if out.Device, err = c.ipmi.DeviceInfo(ctx, host); err != nil {
+		errs = append(errs, err)
+	}
+	if out.SelfTest, err = c.ipmi.SelfTest(ctx, host); err != nil {
+		errs = append(errs, err)
+	}
+	if out.Watchdog, err = c.ipmi.Watchdog(ctx, host); err != nil {
+		errs = append(errs, err)
+	}
+	for _, err := range errs {
+		out.Errors = append(out.Errors, err.Error())
+	}
+
+	if c.json {
+		if err := printJSON(out); err != nil {
+			return err
+		}
+		return errors.Join(errs...)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if d := out.Device; d != nil {
+		fmt.Fprintf(tw, "Device ID\t%#02x (revision %d)\n", d.DeviceID, d.DeviceRevision)
+		fmt.Fprintf(tw, "Firmware\t%s\n", d.Firmware)
+		fmt.Fprintf(tw, "IPMI version\t%s\n", d.IPMIVersion)
+		fmt.Fprintf(tw, "Manufacturer ID\t%d\n", d.ManufacturerID)
+		fmt.Fprintf(tw, "Product ID\t%#04x\n", d.ProductID)
+		fmt.Fprintf(tw, "Available\t%t\n", d.Available)
+	}
+	if st := out.SelfTest; st != nil {
+		fmt.Fprintf(tw, "Self test\t%s\n", selfTestSummary(st))
+	}
+	if wd := out.Watchdog; wd != nil {
+		fmt.Fprintf(tw, "Watchdog\t%s\n", watchdogSummary(wd))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func selfTestSummary(st *ipmi.SelfTest) string {
+	switch {
+	case !st.Implemented:
+		return "not implemented"
+	case st.Passed:
+		return "passed"
+	}
+	return "failed: " + strings.Join(st.Failures, ", ")
+}
+
+func watchdogSummary(wd *ipmi.Watchdog) string {
+	use := wd.Use
+	if use == "" {
+		use = "never set up"
+	}
+	state := "stopped"
+	if wd.Running {
+		state = fmt.Sprintf("running, %s of %s left", wd.Remaining, wd.Initial)
+	}
+	out := fmt.Sprintf("%s (%s), action %s", state, use, wd.Action)
+	if len(wd.Expired) > 0 {
+		out += ", expired for " + strings.Join(wd.Expired, ", ")
+	}
+	return out
+}
+
+func (c *cli) runPower(ctx context.Context, host string, _ []string) error {
+	ps, err := c.ipmi.PowerStatus(ctx, host)
+	if err != nil {
+		return err
+	}
+	// Not every BMC does DCMI, so this is just a bonus.
+	reading, rerr := c.ipmi.PowerReading(ctx, host)
+	if rerr != nil {
+		log.Printf("no DCMI power reading: %v", rerr)
+	}
+
+	if c.json {
+		return printJSON(struct {
+			Status  *ipmi.PowerStatus
+			Reading *ipmi.PowerReading `json:",omitempty"`
+		}{ps, reading})
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Power on\t%t\n", ps.On)
+	fmt.Fprintf(tw, "Power fault\t%t\n", ps.Fault)
+	fmt.Fprintf(tw, "Power control fault\t%t\n", ps.ControlFault)
+	fmt.Fprintf(tw, "Overload\t%t\n", ps.Overload)
+	fmt.Fprintf(tw, "Interlock\t%t\n", ps.Interlock)
+	fmt.Fprintf(tw, "Restore policy\t%s\n", ps.RestorePolicy)
+	if reading != nil && reading.Active {
+		fmt.Fprintf(tw, "Power draw\t%d W (min %d W, max %d W, average %d W over %s)\n",
+			reading.CurrentWatts, reading.MinWatts, reading.MaxWatts, reading.AverageWatts, reading.Period)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) runRaw(ctx context.Context, host string, args []string) error {
+	if len(args) < 2 {
+		return errors.New("raw needs at least a netfn and a command")
+	}
+	// Bytes can be given in any base Go understands, like ipmitool takes them.
+	bs := make([]byte, len(args))
+	for i, arg := range args {
+		b, err := strconv.ParseUint(arg, 0, 8)
+		if err != nil {
+			return fmt.Errorf("invalid byte %q: %w", arg, err)
+		}
+		bs[i] = byte(b)
+	}
+
+	resp, err := c.ipmi.Raw(ctx, host, bs[0], bs[1], bs[2:])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(resp)
+	}
+	fmt.Printf("% x\n", resp)
+	return nil
+}
+
+func (c *cli) runOEM(ctx context.Context, host string, args []string) error {
+	if len(args) == 0 {
+		for _, cmd := range ipmi.OEMCommands() {
+			fmt.Printf("%s\t%s\n", cmd.Name, cmd.Description)
+		}
+		return nil
+	}
+
+	out, err := c.ipmi.OEM(ctx, host, args[0])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(out)
+	}
+	fmt.Printf("%+v\n", out)
+	return nil
+}
+
+func newTable(headers ...string) *tabwriter.Writer {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	return tw
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+)
+
+// sseKeepAlive is how often we send a comment to idle /events clients, so
+// proxies don't time out the connection between events.
+const sseKeepAlive = 30 * time.Second
+
+// eventsHandler streams the events between successive chassis snapshots as
+// Server-Sent Events, named for their type, with the event as JSON.
+type eventsHandler struct {
+	watcher   *chassis.Watcher
+	keepAlive time.Duration
+}
+
+func newEventsHandler(w *chassis.Watcher) *eventsHandler {
+	return &eventsHandler{watcher: w, keepAlive: sseKeepAlive}
+}
+
+func (h *eventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+	events, stop := h.watcher.Subscribe()
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	t := time.NewTicker(h.keepAlive)
+	defer t.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				// The exporter is shutting down.
+				return
+			}
+			dat, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("failed to marshal event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, dat); err != nil {
+				return
+			}
+		case <-t.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
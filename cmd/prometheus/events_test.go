@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/racadm"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestEventsHandler(t *testing.T) {
+	m, err := newMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin")
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	// We don't know where the blade's BMC is, so all we get is the CMC's view
+	// of it.
+	blade := &racadm.ServerPowerInfo{SlotNumber: 1, ServerName: "web-1", PowerState: "ON", BladeType: "PowerEdgeM610"}
+	mc := &metricClient{
+		client:          &fakeChassis{blades: []*racadm.ServerPowerInfo{blade}},
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		ipmiWorkers:     1,
+		ipmiHostTimeout: 10 * time.Second,
+		events:          chassis.NewWatcher(),
+	}
+
+	h := newEventsHandler(mc.events)
+	h.keepAlive = 10 * time.Millisecond
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to connect to /events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	mc.updateMetrics()
+	blade.PowerState = "OFF"
+	mc.updateMetrics()
+
+	sc := bufio.NewScanner(resp.Body)
+	var name, data string
+	keepAlives := 0
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, ": "):
+			keepAlives++
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+		if data != "" {
+			break
+		}
+	}
+	if name != string(chassis.BladePoweredOff) {
+		t.Errorf("event name = %q, want %q", name, chassis.BladePoweredOff)
+	}
+	var e chassis.Event
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		t.Fatalf("failed to unmarshal event %q: %v", data, err)
+	}
+	if e.Type != chassis.BladePoweredOff || e.Slot != 1 || e.Message != "slot 1 (web-1) powered off" {
+		t.Errorf("got event %+v, want slot 1 powered off", e)
+	}
+
+	// Shutting down ends the stream, after a keep-alive or two.
+	time.Sleep(30 * time.Millisecond)
+	mc.events.Close()
+	for sc.Scan() {
+		if strings.HasPrefix(sc.Text(), ": ") {
+			keepAlives++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Errorf("stream ended with an error: %v", err)
+	}
+	if keepAlives == 0 {
+		t.Error("got no keep-alives")
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/racadm"
+)
+
+// idracCreds is how we log in to blades' iDRACs, over SSH for blades that use
+// the racadm backend and over HTTPS for blades that use the redfish backend.
+type idracCreds struct {
+	User     string
+	Password string
+	// Credentials are used instead of the above for matching blades, keyed by
+	// slot number, server name or iDRAC IP address.
+	Credentials map[string]*idracCredentials
+	// Port is the iDRACs' SSH port, 22 by default.
+	Port int
+	// Redfish configures the redfish backend.
+	Redfish *redfishConfig
+}
+
+type idracCredentials struct {
+	User     string
+	Password string
+}
+
+const (
+	defaultIDRACPort = 22
+	// idracDialTimeout is how long we'll wait to connect to an iDRAC, which
+	// can be slow to answer SSH while it's busy.
+	idracDialTimeout = 20 * time.Second
+)
+
+// idrac is what we need from a blade's iDRAC, which is implemented by
+// *racadm.Client.
+type idrac interface {
+	GetIDRACSensorInfo() (*racadm.IDRACSensorInfo, error)
+	Close() error
+}
+
+type idracDialFunc func(user, pass, addr string) (idrac, error)
+
+func dialIDRAC(user, pass, addr string) (idrac, error) {
+	return racadm.Dial(user, pass, addr, racadm.WithDialTimeout(idracDialTimeout))
+}
+
+// idracPool keeps an SSH connection open to the iDRAC of each blade we read
+// with racadm.
+type idracPool struct {
+	dial idracDialFunc
+	port int
+
+	mu sync.Mutex
+	// Keyed by host.
+	conns map[string]*idracConn
+}
+
+type idracConn struct {
+	client idrac
+	// The credentials the client was dialed with, so we can tell if they've
+	// changed.
+	creds idracCredentials
+}
+
+func newIDRACPool(dial idracDialFunc, port int) *idracPool {
+	if port == 0 {
+		port = defaultIDRACPort
+	}
+	return &idracPool{
+		dial:  dial,
+		port:  port,
+		conns: make(map[string]*idracConn),
+	}
+}
+
+// SensorInfo runs `racadm getsensorinfo` on the host's iDRAC, connecting
+// first if we aren't already. If it fails or ctx is done first, we drop the
+// connection, so the next call gets a fresh one.
+func (p *idracPool) SensorInfo(ctx context.Context, host string, creds idracCredentials) (*racadm.IDRACSensorInfo, error) {
+	type result struct {
+		info *racadm.IDRACSensorInfo
+		err  error
+	}
+	// The racadm client doesn't take a context, so we give up on it rather
+	// than wait. Closing it unblocks whatever it's stuck on.
+	done := make(chan result, 1)
+	go func() {
+		cn, err := p.conn(host, creds)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		info, err := cn.client.GetIDRACSensorInfo()
+		if err != nil {
+			p.drop(host, cn)
+		}
+		done <- result{info: info, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.info, res.err
+	case <-ctx.Done():
+		p.mu.Lock()
+		cn := p.conns[host]
+		p.mu.Unlock()
+		if cn != nil {
+			p.drop(host, cn)
+		}
+		return nil, fmt.Errorf("gave up on iDRAC: %w", ctx.Err())
+	}
+}
+
+func (p *idracPool) conn(host string, creds idracCredentials) (*idracConn, error) {
+	p.mu.Lock()
+	cn, ok := p.conns[host]
+	p.mu.Unlock()
+	if ok && cn.creds == creds {
+		return cn, nil
+	}
+	if ok {
+		p.drop(host, cn)
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(p.port))
+	client, err := p.dial(creds.User, creds.Password, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to iDRAC: %w", err)
+	}
+	cn = &idracConn{client: client, creds: creds}
+	p.mu.Lock()
+	p.conns[host] = cn
+	p.mu.Unlock()
+	return cn, nil
+}
+
+// drop closes the connection, if it's still the current one for the host.
+// Otherwise, someone else already has.
+func (p *idracPool) drop(host string, cn *idracConn) {
+	p.mu.Lock()
+	if p.conns[host] != cn {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.conns, host)
+	p.mu.Unlock()
+	if err := cn.client.Close(); err != nil {
+		log.Printf("failed to close connection to iDRAC %s: %v", host, err)
+	}
+}
+
+func (p *idracPool) Close() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = make(map[string]*idracConn)
+	p.mu.Unlock()
+	for host, cn := range conns {
+		if err := cn.client.Close(); err != nil {
+			log.Printf("failed to close connection to iDRAC %s: %v", host, err)
+		}
+	}
+}
+
+// idracCredentials returns the credentials to log in to the given blade's
+// iDRAC with, matching on its IP address, then its server name, then its
+// slot.
+func (mc *metricClient) idracCredentials(s *chassis.Slot) idracCredentials {
+	for _, key := range []string{s.Host(), s.Name, strconv.Itoa(s.Number)} {
+		if cred, ok := mc.idracCreds.Credentials[key]; ok && key != "" {
+			return *cred
+		}
+	}
+	return idracCredentials{User: mc.idracCreds.User, Password: mc.idracCreds.Password}
+}
+
+// readBladeRacadm reads blades that use the racadm backend. We only get what
+// `racadm getsensorinfo` knows about, so there's no SEL, FRU, watchdog or BMC
+// power state.
+func (mc *metricClient) readBladeRacadm(ctx context.Context, s *chassis.Slot) {
+	s.Backend = backendRacadm
+	mc.readBladeFRU(ctx, s, nil)
+
+	host := s.Host()
+	if !s.On() || host == "" {
+		return
+	}
+
+	info, err := mc.idrac.SensorInfo(ctx, host, mc.idracCredentials(s))
+	if err != nil {
+		s.AddError(chassis.SourceSensors, err)
+		return
+	}
+
+	for _, sn := range info.Sensors {
+		if !sn.HasValue {
+			continue
+		}
+		s.Sensors = append(s.Sensors, &chassis.BladeSensor{
+			// Unlike IPMI, the iDRAC's sensor names are unique, and it doesn't
+			// tell us their numbers.
+			Name:   sn.Name,
+			Type:   idracSensorType(sn.Type),
+			Unit:   idracUnit(sn.Units),
+			Value:  sn.Value,
+			Status: sn.Status,
+		})
+
+		switch {
+		case s.AmbientTemp == nil && isIDRACAmbientTemp(sn):
+			temp := sn.Value
+			s.AmbientTemp = &temp
+		case sn.Type == "CURRENT" && sn.Units == "Watts" && strings.Contains(sn.Name, "Pwr Consumption"):
+			// All we get is the current draw, not DCMI's stats.
+			s.Power = &chassis.BladePower{Current: sn.Value}
+		}
+	}
+	if s.AmbientTemp == nil {
+		s.AddError(chassis.SourceAmbientTemp, errors.New("no ambient temp sensor"))
+	}
+}
+
+// isIDRACAmbientTemp reports whether the sensor is the equivalent of the
+// "Ambient Temp" sensor we read over IPMI, which newer iDRACs call the inlet
+// temp.
+func isIDRACAmbientTemp(sn *racadm.IDRACSensor) bool {
+	if sn.Type != "TEMPERATURE" || sn.Units != "C" {
+		return false
+	}
+	return strings.Contains(sn.Name, "Inlet") || strings.Contains(sn.Name, "Ambient")
+}
+
+// idracSensorType maps the iDRAC's sensor types to the names IPMI uses, so
+// m1000e_blade_sensor looks the same whichever backend a blade uses.
+func idracSensorType(t string) string {
+	switch t {
+	case "TEMPERATURE":
+		return "Temperature"
+	case "FAN":
+		return "Fan"
+	case "VOLTAGE":
+		return "Voltage"
+	case "CURRENT":
+		return "Current"
+	}
+	return t
+}
+
+// idracUnit is like idracSensorType, for units.
+func idracUnit(u string) string {
+	switch u {
+	case "C":
+		return "degrees C"
+	case "F":
+		return "degrees F"
+	case "RPM":
+		return "RPM"
+	case "V":
+		return "Volts"
+	case "A", "Amps":
+		return "Amps"
+	case "W", "Watts":
+		return "Watts"
+	case "%":
+		return "percent"
+	}
+	return u
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/bcspragu/m1000e-prom/racadm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeIDRAC answers getsensorinfo with whatever it's told to.
+type fakeIDRAC struct {
+	mu     sync.Mutex
+	info   *racadm.IDRACSensorInfo
+	err    error
+	closed bool
+}
+
+func (fi *fakeIDRAC) GetIDRACSensorInfo() (*racadm.IDRACSensorInfo, error) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.info, fi.err
+}
+
+func (fi *fakeIDRAC) Close() error {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if fi.closed {
+		return errors.New("already closed")
+	}
+	fi.closed = true
+	return nil
+}
+
+func (fi *fakeIDRAC) set(info *racadm.IDRACSensorInfo, err error) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.info, fi.err = info, err
+}
+
+// fakeIDRACs hands out fakeIDRACs, keyed by address.
+type fakeIDRACs struct {
+	mu     sync.Mutex
+	dials  []string
+	idracs map[string]*fakeIDRAC
+	info   *racadm.IDRACSensorInfo
+}
+
+func (fs *fakeIDRACs) dial(user, pass, addr string) (idrac, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if user != "root" || pass != "calvin" {
+		return nil, errors.New("bad credentials")
+	}
+	fs.dials = append(fs.dials, addr)
+	fi := &fakeIDRAC{info: fs.info}
+	fs.idracs[addr] = fi
+	return fi, nil
+}
+
+func (fs *fakeIDRACs) get(addr string) *fakeIDRAC {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.idracs[addr]
+}
+
+func (fs *fakeIDRACs) dialCount() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.dials)
+}
+
+func TestRacadmBackend(t *testing.T) {
+	// Slot 1 is read over IPMI, slot 2 with racadm.
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 21)
+
+	reg := prometheus.NewRegistry()
+	m, err := newMetrics(reg)
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin",
+		ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	idracs := &fakeIDRACs{
+		idracs: make(map[string]*fakeIDRAC),
+		info: &racadm.IDRACSensorInfo{
+			Sensors: []*racadm.IDRACSensor{
+				{Type: "POWER", Name: "System Board PS Redundancy", Status: "Full Redundant"},
+				{Type: "TEMPERATURE", Name: "System Board Inlet Temp", Status: "Ok", Reading: "23C", Value: 23, Units: "C", HasValue: true},
+				{Type: "TEMPERATURE", Name: "CPU1 Temp", Status: "Ok", Reading: "47C", Value: 47, Units: "C", HasValue: true},
+				{Type: "CURRENT", Name: "System Board Pwr Consumption", Status: "Ok", Reading: "126Watts", Value: 126, Units: "Watts", HasValue: true},
+			},
+		},
+	}
+	pool := newIDRACPool(idracs.dial, 0)
+	t.Cleanup(pool.Close)
+
+	blade := &racadm.ServerPowerInfo{SlotNumber: 2, ServerName: "db-1", PowerState: "ON", BladeType: "PowerEdgeM630"}
+	mc := &metricClient{
+		client: &fakeChassis{
+			blades: []*racadm.ServerPowerInfo{
+				{SlotNumber: 1, ServerName: "web-1", PowerState: "ON", BladeType: "PowerEdgeM610"},
+				blade,
+			},
+			ips: map[int]net.IP{
+				1: net.ParseIP(bmc.Host),
+				2: net.ParseIP("10.0.0.2"),
+			},
+		},
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		events:          chassis.NewWatcher(),
+		ipmiWorkers:     2,
+		ipmiHostTimeout: 10 * time.Second,
+		bladeBackends:   map[string]string{"db-1": backendRacadm},
+		idrac:           pool,
+		idracCreds: idracCreds{
+			User:        "admin",
+			Password:    "hunter2",
+			Credentials: map[string]*idracCredentials{"2": {User: "root", Password: "calvin"}},
+		},
+	}
+
+	labels := func(slot, name, bladeType string) prometheus.Labels {
+		return prometheus.Labels{"slot_number": slot, "name": name, "power_state": "ON", "blade_type": bladeType}
+	}
+	mc.updateMetrics()
+	if got := testutil.ToFloat64(m.serverTemp.With(labels("1", "web-1", "PowerEdgeM610"))); got != 21 {
+		t.Errorf("IPMI blade temp = %g, want 21", got)
+	}
+	if got := testutil.ToFloat64(m.serverTemp.With(labels("2", "db-1", "PowerEdgeM630"))); got != 23 {
+		t.Errorf("racadm blade temp = %g, want 23", got)
+	}
+	sensorLabels := prometheus.Labels{"slot": "2", "sensor": "CPU1 Temp", "type": "Temperature", "unit": "degrees C"}
+	if got := testutil.ToFloat64(m.bladeSensor.With(sensorLabels)); got != 47 {
+		t.Errorf("racadm CPU1 temp = %g, want 47", got)
+	}
+	if got := testutil.ToFloat64(m.bladePower.With(prometheus.Labels{"slot": "2", "stat": "current"})); got != 126 {
+		t.Errorf("racadm blade power = %g, want 126", got)
+	}
+	// The racadm blade doesn't get anything IPMI-only.
+	if n := testutil.CollectAndCount(m.bladeCreds); n != 1 {
+		t.Errorf("got %d IPMI credentials metrics, want just slot 1's", n)
+	}
+	if n := testutil.CollectAndCount(m.powerOn); n != 3 {
+		t.Errorf("got %d power states, want both CMC's and slot 1's BMC's", n)
+	}
+
+	// The connection is reused.
+	mc.updateMetrics()
+	if n := idracs.dialCount(); n != 1 {
+		t.Errorf("dialed iDRACs %d times, want 1", n)
+	}
+
+	// If the iDRAC stops answering, we stop reporting its temp, and reconnect
+	// next time.
+	idracs.get("10.0.0.2:22").set(nil, errors.New("connection reset"))
+	mc.updateMetrics()
+	if n := testutil.CollectAndCount(m.serverTemp); n != 1 {
+		t.Errorf("got %d blade temps with a failing iDRAC, want 1", n)
+	}
+	if !idracs.get("10.0.0.2:22").closed {
+		t.Error("failing iDRAC connection wasn't closed")
+	}
+	mc.updateMetrics()
+	if got := testutil.ToFloat64(m.serverTemp.With(labels("2", "db-1", "PowerEdgeM630"))); got != 23 {
+		t.Errorf("racadm blade temp after reconnecting = %g, want 23", got)
+	}
+	if n := idracs.dialCount(); n != 2 {
+		t.Errorf("dialed iDRACs %d times, want 2", n)
+	}
+
+	blade.PowerState = "OFF"
+	mc.updateMetrics()
+	if n := testutil.CollectAndCount(m.bladeSensor); n != 1 {
+		t.Errorf("got %d blade sensors, want just slot 1's", n)
+	}
+}
+
+func TestIDRACPoolTimeout(t *testing.T) {
+	hung := make(chan struct{})
+	defer close(hung)
+	pool := newIDRACPool(func(user, pass, addr string) (idrac, error) {
+		<-hung
+		return nil, errors.New("gave up")
+	}, 0)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.SensorInfo(ctx, "10.0.0.2", idracCredentials{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SensorInfo returned %v, want a deadline exceeded error", err)
+	}
+}
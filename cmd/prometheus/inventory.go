@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FRU data only changes when someone swaps hardware, and reading it takes a
+// bunch of round trips to the BMC.
+const fruTTL = 6 * time.Hour
+
+// inventory holds the hardware inventory for each blade, combining what the
+// CMC knows about the slot with FRU data from the blade's BMC.
+type inventory struct {
+	mu     sync.Mutex
+	blades map[int]*bladeInventory
+}
+
+type bladeInventory struct {
+	Slot       int
+	ServerName string
+	BladeType  string
+	PowerState string
+	IPAddress  string
+	FRU        *chassis.FRU
+	// FRULoadedAt is when we last read the FRU data. We keep the last FRU data
+	// we read for blades that are powered off, since it doesn't change.
+	FRULoadedAt time.Time
+}
+
+func newInventory() *inventory {
+	return &inventory{blades: make(map[int]*bladeInventory)}
+}
+
+// needsFRU reports whether we should (re)load FRU data for the blade in the
+// given slot.
+func (inv *inventory) needsFRU(s *chassis.Slot, host string) bool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	b, ok := inv.blades[s.Number]
+	return !ok || b.FRU == nil || b.IPAddress != host || b.swapped(s) || time.Since(b.FRULoadedAt) > fruTTL
+}
+
+// swapped reports whether the slot no longer holds the blade we have FRU data
+// for, going by what the CMC says about it. The CMC doesn't know the blade's
+// service tag, so a swap shows up as a change in its type or name.
+func (b *bladeInventory) swapped(s *chassis.Slot) bool {
+	return !s.Present() || b.BladeType != s.BladeType || b.ServerName != s.Name
+}
+
+// update records what the CMC says about the blade, and the FRU data if it's
+// non-nil. Cached FRU data is dropped if the slot is empty, or holds a
+// different blade than it did. It returns the current inventory for the
+// blade.
+func (inv *inventory) update(s *chassis.Slot, host string, fru *chassis.FRU) bladeInventory {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	b, ok := inv.blades[s.Number]
+	if !ok {
+		b = &bladeInventory{Slot: s.Number}
+		inv.blades[s.Number] = b
+	}
+	if b.swapped(s) {
+		b.IPAddress, b.FRU, b.FRULoadedAt = "", nil, time.Time{}
+	}
+	b.ServerName = s.Name
+	b.BladeType = s.BladeType
+	b.PowerState = s.PowerState
+	if host != "" {
+		b.IPAddress = host
+	}
+	if fru != nil {
+		b.FRU = fru
+		b.FRULoadedAt = time.Now()
+	}
+	return *b
+}
+
+func (inv *inventory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	inv.mu.Lock()
+	blades := make([]bladeInventory, 0, len(inv.blades))
+	for _, b := range inv.blades {
+		blades = append(blades, *b)
+	}
+	inv.mu.Unlock()
+
+	sort.Slice(blades, func(i, j int) bool { return blades[i].Slot < blades[j].Slot })
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(blades); err != nil {
+		log.Printf("failed to write inventory: %v", err)
+	}
+}
+
+// readBladeFRU fills in the blade's FRU data from the inventory, reading it
+// with r if we don't have fresh data for the blade. Only IPMI reads the FRU,
+// so r is nil for other backends, which get whatever we last read over IPMI.
+func (mc *metricClient) readBladeFRU(ctx context.Context, s *chassis.Slot, r *chassis.IPMIReader) {
+	host := s.Host()
+	if r == nil || !s.On() {
+		host = ""
+	}
+	if host != "" && mc.inventory.needsFRU(s, host) {
+		r.ReadFRU(ctx, s)
+	}
+	s.FRU = mc.inventory.update(s, host, s.FRU).FRU
+}
+
+func (mc *metricClient) updateBladeInfo(s *chassis.Slot, slot string) {
+	mc.metrics.bladeInfo.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	if s.FRU == nil {
+		return
+	}
+	mc.metrics.bladeInfo.With(prometheus.Labels{
+		"slot":             slot,
+		"blade_type":       s.BladeType,
+		"manufacturer":     s.FRU.BoardManufacturer,
+		"product":          s.FRU.BoardProduct,
+		"serial":           s.FRU.BoardSerial,
+		"part_number":      s.FRU.BoardPartNumber,
+		"service_tag":      s.FRU.ProductSerial,
+		"manufacture_date": s.FRU.ManufactureDate.Format("2006-01-02"),
+	}).Set(1)
+}
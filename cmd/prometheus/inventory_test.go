@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/bcspragu/m1000e-prom/racadm"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInventory(t *testing.T) {
+	mfgDate := time.Date(2010, time.March, 30, 14, 24, 0, 0, time.UTC)
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.SetFRU(&ipmisim.FRU{
+		BoardManufacturer: "DELL",
+		BoardProduct:      "PowerEdge M610",
+		BoardSerial:       "CN1374003T0123",
+		BoardPartNumber:   "0N582M",
+		ManufactureDate:   mfgDate,
+		ProductName:       "PowerEdge M610",
+		ProductSerial:     "ABC1234",
+	})
+
+	m, err := newMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin",
+		ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	blade := &racadm.ServerPowerInfo{SlotNumber: 1, ServerName: "web-1", PowerState: "ON", BladeType: "PowerEdgeM610"}
+	mc := &metricClient{
+		client: &fakeChassis{
+			blades: []*racadm.ServerPowerInfo{blade},
+			ips:    map[int]net.IP{1: net.ParseIP(bmc.Host)},
+		},
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		events:          chassis.NewWatcher(),
+		ipmiWorkers:     1,
+		ipmiHostTimeout: 10 * time.Second,
+	}
+
+	type bladeFRU struct {
+		Slot       int
+		ServerName string
+		BladeType  string
+		FRU        *struct{ BoardProduct, ProductSerial string }
+	}
+	getInventory := func() []bladeFRU {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mc.inventory.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/inventory", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		var got []bladeFRU
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode inventory: %v", err)
+		}
+		return got
+	}
+	fru := func(product, serviceTag string) *struct{ BoardProduct, ProductSerial string } {
+		return &struct{ BoardProduct, ProductSerial string }{product, serviceTag}
+	}
+	infoLabels := func(bladeType, product, serial, serviceTag string) prometheus.Labels {
+		return prometheus.Labels{
+			"slot":             "1",
+			"blade_type":       bladeType,
+			"manufacturer":     "DELL",
+			"product":          product,
+			"serial":           serial,
+			"part_number":      "0N582M",
+			"service_tag":      serviceTag,
+			"manufacture_date": "2010-03-30",
+		}
+	}
+	checkInfo := func(want prometheus.Labels) {
+		t.Helper()
+		wantCount := 0
+		if want != nil {
+			wantCount = 1
+		}
+		if n := testutil.CollectAndCount(m.bladeInfo); n != wantCount {
+			t.Fatalf("got %d blade info metrics, want %d", n, wantCount)
+		}
+		if want != nil {
+			if got := testutil.ToFloat64(m.bladeInfo.With(want)); got != 1 {
+				t.Errorf("blade info = %g, want 1", got)
+			}
+		}
+	}
+
+	mc.updateMetrics()
+	want := []bladeFRU{{Slot: 1, ServerName: "web-1", BladeType: "PowerEdgeM610", FRU: fru("PowerEdge M610", "ABC1234")}}
+	if diff := cmp.Diff(want, getInventory()); diff != "" {
+		t.Errorf("unexpected inventory (-want +got)\n%s", diff)
+	}
+	checkInfo(infoLabels("PowerEdgeM610", "PowerEdge M610", "CN1374003T0123", "ABC1234"))
+	if n := bmc.Requests(ipmisim.GetFRUInventoryAreaInfo); n != 1 {
+		t.Errorf("read the FRU %d times, want 1", n)
+	}
+
+	// We keep the FRU data for blades that are powered off, and don't read it
+	// again while it's fresh.
+	blade.PowerState = "OFF"
+	mc.updateMetrics()
+	blade.PowerState = "ON"
+	mc.updateMetrics()
+	if diff := cmp.Diff(want, getInventory()); diff != "" {
+		t.Errorf("unexpected inventory after power cycle (-want +got)\n%s", diff)
+	}
+	if n := bmc.Requests(ipmisim.GetFRUInventoryAreaInfo); n != 1 {
+		t.Errorf("read the FRU %d times after a power cycle, want 1", n)
+	}
+
+	// Someone swaps in a different blade, at the same iDRAC address.
+	blade.ServerName, blade.BladeType = "web-2", "PowerEdgeM620"
+	bmc.SetFRU(&ipmisim.FRU{
+		BoardManufacturer: "DELL",
+		BoardProduct:      "PowerEdge M620",
+		BoardSerial:       "CN1374003T0456",
+		BoardPartNumber:   "0N582M",
+		ManufactureDate:   mfgDate,
+		ProductName:       "PowerEdge M620",
+		ProductSerial:     "XYZ9876",
+	})
+	mc.updateMetrics()
+	want = []bladeFRU{{Slot: 1, ServerName: "web-2", BladeType: "PowerEdgeM620", FRU: fru("PowerEdge M620", "XYZ9876")}}
+	if diff := cmp.Diff(want, getInventory()); diff != "" {
+		t.Errorf("unexpected inventory after swapping blades (-want +got)\n%s", diff)
+	}
+	checkInfo(infoLabels("PowerEdgeM620", "PowerEdge M620", "CN1374003T0456", "XYZ9876"))
+
+	// Then pulls it out.
+	blade.ServerName, blade.BladeType, blade.PowerState = "SLOT-01", "N/A", "N/A"
+	mc.updateMetrics()
+	want = []bladeFRU{{Slot: 1, ServerName: "SLOT-01", BladeType: "N/A"}}
+	if diff := cmp.Diff(want, getInventory()); diff != "" {
+		t.Errorf("unexpected inventory for an empty slot (-want +got)\n%s", diff)
+	}
+	checkInfo(nil)
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,6 +13,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/console"
+	"github.com/bcspragu/m1000e-prom/internal/config"
 	"github.com/bcspragu/m1000e-prom/ipmi"
 	"github.com/bcspragu/m1000e-prom/racadm"
 	"github.com/prometheus/client_golang/prometheus"
@@ -30,18 +32,129 @@ type creds struct {
 	User     string
 	Password string
 	Addr     string
-	IPMI     *ipmiCreds
+	IPMI     *config.IPMICreds
+
+	// ShellSession runs all racadm commands through one long-lived shell
+	// session on the CMC, see racadm.WithShellSession.
+	ShellSession bool
+	// MaxSessions limits how many SSH sessions we'll have open on the CMC at
+	// once, see racadm.WithMaxSessions.
+	MaxSessions int
+
+	// Console records the serial consoles of the given blades over IPMI SOL.
+	Console *consoleConfig
+
+	// BladeBackend is how we read blades' temps and sensors by default, see
+	// the backend* constants. BladeBackends overrides it for matching blades,
+	// keyed by slot number, server name or iDRAC IP address.
+	BladeBackend  string
+	BladeBackends map[string]string
+	// IDRAC is how we log in to blades' iDRACs for the racadm and redfish
+	// backends.
+	IDRAC *idracCreds
 }
 
-type ipmiCreds struct {
-	User     string
-	Password string
+// Blade backends, i.e. how we read a blade's temps and sensors.
+const (
+	// backendIPMI talks IPMI to the blade's BMC, which gets us everything.
+	backendIPMI = "ipmi"
+	// backendRacadm runs racadm on the blade's iDRAC over SSH, for blades
+	// that don't have IPMI over LAN enabled.
+	backendRacadm = "racadm"
+	// backendRedfish talks to the blade's iDRAC's Redfish API over HTTPS,
+	// which needs an iDRAC7 or later with 2.x firmware.
+	backendRedfish = "redfish"
+)
+
+func (c *creds) validateBackends() error {
+	check := func(backend string) error {
+		switch backend {
+		case backendIPMI:
+		case backendRacadm, backendRedfish:
+			if c.IDRAC == nil {
+				return fmt.Errorf("the %s backend needs an idrac config", backend)
+			}
+		default:
+			return fmt.Errorf("unknown blade backend %q", backend)
+		}
+		return nil
+	}
+	if c.BladeBackend != "" {
+		if err := check(c.BladeBackend); err != nil {
+			return err
+		}
+	}
+	for key, backend := range c.BladeBackends {
+		if err := check(backend); err != nil {
+			return fmt.Errorf("for blade %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+type consoleConfig struct {
+	// Slots are the blades whose consoles we record.
+	Slots []int
+	// Dir is where the console logs go.
+	Dir string
+	// MaxFileSize is how big a console log gets before it's rotated, in bytes.
+	MaxFileSize int64
+	// MaxFiles is how many rotated logs to keep for each blade.
+	MaxFiles int
+	// Lines is how many lines of each console to keep in memory for /console.
+	Lines int
+	// Takeover takes a blade's console over from any other SOL session that
+	// has it when we connect.
+	Takeover bool
+}
+
+func (cc *consoleConfig) options() []console.Option {
+	var opts []console.Option
+	if cc.MaxFileSize > 0 {
+		opts = append(opts, console.WithMaxFileSize(cc.MaxFileSize))
+	}
+	if cc.MaxFiles > 0 {
+		opts = append(opts, console.WithMaxFiles(cc.MaxFiles))
+	}
+	if cc.Lines > 0 {
+		opts = append(opts, console.WithLines(cc.Lines))
+	}
+	if cc.Takeover {
+		opts = append(opts, console.WithTakeover())
+	}
+	return opts
 }
 
+const (
+	defaultIPMIWorkers     = 4
+	defaultIPMIHostTimeout = 20 * time.Second
+
+	// iDRAC IPs basically never change, but we don't want to hold on to a stale
+	// one forever if they do.
+	nicConfigTTL = time.Hour
+	sysInfoTTL   = 5 * time.Minute
+	// I/O modules only change when someone swaps or reboots one.
+	ioInfoTTL = 5 * time.Minute
+)
+
 type metrics struct {
-	ambientTemp *prometheus.GaugeVec
-	fanRPM      *prometheus.GaugeVec
-	serverTemp  *prometheus.GaugeVec
+	ambientTemp   *prometheus.GaugeVec
+	fanRPM        *prometheus.GaugeVec
+	serverTemp    *prometheus.GaugeVec
+	bladeSensor   *prometheus.GaugeVec
+	selEvents     *prometheus.CounterVec
+	bladePower    *prometheus.GaugeVec
+	bladeInfo     *prometheus.GaugeVec
+	bladeCreds    *prometheus.GaugeVec
+	bmcInfo       *prometheus.GaugeVec
+	powerOn       *prometheus.GaugeVec
+	powerMismatch *prometheus.GaugeVec
+
+	watchdogRunning   *prometheus.GaugeVec
+	watchdogRemaining *prometheus.GaugeVec
+	watchdogExpired   *prometheus.GaugeVec
+	selfTestPassed    *prometheus.GaugeVec
+	selfTestFailure   *prometheus.GaugeVec
 }
 
 func newMetrics(reg prometheus.Registerer) (*metrics, error) {
@@ -67,11 +180,116 @@ func newMetrics(reg prometheus.Registerer) (*metrics, error) {
 			},
 			[]string{"slot_number", "name", "power_state", "blade_type"},
 		),
+		bladeSensor: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_sensor",
+				Help: "Current reading of a sensor on a blade server's BMC, in the given units.",
+			},
+			[]string{"slot", "sensor", "type", "unit"},
+		),
+		selEvents: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "m1000e_blade_sel_events_total",
+				Help: "Number of events logged to a blade server's System Event Log.",
+			},
+			[]string{"slot", "sensor_type", "severity"},
+		),
+		bladePower: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_power_watts",
+				Help: "Power draw of a blade server as reported by its BMC. The min, max and average are over the BMC's sampling period, and aren't reported by the racadm backend.",
+			},
+			[]string{"slot", "stat"},
+		),
+		bladeInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_info",
+				Help: "Hardware inventory of a blade server from its BMC's FRU data, always 1.",
+			},
+			[]string{"slot", "blade_type", "manufacturer", "product", "serial", "part_number", "service_tag", "manufacture_date"},
+		),
+		bladeCreds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_ipmi_credentials",
+				Help: "Which credentials a blade server's BMC last accepted over IPMI, always 1.",
+			},
+			[]string{"slot", "credentials"},
+		),
+		bmcInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_bmc_info",
+				Help: "Firmware revision of a blade server's BMC, from IPMI Get Device ID, always 1.",
+			},
+			[]string{"slot", "firmware"},
+		),
+		powerOn: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_power_on",
+				Help: "Whether a blade server is powered on (1) or off (0), according to the given source, either the CMC or the blade's BMC.",
+			},
+			[]string{"slot", "source"},
+		),
+		powerMismatch: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_power_state_mismatch",
+				Help: "Whether the CMC and a blade server's BMC disagree about the blade's power state, including when the CMC says it's on but the BMC can't be reached.",
+			},
+			[]string{"slot"},
+		),
+		watchdogRunning: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_watchdog_running",
+				Help: "Whether a blade server's BMC watchdog timer is counting down (1) or stopped (0), with what it's set up for and what it'll do when it expires.",
+			},
+			[]string{"slot", "use", "action"},
+		),
+		watchdogRemaining: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_watchdog_remaining_seconds",
+				Help: "How long a blade server's BMC watchdog timer has left before it expires.",
+			},
+			[]string{"slot"},
+		),
+		watchdogExpired: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_watchdog_expired",
+				Help: "Whether a blade server's BMC watchdog timer has expired for the given use since its flags were last cleared.",
+			},
+			[]string{"slot", "use"},
+		),
+		selfTestPassed: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_bmc_self_test_passed",
+				Help: "Whether a blade server's BMC passed its self test (or doesn't have one).",
+			},
+			[]string{"slot"},
+		),
+		selfTestFailure: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "m1000e_blade_bmc_self_test_failure",
+				Help: "What a blade server's BMC self test found wrong, always 1.",
+			},
+			[]string{"slot", "failure"},
+		),
 	}
+
 	cols := []prometheus.Collector{
 		m.ambientTemp,
 		m.fanRPM,
 		m.serverTemp,
+		m.bladeSensor,
+		m.selEvents,
+		m.bladePower,
+		m.bladeInfo,
+		m.bladeCreds,
+		m.bmcInfo,
+		m.powerOn,
+		m.powerMismatch,
+		m.watchdogRunning,
+		m.watchdogRemaining,
+		m.watchdogExpired,
+		m.selfTestPassed,
+		m.selfTestFailure,
 	}
 	for _, col := range cols {
 		if err := reg.Register(col); err != nil {
@@ -81,30 +299,270 @@ func newMetrics(reg prometheus.Registerer) (*metrics, error) {
 	return m, nil
 }
 
+// bladeVecs returns the gauges that have a series per blade. The SEL event
+// counter isn't one of them: we've already moved past the events it counted,
+// so resetting it would lose them for good.
+func (m *metrics) bladeVecs() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{
+		m.serverTemp,
+		m.bladeSensor,
+		m.bladePower,
+		m.bladeInfo,
+		m.bladeCreds,
+		m.bmcInfo,
+		m.powerOn,
+		m.powerMismatch,
+		m.watchdogRunning,
+		m.watchdogRemaining,
+		m.watchdogExpired,
+		m.selfTestPassed,
+		m.selfTestFailure,
+	}
+}
+
+// schedulerCollector exports stats about racadm commands waiting for a session
+// on the CMC.
+type schedulerCollector struct {
+	client *racadm.Client
+
+	maxSessions    *prometheus.Desc
+	activeSessions *prometheus.Desc
+	queueDepth     *prometheus.Desc
+	commands       *prometheus.Desc
+	waitSeconds    *prometheus.Desc
+	maxWaitSeconds *prometheus.Desc
+}
+
+func newSchedulerCollector(c *racadm.Client) *schedulerCollector {
+	return &schedulerCollector{
+		client: c,
+		maxSessions: prometheus.NewDesc(
+			"m1000e_racadm_max_sessions",
+			"Maximum number of concurrent sessions we'll open on the CMC.",
+			nil, nil,
+		),
+		activeSessions: prometheus.NewDesc(
+			"m1000e_racadm_active_sessions",
+			"Number of sessions currently open on the CMC.",
+			nil, nil,
+		),
+		queueDepth: prometheus.NewDesc(
+			"m1000e_racadm_queue_depth",
+			"Number of racadm commands waiting for a session.",
+			[]string{"priority"}, nil,
+		),
+		commands: prometheus.NewDesc(
+			"m1000e_racadm_commands_total",
+			"Number of racadm commands that have been given a session.",
+			[]string{"priority"}, nil,
+		),
+		waitSeconds: prometheus.NewDesc(
+			"m1000e_racadm_queue_wait_seconds_total",
+			"Total time racadm commands have spent waiting for a session.",
+			[]string{"priority"}, nil,
+		),
+		maxWaitSeconds: prometheus.NewDesc(
+			"m1000e_racadm_queue_max_wait_seconds",
+			"Longest time a racadm command has waited for a session.",
+			[]string{"priority"}, nil,
+		),
+	}
+}
+
+func (sc *schedulerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sc.maxSessions
+	ch <- sc.activeSessions
+	ch <- sc.queueDepth
+	ch <- sc.commands
+	ch <- sc.waitSeconds
+	ch <- sc.maxWaitSeconds
+}
+
+func (sc *schedulerCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := sc.client.SchedulerStats()
+	ch <- prometheus.MustNewConstMetric(sc.maxSessions, prometheus.GaugeValue, float64(stats.MaxSessions))
+	ch <- prometheus.MustNewConstMetric(sc.activeSessions, prometheus.GaugeValue, float64(stats.ActiveSessions))
+	for p, ps := range stats.ByPriority {
+		prio := p.String()
+		ch <- prometheus.MustNewConstMetric(sc.queueDepth, prometheus.GaugeValue, float64(ps.Queued), prio)
+		ch <- prometheus.MustNewConstMetric(sc.commands, prometheus.CounterValue, float64(ps.Started), prio)
+		ch <- prometheus.MustNewConstMetric(sc.waitSeconds, prometheus.CounterValue, ps.TotalWait.Seconds(), prio)
+		ch <- prometheus.MustNewConstMetric(sc.maxWaitSeconds, prometheus.GaugeValue, ps.MaxWait.Seconds(), prio)
+	}
+}
+
+// sessionCollector exports the state of our IPMI sessions with each blade's
+// BMC.
+type sessionCollector struct {
+	client *ipmi.Client
+
+	state      *prometheus.Desc
+	reconnects *prometheus.Desc
+	evictions  *prometheus.Desc
+	failures   *prometheus.Desc
+}
+
+func newSessionCollector(c *ipmi.Client) *sessionCollector {
+	return &sessionCollector{
+		client: c,
+		state: prometheus.NewDesc(
+			"m1000e_ipmi_session_state",
+			"State of our IPMI session with a BMC, 1 for the current state and 0 otherwise.",
+			[]string{"host", "state"}, nil,
+		),
+		reconnects: prometheus.NewDesc(
+			"m1000e_ipmi_session_reconnects_total",
+			"Number of times we've opened a new IPMI session with a BMC after the first.",
+			[]string{"host"}, nil,
+		),
+		evictions: prometheus.NewDesc(
+			"m1000e_ipmi_session_evictions_total",
+			"Number of IPMI sessions with a BMC that we've dropped after finding them dead.",
+			[]string{"host"}, nil,
+		),
+		failures: prometheus.NewDesc(
+			"m1000e_ipmi_session_consecutive_failures",
+			"Number of failed attempts to connect to a BMC since the last success.",
+			[]string{"host"}, nil,
+		),
+	}
+}
+
+func (sc *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sc.state
+	ch <- sc.reconnects
+	ch <- sc.evictions
+	ch <- sc.failures
+}
+
+func (sc *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range sc.client.SessionStats() {
+		for _, state := range ipmi.AllSessionStates {
+			v := 0.0
+			if s.State == state {
+				v = 1
+			}
+			ch <- prometheus.MustNewConstMetric(sc.state, prometheus.GaugeValue, v, s.Host, state.String())
+		}
+		ch <- prometheus.MustNewConstMetric(sc.reconnects, prometheus.CounterValue, float64(s.Reconnects), s.Host)
+		ch <- prometheus.MustNewConstMetric(sc.evictions, prometheus.CounterValue, float64(s.Evictions), s.Host)
+		ch <- prometheus.MustNewConstMetric(sc.failures, prometheus.GaugeValue, float64(s.ConsecutiveFailures), s.Host)
+	}
+}
+
+// consoleCollector exports the state of our console sessions with each
+// blade's BMC.
+type consoleCollector struct {
+	recorder *console.Recorder
+
+	connected  *prometheus.Desc
+	reconnects *prometheus.Desc
+}
+
+func newConsoleCollector(r *console.Recorder) *consoleCollector {
+	return &consoleCollector{
+		recorder: r,
+		connected: prometheus.NewDesc(
+			"m1000e_blade_console_connected",
+			"Whether we're currently recording a blade server's serial console over IPMI SOL.",
+			[]string{"slot"}, nil,
+		),
+		reconnects: prometheus.NewDesc(
+			"m1000e_blade_console_reconnects_total",
+			"Number of times we've opened a new SOL session with a blade server's BMC after the first.",
+			[]string{"slot"}, nil,
+		),
+	}
+}
+
+func (cc *consoleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.connected
+	ch <- cc.reconnects
+}
+
+func (cc *consoleCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range cc.recorder.Status() {
+		slot := strconv.Itoa(s.Slot)
+		ch <- prometheus.MustNewConstMetric(cc.connected, prometheus.GaugeValue, boolToFloat(s.Connected), slot)
+		ch <- prometheus.MustNewConstMetric(cc.reconnects, prometheus.CounterValue, float64(s.Reconnects), slot)
+	}
+}
+
 type metricClient struct {
-	client        *racadm.Client
-	metrics       *metrics
-	serverIPCache map[string]net.IP
-	ipmi          *ipmi.Client
+	client    chassis.CMCClient
+	metrics   *metrics
+	ipmi      *ipmi.Client
+	inventory *inventory
+	// snapshot is the last chassis snapshot we collected, which we serve at
+	// /chassis.
+	snapshot snapshot
+	// events diffs successive snapshots for /events.
+	events *chassis.Watcher
+
+	// ipmiWorkers is how many blades we'll poll at once, and ipmiHostTimeout is
+	// how long we'll spend polling each one.
+	ipmiWorkers     int
+	ipmiHostTimeout time.Duration
+	// bladeCreds are IPMI credentials for specific blades, keyed by slot
+	// number, server name or IP address.
+	bladeCreds map[string]ipmi.Credentials
+
+	// consoles records the serial consoles of the blades in consoleSlots, if
+	// it's non-nil.
+	consoles     *console.Recorder
+	consoleSlots map[int]bool
+
+	// bladeBackend is how we read blades by default, and bladeBackends
+	// overrides it for specific blades, keyed like bladeCreds. The default is
+	// backendIPMI.
+	bladeBackend  string
+	bladeBackends map[string]string
+	// idrac and idracCreds are for blades that use backendRacadm, and
+	// redfish and idracCreds are for blades that use backendRedfish.
+	idrac      *idracPool
+	redfish    *redfishPool
+	idracCreds idracCreds
 }
 
+// updateMetrics collects a snapshot of the chassis and renders it as metrics.
 func (mc *metricClient) updateMetrics() {
-	mc.updateSensorMetrics()
-	mc.updateIPMIMetrics()
+	c := chassis.New(mc.client,
+		chassis.WithBladeReader(mc),
+		chassis.WithWorkers(mc.ipmiWorkers),
+		chassis.WithHostTimeout(mc.ipmiHostTimeout))
+	ch := c.Collect(context.Background())
+	logErrors(ch)
+
+	mc.updateSensorMetrics(ch)
+	mc.updateBladeMetrics(ch)
+	mc.snapshot.set(ch)
+	for _, e := range mc.events.Update(ch) {
+		log.Printf("chassis event: %s", e.Message)
+	}
 }
 
-func (mc *metricClient) updateSensorMetrics() {
-	sInfo, err := mc.client.GetSensorInfo()
-	if err != nil {
+// logErrors logs everything we couldn't read for the snapshot.
+func logErrors(ch *chassis.Chassis) {
+	for _, err := range ch.Errors {
+		log.Print(err)
+	}
+	for _, s := range ch.Slots {
+		for _, err := range s.Errors {
+			log.Printf("slot %d: %v", s.Number, err)
+		}
+	}
+}
+
+func (mc *metricClient) updateSensorMetrics(ch *chassis.Chassis) {
+	if ch.Err(chassis.SourceSensorInfo) != nil {
 		mc.metrics.ambientTemp.Reset()
 		mc.metrics.fanRPM.Reset()
-		log.Printf("failed to load sensor info: %v", err)
 		return
 	}
-	for _, s := range sInfo.AmbientTemp {
+	for _, s := range ch.AmbientTemps {
 		labels := prometheus.Labels{
 			"number": strconv.Itoa(s.Number),
-			"name":   s.SensorName,
+			"name":   s.Name,
 			"status": s.Status,
 		}
 		if s.Units != "Celsius" {
@@ -112,13 +570,13 @@ func (mc *metricClient) updateSensorMetrics() {
 			log.Printf("unexpected ambient temp units %q, skipping", s.Units)
 			continue
 		}
-		mc.metrics.ambientTemp.With(labels).Set(float64(s.Reading))
+		mc.metrics.ambientTemp.With(labels).Set(s.Reading)
 	}
 
-	for _, s := range sInfo.Fans {
+	for _, s := range ch.Fans {
 		labels := prometheus.Labels{
 			"number": strconv.Itoa(s.Number),
-			"name":   s.SensorName,
+			"name":   s.Name,
 			"status": s.Status,
 		}
 		if s.Units != "rpm" {
@@ -126,50 +584,239 @@ func (mc *metricClient) updateSensorMetrics() {
 			log.Printf("unexpected fan speed units %q, skipping", s.Units)
 			continue
 		}
-		mc.metrics.fanRPM.With(labels).Set(float64(s.Reading))
+		mc.metrics.fanRPM.With(labels).Set(s.Reading)
 	}
 }
 
-func (mc *metricClient) updateIPMIMetrics() {
-	pbInfo, err := mc.client.GetPowerBudgetInfo()
-	if err != nil {
-		mc.metrics.serverTemp.Reset()
-		log.Printf("failed to load power budget info: %v", err)
+func (mc *metricClient) updateBladeMetrics(ch *chassis.Chassis) {
+	if ch.Err(chassis.SourcePowerBudget) != nil {
+		// We don't know what's in the slots, so we don't know which of the
+		// blades' metrics are still true.
+		for _, v := range mc.metrics.bladeVecs() {
+			v.Reset()
+		}
 		return
 	}
+	for _, s := range ch.Slots {
+		mc.updateSlotMetrics(s)
+	}
+}
 
-	for _, s := range pbInfo.ServerPowerInfo {
-		if s.PowerState != "ON" {
-			continue
-		}
-		labels := prometheus.Labels{
-			"slot_number": strconv.Itoa(s.SlotNumber),
-			"name":        s.ServerName,
+// updateSlotMetrics renders what we read about the blade in the given slot.
+// Sensors can come and go (e.g. with the power state or the backend), so we
+// start fresh each time.
+func (mc *metricClient) updateSlotMetrics(s *chassis.Slot) {
+	slot := strconv.Itoa(s.Number)
+	mc.updateBladePowerState(s, slot)
+
+	mc.metrics.serverTemp.DeletePartialMatch(prometheus.Labels{"slot_number": slot})
+	if s.AmbientTemp != nil {
+		mc.metrics.serverTemp.With(prometheus.Labels{
+			"slot_number": slot,
+			"name":        s.Name,
 			"power_state": s.PowerState,
 			"blade_type":  s.BladeType,
-		}
+		}).Set(*s.AmbientTemp)
+	}
 
-		ip, ok := mc.serverIPCache[s.ServerName]
-		if !ok {
-			log.Printf("looking up iDRAC IP for server %q", s.ServerName)
-			nicConfig, err := mc.client.GetNICConfig(s.SlotNumber)
-			if err != nil {
-				log.Printf("failed to get NIC config for slot %d: %v", s.SlotNumber, err)
-				mc.metrics.serverTemp.Delete(labels)
+	mc.metrics.bladeSensor.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	names := sensorNames(s.Sensors)
+	for i, sn := range s.Sensors {
+		mc.metrics.bladeSensor.With(prometheus.Labels{
+			"slot":   slot,
+			"sensor": names[i],
+			"type":   sn.Type,
+			"unit":   sn.Unit,
+		}).Set(sn.Value)
+	}
+
+	mc.metrics.bladePower.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	if p := s.Power; p != nil {
+		stats := map[string]*float64{
+			"current": &p.Current,
+			"min":     p.Min,
+			"max":     p.Max,
+			"average": p.Average,
+		}
+		for stat, watts := range stats {
+			if watts == nil {
 				continue
 			}
-			ip = nicConfig.IPAddress
-			mc.serverIPCache[s.ServerName] = ip
+			mc.metrics.bladePower.With(prometheus.Labels{"slot": slot, "stat": stat}).Set(*watts)
 		}
+	}
 
-		temp, err := mc.ipmi.AmbientTemp(ip.String(), 623 /* default IPMI port */)
-		if err != nil {
-			log.Printf("failed to get temp over IMP for slot %d: %v", s.SlotNumber, err)
-			mc.metrics.serverTemp.Delete(labels)
-			continue
+	mc.metrics.bmcInfo.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	if s.Firmware != "" {
+		mc.metrics.bmcInfo.With(prometheus.Labels{"slot": slot, "firmware": s.Firmware}).Set(1)
+	}
+
+	mc.updateBladeWatchdog(s, slot)
+	mc.updateBladeSelfTest(s, slot)
+	mc.updateBladeInfo(s, slot)
+	mc.updateBladeCredentials(s, slot)
+	mc.updateBladeEvents(s, slot)
+}
+
+// sensorNames returns the name to export each sensor under. Sensor names
+// aren't unique (e.g. every CPU temp on an M610 is just called "Temp"), so
+// sensors that share a name get their number added, e.g. "Temp #14".
+func sensorNames(sensors []*chassis.BladeSensor) []string {
+	count := make(map[string]int)
+	for _, sn := range sensors {
+		count[sn.Name]++
+	}
+	names := make([]string, len(sensors))
+	for i, sn := range sensors {
+		names[i] = sn.Name
+		if count[sn.Name] > 1 && sn.Number != "" {
+			names[i] += " #" + sn.Number
+		}
+	}
+	return names
+}
+
+// updateBladePowerState compares the CMC's view of the blade's power state
+// with its BMC's. The CMC only knows what it last told the blade to do, so
+// this catches blades whose BMC is hung, or that were powered off some other
+// way.
+func (mc *metricClient) updateBladePowerState(s *chassis.Slot, slot string) {
+	cmcOn := s.On()
+	mc.metrics.powerOn.With(prometheus.Labels{"slot": slot, "source": "cmc"}).Set(boolToFloat(cmcOn))
+
+	bmcLabels := prometheus.Labels{"slot": slot, "source": "bmc"}
+	switch {
+	case s.BMCPowerOn != nil:
+		on := *s.BMCPowerOn
+		if on != cmcOn {
+			log.Printf("CMC says slot %s is %s, but its BMC says power is on: %t", slot, s.PowerState, on)
 		}
+		mc.metrics.powerOn.With(bmcLabels).Set(boolToFloat(on))
+		mc.metrics.powerMismatch.With(prometheus.Labels{"slot": slot}).Set(boolToFloat(on != cmcOn))
+	case s.Err(chassis.SourcePowerState) != nil:
+		// We couldn't reach the blade's BMC. If the blade is supposed to be on,
+		// that's a problem. If it's off, we can't tell either way.
+		mc.metrics.powerOn.Delete(bmcLabels)
+		mc.metrics.powerMismatch.With(prometheus.Labels{"slot": slot}).Set(boolToFloat(cmcOn))
+	default:
+		// We don't know where the BMC is, or the blade's backend doesn't read
+		// its power state.
+		mc.metrics.powerOn.Delete(bmcLabels)
+		mc.metrics.powerMismatch.Delete(prometheus.Labels{"slot": slot})
+	}
+}
 
-		mc.metrics.serverTemp.With(labels).Set(temp)
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ReadBlade implements chassis.BladeReader, reading the blade with its
+// backend.
+func (mc *metricClient) ReadBlade(ctx context.Context, s *chassis.Slot) {
+	if host := s.Host(); host != "" {
+		mc.ipmi.SetHostCredentials(host, mc.bladeCredentials(s)...)
+		// SOL works whether or not the blade is on, and we want to see it boot.
+		if mc.consoles != nil && mc.consoleSlots[s.Number] {
+			mc.consoles.SetHost(s.Number, host)
+		}
+	}
+
+	switch mc.backend(s) {
+	case backendRacadm:
+		mc.readBladeRacadm(ctx, s)
+	case backendRedfish:
+		mc.readBladeRedfish(ctx, s)
+	default:
+		mc.readBladeIPMI(ctx, s)
+	}
+}
+
+func (mc *metricClient) readBladeIPMI(ctx context.Context, s *chassis.Slot) {
+	r := chassis.NewIPMIReader(mc.ipmi)
+	r.ReadBlade(ctx, s)
+	mc.readBladeFRU(ctx, s, r)
+}
+
+// backend returns how we read the given blade, matching on its IP address,
+// then its server name, then its slot.
+func (mc *metricClient) backend(s *chassis.Slot) string {
+	for _, key := range []string{s.Host(), s.Name, strconv.Itoa(s.Number)} {
+		if backend, ok := mc.bladeBackends[key]; ok && key != "" {
+			return backend
+		}
+	}
+	if mc.bladeBackend != "" {
+		return mc.bladeBackend
+	}
+	return backendIPMI
+}
+
+// bladeCredentials returns the IPMI credentials configured for the given
+// blade, matching on its IP address, then its server name, then its slot.
+func (mc *metricClient) bladeCredentials(s *chassis.Slot) []ipmi.Credentials {
+	var out []ipmi.Credentials
+	for _, key := range []string{s.Host(), s.Name, strconv.Itoa(s.Number)} {
+		if cred, ok := mc.bladeCreds[key]; ok && key != "" {
+			out = append(out, cred)
+		}
+	}
+	return out
+}
+
+func (mc *metricClient) updateBladeCredentials(s *chassis.Slot, slot string) {
+	mc.metrics.bladeCreds.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	if s.Credentials != "" {
+		mc.metrics.bladeCreds.With(prometheus.Labels{"slot": slot, "credentials": s.Credentials}).Set(1)
+	}
+}
+
+func (mc *metricClient) updateBladeWatchdog(s *chassis.Slot, slot string) {
+	mc.metrics.watchdogRunning.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	mc.metrics.watchdogRemaining.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	mc.metrics.watchdogExpired.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	wd := s.Watchdog
+	if wd == nil {
+		return
+	}
+
+	mc.metrics.watchdogRunning.With(prometheus.Labels{"slot": slot, "use": wd.Use, "action": wd.Action}).Set(boolToFloat(wd.Running))
+	mc.metrics.watchdogRemaining.With(prometheus.Labels{"slot": slot}).Set(wd.Remaining.Seconds())
+	expired := make(map[string]bool)
+	for _, use := range wd.Expired {
+		expired[use] = true
+	}
+	for _, use := range ipmi.TimerUses {
+		mc.metrics.watchdogExpired.With(prometheus.Labels{"slot": slot, "use": use}).Set(boolToFloat(expired[use]))
+	}
+}
+
+func (mc *metricClient) updateBladeSelfTest(s *chassis.Slot, slot string) {
+	mc.metrics.selfTestPassed.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	mc.metrics.selfTestFailure.DeletePartialMatch(prometheus.Labels{"slot": slot})
+	st := s.SelfTest
+	if st == nil {
+		return
+	}
+
+	mc.metrics.selfTestPassed.With(prometheus.Labels{"slot": slot}).Set(boolToFloat(st.Passed))
+	for _, f := range st.Failures {
+		mc.metrics.selfTestFailure.With(prometheus.Labels{"slot": slot, "failure": f}).Set(1)
+	}
+}
+
+func (mc *metricClient) updateBladeEvents(s *chassis.Slot, slot string) {
+	for _, e := range s.NewEvents {
+		if e.Severity != string(ipmi.SeverityInfo) {
+			log.Printf("slot %s logged %s event: %s: %s", slot, e.Severity, e.SensorType, e.Description)
+		}
+		mc.metrics.selEvents.With(prometheus.Labels{
+			"slot":        slot,
+			"sensor_type": e.SensorType,
+			"severity":    e.Severity,
+		}).Inc()
 	}
 }
 
@@ -185,8 +832,27 @@ func run(args []string) error {
 	if err := json.Unmarshal(dat, &crds); err != nil {
 		return fmt.Errorf("failed to unmarshal credentials: %w", err)
 	}
+	if err := crds.validateBackends(); err != nil {
+		return fmt.Errorf("invalid blade backends: %w", err)
+	}
+	if crds.IPMI == nil {
+		// Every blade might be using racadm.
+		crds.IPMI = &config.IPMICreds{}
+	}
+
+	opts := []racadm.Option{
+		racadm.WithCacheTTL("getniccfg", nicConfigTTL),
+		racadm.WithCacheTTL("getsysinfo", sysInfoTTL),
+		racadm.WithCacheTTL("getioinfo", ioInfoTTL),
+	}
+	if crds.ShellSession {
+		opts = append(opts, racadm.WithShellSession())
+	}
+	if crds.MaxSessions > 0 {
+		opts = append(opts, racadm.WithMaxSessions(crds.MaxSessions))
+	}
 
-	c, err := racadm.Dial(crds.User, crds.Password, crds.Addr)
+	c, err := racadm.Dial(crds.User, crds.Password, crds.Addr, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to init racadm client: %w", err)
 	}
@@ -205,14 +871,70 @@ func run(args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to init metrics: %w", err)
 	}
+	if err := reg.Register(newSchedulerCollector(c)); err != nil {
+		return fmt.Errorf("failed to register scheduler metrics: %w", err)
+	}
 
-	ipmiClient := ipmi.New(crds.IPMI.User, crds.IPMI.Password)
+	ipmiOpts, err := crds.IPMI.Options()
+	if err != nil {
+		return fmt.Errorf("invalid IPMI config: %w", err)
+	}
+	ipmiClient := ipmi.New(crds.IPMI.User, crds.IPMI.Password, ipmiOpts...)
+	if err := reg.Register(newSessionCollector(ipmiClient)); err != nil {
+		return fmt.Errorf("failed to register IPMI session metrics: %w", err)
+	}
 
 	mc := metricClient{
-		client:        c,
-		metrics:       m,
-		serverIPCache: make(map[string]net.IP),
-		ipmi:          ipmiClient,
+		client:          c,
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		ipmiWorkers:     defaultIPMIWorkers,
+		ipmiHostTimeout: defaultIPMIHostTimeout,
+		bladeCreds:      crds.IPMI.BladeCredentials(),
+		events:          chassis.NewWatcher(),
+	}
+	if crds.IPMI.Workers > 0 {
+		mc.ipmiWorkers = crds.IPMI.Workers
+	}
+	if crds.IPMI.HostTimeout > 0 {
+		mc.ipmiHostTimeout = time.Duration(crds.IPMI.HostTimeout)
+	}
+	mc.bladeBackend, mc.bladeBackends = crds.BladeBackend, crds.BladeBackends
+	if crds.IDRAC != nil {
+		mc.idracCreds = *crds.IDRAC
+		mc.idrac = newIDRACPool(dialIDRAC, crds.IDRAC.Port)
+		defer mc.idrac.Close()
+		if mc.redfish, err = newRedfishPool(crds.IDRAC.Redfish); err != nil {
+			return fmt.Errorf("invalid redfish config: %w", err)
+		}
+		defer mc.redfish.Close()
+	}
+
+	if cc := crds.Console; cc != nil && len(cc.Slots) > 0 {
+		if cc.Dir == "" {
+			return fmt.Errorf("console.dir is required to record consoles")
+		}
+		dial := func(ctx context.Context, host string, takeover bool) (console.Session, error) {
+			var opts []ipmi.SOLOption
+			if takeover {
+				opts = append(opts, ipmi.WithSOLTakeover())
+			}
+			sess, err := ipmiClient.SOL(ctx, host, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return sess, nil
+		}
+		mc.consoles = console.New(dial, cc.Dir, cc.options()...)
+		defer mc.consoles.Close()
+		mc.consoleSlots = make(map[int]bool)
+		for _, slot := range cc.Slots {
+			mc.consoleSlots[slot] = true
+		}
+		if err := reg.Register(newConsoleCollector(mc.consoles)); err != nil {
+			return fmt.Errorf("failed to register console metrics: %w", err)
+		}
 	}
 
 	done := make(chan struct{})
@@ -237,7 +959,16 @@ func run(args []string) error {
 
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}))
+	mux.Handle("/inventory", mc.inventory)
+	mux.Handle("/chassis", &mc.snapshot)
+	mux.Handle("/events", newEventsHandler(mc.events))
+	if mc.consoles != nil {
+		mux.Handle("/console/", mc.consoles)
+	}
 	server := &http.Server{Addr: ":8080", Handler: mux}
+	// Shutdown waits for handlers to return, which /events streams only do
+	// once their channel is closed.
+	server.RegisterOnShutdown(mc.events.Close)
 
 	// We buffer the channel because server.Shutdown will cause an error to be
 	// thrown, but we aren't listening at that point, so it'll block.
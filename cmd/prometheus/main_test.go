@@ -0,0 +1,358 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/bcspragu/m1000e-prom/racadm"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeChassis is a CMC with a fixed set of blades. If pbErr is set, it fails
+// to list them.
+type fakeChassis struct {
+	blades []*racadm.ServerPowerInfo
+	ips    map[int]net.IP
+	pbErr  error
+}
+
+func (fc *fakeChassis) GetSensorInfo() (*racadm.GetSensorInfo, error) {
+	return &racadm.GetSensorInfo{}, nil
+}
+
+func (fc *fakeChassis) GetPowerBudgetInfo() (*racadm.GetPowerBudgetInfo, error) {
+	if fc.pbErr != nil {
+		return nil, fc.pbErr
+	}
+	return &racadm.GetPowerBudgetInfo{ServerPowerInfo: fc.blades}, nil
+}
+
+func (fc *fakeChassis) GetSysInfo() (*racadm.GetSysInfo, error) {
+	return &racadm.GetSysInfo{ChassisName: "CMC-TEST"}, nil
+}
+
+func (fc *fakeChassis) GetIOInfo() (*racadm.GetIOInfo, error) {
+	return &racadm.GetIOInfo{}, nil
+}
+
+func (fc *fakeChassis) GetNICConfig(slotNum int) (*racadm.GetNICConfig, error) {
+	ip, ok := fc.ips[slotNum]
+	if !ok {
+		return nil, fmt.Errorf("no NIC config for slot %d", slotNum)
+	}
+	return &racadm.GetNICConfig{IPAddress: ip}, nil
+}
+
+func TestBladeTemp(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 21)
+
+	reg := prometheus.NewRegistry()
+	m, err := newMetrics(reg)
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin",
+		ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	mc := &metricClient{
+		client: &fakeChassis{
+			blades: []*racadm.ServerPowerInfo{
+				{SlotNumber: 1, ServerName: "web-1", PowerState: "ON", BladeType: "PowerEdgeM610"},
+				{SlotNumber: 2, ServerName: "web-2", PowerState: "OFF", BladeType: "PowerEdgeM610"},
+			},
+			ips: map[int]net.IP{1: net.ParseIP(bmc.Host)},
+		},
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		events:          chassis.NewWatcher(),
+		ipmiWorkers:     2,
+		ipmiHostTimeout: 10 * time.Second,
+	}
+
+	labels := prometheus.Labels{"slot_number": "1", "name": "web-1", "power_state": "ON", "blade_type": "PowerEdgeM610"}
+	checkTemp := func(want float64) {
+		t.Helper()
+		if n := testutil.CollectAndCount(m.serverTemp); n != 1 {
+			t.Fatalf("got %d blade temps, want 1", n)
+		}
+		if got := testutil.ToFloat64(m.serverTemp.With(labels)); got != want {
+			t.Errorf("blade temp = %g, want %g", got, want)
+		}
+	}
+
+	mc.updateMetrics()
+	checkTemp(21)
+	if got := testutil.ToFloat64(m.bladeCreds.With(prometheus.Labels{"slot": "1", "credentials": ipmi.DefaultCredentials})); got != 1 {
+		t.Errorf("credentials metric = %g, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.bmcInfo.With(prometheus.Labels{"slot": "1", "firmware": "1.00"})); got != 1 {
+		t.Errorf("BMC info metric = %g, want 1", got)
+	}
+
+	bmc.SetReadings(0x0e, 24)
+	mc.updateMetrics()
+	checkTemp(24)
+
+	// If the BMC can't read its sensors, we should stop reporting a temp rather
+	// than report a stale one.
+	bmc.Fail(ipmisim.GetSensorReading, ipmisim.CompletionNodeBusy)
+	mc.updateMetrics()
+	if n := testutil.CollectAndCount(m.serverTemp); n != 0 {
+		t.Errorf("got %d blade temps with a failing BMC, want 0", n)
+	}
+
+	bmc.Fail(ipmisim.GetSensorReading, ipmisim.CompletionOK)
+	mc.updateMetrics()
+	checkTemp(24)
+}
+
+func TestPowerStateMismatch(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 21)
+
+	reg := prometheus.NewRegistry()
+	m, err := newMetrics(reg)
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin",
+		ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	blade := &racadm.ServerPowerInfo{SlotNumber: 1, ServerName: "web-1", PowerState: "ON", BladeType: "PowerEdgeM610"}
+	mc := &metricClient{
+		client: &fakeChassis{
+			blades: []*racadm.ServerPowerInfo{blade},
+			ips:    map[int]net.IP{1: net.ParseIP(bmc.Host)},
+		},
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		events:          chassis.NewWatcher(),
+		ipmiWorkers:     1,
+		ipmiHostTimeout: 10 * time.Second,
+	}
+
+	check := func(wantCMC, wantBMC, wantMismatch float64) {
+		t.Helper()
+		if got := testutil.ToFloat64(m.powerOn.With(prometheus.Labels{"slot": "1", "source": "cmc"})); got != wantCMC {
+			t.Errorf("CMC power on = %g, want %g", got, wantCMC)
+		}
+		if wantBMC < 0 {
+			if n := testutil.CollectAndCount(m.powerOn); n != 1 {
+				t.Errorf("got %d power states, want just the CMC's", n)
+			}
+		} else if got := testutil.ToFloat64(m.powerOn.With(prometheus.Labels{"slot": "1", "source": "bmc"})); got != wantBMC {
+			t.Errorf("BMC power on = %g, want %g", got, wantBMC)
+		}
+		if got := testutil.ToFloat64(m.powerMismatch.With(prometheus.Labels{"slot": "1"})); got != wantMismatch {
+			t.Errorf("power state mismatch = %g, want %g", got, wantMismatch)
+		}
+	}
+
+	mc.updateMetrics()
+	check(1, 1, 0)
+
+	// The blade went down without the CMC's say-so.
+	bmc.SetPowerOn(false)
+	mc.updateMetrics()
+	check(1, 0, 1)
+
+	// And now the CMC agrees.
+	blade.PowerState = "OFF"
+	mc.updateMetrics()
+	check(0, 0, 0)
+	if n := testutil.CollectAndCount(m.serverTemp); n != 0 {
+		t.Errorf("got %d blade temps for a blade that's off, want 0", n)
+	}
+
+	// The CMC thinks it's on, but the BMC is hung.
+	blade.PowerState = "ON"
+	bmc.SetUnresponsive(true)
+	mc.updateMetrics()
+	check(1, -1, 1)
+}
+
+func TestWatchdogAndSelfTest(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.SetWatchdog(ipmisim.Watchdog{Running: true, Use: 0x04, Action: 0x01, InitialCountdown: 600, PresentCountdown: 450})
+
+	reg := prometheus.NewRegistry()
+	m, err := newMetrics(reg)
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin",
+		ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	blade := &racadm.ServerPowerInfo{SlotNumber: 2, ServerName: "db-1", PowerState: "ON", BladeType: "PowerEdgeM610"}
+	mc := &metricClient{
+		client: &fakeChassis{
+			blades: []*racadm.ServerPowerInfo{blade},
+			ips:    map[int]net.IP{2: net.ParseIP(bmc.Host)},
+		},
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		events:          chassis.NewWatcher(),
+		ipmiWorkers:     1,
+		ipmiHostTimeout: 10 * time.Second,
+	}
+
+	mc.updateMetrics()
+	if got := testutil.ToFloat64(m.watchdogRunning.With(prometheus.Labels{"slot": "2", "use": "sms-os", "action": "hard-reset"})); got != 1 {
+		t.Errorf("watchdog running = %g, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.watchdogRemaining.With(prometheus.Labels{"slot": "2"})); got != 45 {
+		t.Errorf("watchdog remaining = %g, want 45", got)
+	}
+	if got := testutil.ToFloat64(m.watchdogExpired.With(prometheus.Labels{"slot": "2", "use": "sms-os"})); got != 0 {
+		t.Errorf("watchdog expired = %g, want 0", got)
+	}
+	if got := testutil.ToFloat64(m.selfTestPassed.With(prometheus.Labels{"slot": "2"})); got != 1 {
+		t.Errorf("self test passed = %g, want 1", got)
+	}
+
+	// The host hangs, the watchdog fires and powers it off, and the BMC's SDR
+	// has gone missing.
+	bmc.SetWatchdog(ipmisim.Watchdog{Use: 0x04, Action: 0x02, ExpirationFlags: 0x10, InitialCountdown: 600})
+	bmc.SetPowerOn(false)
+	bmc.SetSelfTest(0x57, 0x40)
+	blade.PowerState = "OFF"
+	mc.updateMetrics()
+
+	if got := testutil.ToFloat64(m.watchdogRunning.With(prometheus.Labels{"slot": "2", "use": "sms-os", "action": "power-down"})); got != 0 {
+		t.Errorf("watchdog running = %g, want 0", got)
+	}
+	if got := testutil.ToFloat64(m.watchdogExpired.With(prometheus.Labels{"slot": "2", "use": "sms-os"})); got != 1 {
+		t.Errorf("watchdog expired = %g, want 1", got)
+	}
+	if n := testutil.CollectAndCount(m.watchdogRunning); n != 1 {
+		t.Errorf("got %d watchdog states, want 1", n)
+	}
+	if got := testutil.ToFloat64(m.selfTestPassed.With(prometheus.Labels{"slot": "2"})); got != 0 {
+		t.Errorf("self test passed = %g, want 0", got)
+	}
+	if got := testutil.ToFloat64(m.selfTestFailure.With(prometheus.Labels{"slot": "2", "failure": "sdr-inaccessible"})); got != 1 {
+		t.Errorf("self test failure = %g, want 1", got)
+	}
+}
+
+func TestPowerBudgetError(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 21)
+	bmc.AddSELEntry(ipmisim.SELEntry{Timestamp: time.Now(), SensorType: 0x0c, SensorNumber: 0x01, EventDirType: 0x6f, EventData: [3]byte{0x01, 0xff, 0xff}})
+	bmc.SetFRU(&ipmisim.FRU{BoardManufacturer: "DELL", BoardProduct: "PowerEdge M610", ManufactureDate: time.Date(2010, time.March, 30, 0, 0, 0, 0, time.UTC)})
+
+	reg := prometheus.NewRegistry()
+	m, err := newMetrics(reg)
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin",
+		ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	cmc := &fakeChassis{
+		blades: []*racadm.ServerPowerInfo{{SlotNumber: 1, ServerName: "web-1", PowerState: "ON", BladeType: "PowerEdgeM610"}},
+		ips:    map[int]net.IP{1: net.ParseIP(bmc.Host)},
+	}
+	mc := &metricClient{
+		client:          cmc,
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		events:          chassis.NewWatcher(),
+		ipmiWorkers:     1,
+		ipmiHostTimeout: 10 * time.Second,
+	}
+
+	// bladeMetrics returns the names of the per-blade metrics we're exporting.
+	bladeMetrics := func() []string {
+		t.Helper()
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+		var names []string
+		for _, mf := range mfs {
+			if name := mf.GetName(); strings.HasPrefix(name, "m1000e_blade_") || name == "m1000e_server_temp_celsius" {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	mc.updateMetrics()
+	want := []string{
+		"m1000e_blade_bmc_info",
+		"m1000e_blade_bmc_self_test_passed",
+		"m1000e_blade_info",
+		"m1000e_blade_ipmi_credentials",
+		"m1000e_blade_power_on",
+		"m1000e_blade_power_state_mismatch",
+		"m1000e_blade_sel_events_total",
+		"m1000e_blade_sensor",
+		"m1000e_blade_watchdog_expired",
+		"m1000e_blade_watchdog_remaining_seconds",
+		"m1000e_blade_watchdog_running",
+		"m1000e_server_temp_celsius",
+	}
+	if diff := cmp.Diff(want, bladeMetrics()); diff != "" {
+		t.Errorf("unexpected blade metrics (-want +got)\n%s", diff)
+	}
+
+	eccErrors := m.selEvents.With(prometheus.Labels{"slot": "1", "sensor_type": "Memory", "severity": "critical"})
+	if got := testutil.ToFloat64(eccErrors); got != 1 {
+		t.Errorf("SEL events = %g, want 1", got)
+	}
+
+	// Without the power budget, we don't know what's in the slots, so we can't
+	// trust anything we last read about the blades. The events they logged
+	// still happened, though.
+	cmc.pbErr = errors.New("timed out")
+	mc.updateMetrics()
+	want = []string{"m1000e_blade_sel_events_total"}
+	if diff := cmp.Diff(want, bladeMetrics()); diff != "" {
+		t.Errorf("unexpected blade metrics after failing to read the power budget (-want +got)\n%s", diff)
+	}
+	if got := testutil.ToFloat64(eccErrors); got != 1 {
+		t.Errorf("SEL events = %g after failing to read the power budget, want 1", got)
+	}
+
+	// Once the CMC's back, we pick up where we left off.
+	cmc.pbErr = nil
+	bmc.AddSELEntry(ipmisim.SELEntry{Timestamp: time.Now(), SensorType: 0x0c, SensorNumber: 0x01, EventDirType: 0x6f, EventData: [3]byte{0x01, 0xff, 0xff}})
+	mc.updateMetrics()
+	if got := testutil.ToFloat64(eccErrors); got != 2 {
+		t.Errorf("SEL events = %g after the CMC came back, want 2", got)
+	}
+}
+
+func TestSensorNames(t *testing.T) {
+	sensors := []*chassis.BladeSensor{
+		{Number: "14", Name: "Temp"},
+		{Number: "15", Name: "Temp"},
+		{Number: "1", Name: "Ambient Temp"},
+		// The racadm backend doesn't know sensor numbers, but its names are
+		// unique anyway.
+		{Name: "System Board Inlet Temp"},
+	}
+	want := []string{"Temp #14", "Temp #15", "Ambient Temp", "System Board Inlet Temp"}
+	if diff := cmp.Diff(want, sensorNames(sensors)); diff != "" {
+		t.Errorf("unexpected sensor names (-want +got)\n%s", diff)
+	}
+}
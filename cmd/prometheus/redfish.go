@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/redfish"
+)
+
+// redfishConfig is how we talk to blades' iDRACs for the redfish backend,
+// which logs in with the same credentials as the racadm backend.
+type redfishConfig struct {
+	// Port is the iDRACs' HTTPS port, 443 by default.
+	Port int
+	// SessionAuth logs in once per iDRAC rather than sending the password
+	// with every request, see redfish.WithSessionAuth.
+	SessionAuth bool
+	// CACert is a PEM file of the CAs that signed the iDRACs' certificates,
+	// which are checked against the system's CAs otherwise.
+	CACert string
+	// InsecureSkipVerify doesn't check the iDRACs' certificates at all, for
+	// iDRACs that still have their factory self-signed ones.
+	InsecureSkipVerify bool
+}
+
+const (
+	defaultRedfishPort = 443
+	// The IDs of a blade's chassis and manager on its iDRAC, for when the
+	// system doesn't link to them.
+	idracChassisID = "System.Embedded.1"
+	idracManagerID = "iDRAC.Embedded.1"
+)
+
+// redfishPool keeps a Redfish client for the iDRAC of each blade we read with
+// the redfish backend, along with what we've already read from its SEL.
+type redfishPool struct {
+	port int
+	opts []redfish.Option
+
+	mu sync.Mutex
+	// Keyed by host.
+	conns map[string]*redfishConn
+}
+
+type redfishConn struct {
+	client *redfish.Client
+	// The credentials the client was created with, so we can tell if they've
+	// changed.
+	creds idracCredentials
+
+	// sel is how far we've read into the iDRAC's SEL. It's the host's, not the
+	// client's, so it's carried over if the credentials change.
+	sel *redfishSEL
+}
+
+// redfishSEL is how far we've read into an iDRAC's SEL.
+type redfishSEL struct {
+	// log is the SEL's log service, which we look up once.
+	log *redfish.LogService
+	// newest is when the newest entry we've counted was logged, or zero if we
+	// haven't read the SEL yet. Entries are only logged to the second, so we
+	// also keep the IDs of the ones logged then, to tell them apart from
+	// entries logged in the same second after we looked.
+	newest    time.Time
+	newestIDs map[string]bool
+}
+
+func newRedfishPool(cfg *redfishConfig) (*redfishPool, error) {
+	if cfg == nil {
+		cfg = &redfishConfig{}
+	}
+	p := &redfishPool{
+		port:  cfg.Port,
+		conns: make(map[string]*redfishConn),
+	}
+	if p.port == 0 {
+		p.port = defaultRedfishPort
+	}
+	if cfg.SessionAuth {
+		p.opts = append(p.opts, redfish.WithSessionAuth())
+	}
+	if cfg.InsecureSkipVerify {
+		p.opts = append(p.opts, redfish.WithInsecureSkipVerify())
+	}
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read iDRAC CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACert)
+		}
+		p.opts = append(p.opts, redfish.WithRootCAs(pool))
+	}
+	return p, nil
+}
+
+// conn returns the host's client, creating it if we don't have one yet or if
+// its credentials have changed.
+func (p *redfishPool) conn(host string, creds idracCredentials) *redfishConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cn, ok := p.conns[host]
+	if ok && cn.creds == creds {
+		return cn
+	}
+	sel := &redfishSEL{}
+	if ok {
+		// Log out in the background, so we don't hold the lock on a slow iDRAC.
+		go closeRedfish(host, cn.client)
+		sel = cn.sel
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(p.port))
+	cn = &redfishConn{
+		client: redfish.New(addr, creds.User, creds.Password, p.opts...),
+		creds:  creds,
+		sel:    sel,
+	}
+	p.conns[host] = cn
+	return cn
+}
+
+// Close logs out of any sessions we have open.
+func (p *redfishPool) Close() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = make(map[string]*redfishConn)
+	p.mu.Unlock()
+	for host, cn := range conns {
+		closeRedfish(host, cn.client)
+	}
+}
+
+func closeRedfish(host string, c *redfish.Client) {
+	if err := c.Close(); err != nil {
+		log.Printf("failed to log out of iDRAC %s: %v", host, err)
+	}
+}
+
+// readBladeRedfish reads blades that use the redfish backend. We get the same
+// sensors, power readings and SEL events as over IPMI, but not the FRU,
+// watchdog or self test.
+func (mc *metricClient) readBladeRedfish(ctx context.Context, s *chassis.Slot) {
+	s.Backend = backendRedfish
+	mc.readBladeFRU(ctx, s, nil)
+
+	host := s.Host()
+	if host == "" {
+		return
+	}
+
+	cn := mc.redfish.conn(host, mc.idracCredentials(s))
+	systems, err := cn.client.Systems(ctx)
+	if err == nil && len(systems) == 0 {
+		err = errors.New("iDRAC doesn't list any systems")
+	}
+	if err != nil {
+		s.AddError(chassis.SourcePowerState, err)
+		return
+	}
+	sys := systems[0]
+	on := sys.On()
+	s.BMCPowerOn = &on
+
+	if !s.On() {
+		return
+	}
+
+	chassisID, managerID := idracChassisID, idracManagerID
+	if len(sys.Chassis) > 0 {
+		chassisID = sys.Chassis[0]
+	}
+	if len(sys.Managers) > 0 {
+		managerID = sys.Managers[0]
+	}
+	readRedfishEvents(ctx, cn, s, managerID)
+	readRedfishPower(ctx, cn.client, s, chassisID)
+
+	th, err := cn.client.Thermal(ctx, chassisID)
+	if err != nil {
+		s.AddError(chassis.SourceSensors, err)
+		return
+	}
+	for _, t := range th.Temperatures {
+		if t.ReadingCelsius == nil {
+			continue
+		}
+		s.Sensors = append(s.Sensors, redfishSensor(t.SensorNumber, t.Name, "Temperature", "degrees C", *t.ReadingCelsius, t.Status))
+		if s.AmbientTemp == nil && isRedfishAmbientTemp(t) {
+			temp := *t.ReadingCelsius
+			s.AmbientTemp = &temp
+		}
+	}
+	for _, f := range th.Fans {
+		if f.Reading == nil {
+			continue
+		}
+		unit := "RPM"
+		if f.ReadingUnits == "Percent" {
+			unit = "percent"
+		}
+		s.Sensors = append(s.Sensors, redfishSensor(f.SensorNumber, f.Name, "Fan", unit, *f.Reading, f.Status))
+	}
+	if s.AmbientTemp == nil {
+		s.AddError(chassis.SourceAmbientTemp, errors.New("no ambient temp sensor"))
+	}
+}
+
+func redfishSensor(number int, name, typ, unit string, v float64, status redfish.Status) *chassis.BladeSensor {
+	return &chassis.BladeSensor{
+		Number: strconv.Itoa(number),
+		Name:   name,
+		Type:   typ,
+		Unit:   unit,
+		Value:  v,
+		Status: status.Health,
+	}
+}
+
+// isRedfishAmbientTemp reports whether the sensor is the equivalent of the
+// "Ambient Temp" sensor we read over IPMI.
+func isRedfishAmbientTemp(t *redfish.Temperature) bool {
+	return t.PhysicalContext == "Intake" || strings.Contains(t.Name, "Inlet") || strings.Contains(t.Name, "Ambient")
+}
+
+func readRedfishPower(ctx context.Context, c *redfish.Client, s *chassis.Slot, chassisID string) {
+	p, err := c.Power(ctx, chassisID)
+	if err != nil {
+		s.AddError(chassis.SourcePower, err)
+		return
+	}
+
+	for _, v := range p.Voltages {
+		if v.ReadingVolts == nil {
+			continue
+		}
+		s.Sensors = append(s.Sensors, redfishSensor(v.SensorNumber, v.Name, "Voltage", "Volts", *v.ReadingVolts, v.Status))
+	}
+
+	if len(p.PowerControl) == 0 || p.PowerControl[0].PowerConsumedWatts == nil {
+		s.AddError(chassis.SourcePower, errors.New("iDRAC doesn't report power consumption"))
+		return
+	}
+	pc := p.PowerControl[0]
+	s.Power = &chassis.BladePower{
+		Current: *pc.PowerConsumedWatts,
+		Min:     pc.PowerMetrics.MinConsumedWatts,
+		Max:     pc.PowerMetrics.MaxConsumedWatts,
+		Average: pc.PowerMetrics.AverageConsumedWatts,
+	}
+}
+
+// readRedfishEvents reads the entries that have been added to the blade's SEL
+// since we last looked. Like over IPMI, the first time we look we get
+// everything that's already there.
+func readRedfishEvents(ctx context.Context, cn *redfishConn, s *chassis.Slot, managerID string) {
+	// Only the goroutine reading this blade touches its SEL state.
+	sel := cn.sel
+	if sel.log == nil {
+		services, err := cn.client.LogServices(ctx, managerID)
+		if err != nil {
+			s.AddError(chassis.SourceSEL, err)
+			return
+		}
+		for _, ls := range services {
+			if strings.EqualFold(ls.ID, "SEL") {
+				sel.log = ls
+			}
+		}
+		if sel.log == nil {
+			s.AddError(chassis.SourceSEL, errors.New("iDRAC doesn't have a SEL log service"))
+			return
+		}
+	}
+
+	entries, err := cn.client.LogEntriesSince(ctx, sel.log, sel.newest)
+	if err != nil {
+		s.AddError(chassis.SourceSEL, err)
+		return
+	}
+	newest, newestIDs := sel.newest, sel.newestIDs
+	for _, e := range entries {
+		if e.Created.Equal(sel.newest) && sel.newestIDs[e.ID] {
+			continue
+		}
+		if newestIDs == nil || e.Created.After(newest) {
+			newest, newestIDs = e.Created, make(map[string]bool)
+		}
+		if e.Created.Equal(newest) {
+			newestIDs[e.ID] = true
+		}
+		s.NewEvents = append(s.NewEvents, &chassis.SELEvent{
+			Timestamp:   e.Created,
+			SensorType:  e.SensorType,
+			Description: e.Message,
+			Severity:    string(redfishSeverity(e.Severity)),
+		})
+	}
+	sel.newest, sel.newestIDs = newest, newestIDs
+}
+
+// redfishSeverity maps Redfish's severities to the ones we use for IPMI
+// events, so m1000e_blade_sel_events_total looks the same whichever backend
+// a blade uses.
+func redfishSeverity(s string) ipmi.Severity {
+	switch s {
+	case "Warning":
+		return ipmi.SeverityWarning
+	case "Critical":
+		return ipmi.SeverityCritical
+	}
+	return ipmi.SeverityInfo
+}
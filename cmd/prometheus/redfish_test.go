@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/racadm"
+	"github.com/bcspragu/m1000e-prom/redfish/redfishsim"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRedfishBackend(t *testing.T) {
+	idrac := redfishsim.New(t, "root", "calvin")
+
+	// The iDRAC's certificate is trusted through the CA cert file.
+	caFile := filepath.Join(t.TempDir(), "idrac-ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: idrac.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("failed to write CA cert: %v", err)
+	}
+	pool, err := newRedfishPool(&redfishConfig{Port: idrac.Port, SessionAuth: true, CACert: caFile})
+	if err != nil {
+		t.Fatalf("newRedfishPool: %v", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	m, err := newMetrics(reg)
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin", ipmi.WithDefaults(ipmi.Config{Timeout: 100 * time.Millisecond}))
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	blade := &racadm.ServerPowerInfo{SlotNumber: 2, ServerName: "db-1", PowerState: "ON", BladeType: "PowerEdgeM630"}
+	mc := &metricClient{
+		client: &fakeChassis{
+			blades: []*racadm.ServerPowerInfo{blade},
+			ips:    map[int]net.IP{2: net.ParseIP(idrac.Host)},
+		},
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		events:          chassis.NewWatcher(),
+		ipmiWorkers:     1,
+		ipmiHostTimeout: 10 * time.Second,
+		bladeBackend:    backendRedfish,
+		redfish:         pool,
+		idracCreds:      idracCreds{User: "root", Password: "calvin"},
+	}
+
+	labels := prometheus.Labels{"slot_number": "2", "name": "db-1", "power_state": "ON", "blade_type": "PowerEdgeM630"}
+	mc.updateMetrics()
+	if got := testutil.ToFloat64(m.serverTemp.With(labels)); got != 23 {
+		t.Errorf("blade temp = %g, want 23", got)
+	}
+	sensorLabels := prometheus.Labels{"slot": "2", "sensor": "CPU1 Temp", "type": "Temperature", "unit": "degrees C"}
+	if got := testutil.ToFloat64(m.bladeSensor.With(sensorLabels)); got != 47 {
+		t.Errorf("CPU1 temp = %g, want 47", got)
+	}
+	voltLabels := prometheus.Labels{"slot": "2", "sensor": "CPU1 VCORE PG", "type": "Voltage", "unit": "Volts"}
+	if got := testutil.ToFloat64(m.bladeSensor.With(voltLabels)); got != 1 {
+		t.Errorf("CPU1 VCORE PG = %g, want 1", got)
+	}
+	wantPower := map[string]float64{"current": 126, "min": 120, "max": 168, "average": 131}
+	for stat, want := range wantPower {
+		if got := testutil.ToFloat64(m.bladePower.With(prometheus.Labels{"slot": "2", "stat": stat})); got != want {
+			t.Errorf("%s blade power = %g, want %g", stat, got, want)
+		}
+	}
+	if got := testutil.ToFloat64(m.powerOn.With(prometheus.Labels{"slot": "2", "source": "bmc"})); got != 1 {
+		t.Errorf("iDRAC power state = %g, want 1", got)
+	}
+	// Everything already in the SEL is counted the first time.
+	selLabels := prometheus.Labels{"slot": "2", "sensor_type": "Memory", "severity": "critical"}
+	if got := testutil.ToFloat64(m.selEvents.With(selLabels)); got != 1 {
+		t.Errorf("critical memory events = %g, want 1", got)
+	}
+	if n := testutil.CollectAndCount(m.bladeCreds); n != 0 {
+		t.Errorf("got %d IPMI credentials metrics for a Redfish blade, want 0", n)
+	}
+
+	// Only new SEL entries are counted after that, and we stay logged in.
+	idrac.AddSELEntry(redfishsim.SELEntry{
+		Created:    time.Now().Format(time.RFC3339),
+		Severity:   "Critical",
+		Message:    "Multi-bit memory errors detected on a memory device at location(s) DIMM A1.",
+		SensorType: "Memory",
+	})
+	idrac.SetTemperature("System Board Inlet Temp", 25)
+	mc.updateMetrics()
+	if got := testutil.ToFloat64(m.selEvents.With(selLabels)); got != 2 {
+		t.Errorf("critical memory events = %g, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.serverTemp.With(labels)); got != 25 {
+		t.Errorf("blade temp = %g, want 25", got)
+	}
+	if n := idrac.Logins(); n != 1 {
+		t.Errorf("logged in to the iDRAC %d times, want 1", n)
+	}
+
+	// The iDRAC thinks the blade is off, even though the CMC doesn't.
+	idrac.SetPowerState("Off")
+	mc.updateMetrics()
+	if got := testutil.ToFloat64(m.powerMismatch.With(prometheus.Labels{"slot": "2"})); got != 1 {
+		t.Errorf("power mismatch = %g, want 1", got)
+	}
+	idrac.SetPowerState("On")
+
+	// If the iDRAC stops answering, we stop reporting its temp.
+	idrac.Fail(redfishsim.ThermalPath, http.StatusInternalServerError)
+	mc.updateMetrics()
+	if n := testutil.CollectAndCount(m.serverTemp); n != 0 {
+		t.Errorf("got %d blade temps with a failing iDRAC, want 0", n)
+	}
+	idrac.Fail(redfishsim.ThermalPath, 0)
+
+	blade.PowerState = "OFF"
+	mc.updateMetrics()
+	if n := testutil.CollectAndCount(m.bladeSensor); n != 0 {
+		t.Errorf("got %d blade sensors for a blade that's off, want 0", n)
+	}
+
+	pool.Close()
+	if n := idrac.ActiveSessions(); n != 0 {
+		t.Errorf("%d iDRAC sessions still open after closing the pool", n)
+	}
+}
+
+func TestRedfishSELCursor(t *testing.T) {
+	idrac := redfishsim.New(t, "root", "calvin")
+	pool, err := newRedfishPool(&redfishConfig{Port: idrac.Port, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("newRedfishPool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	m, err := newMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin", ipmi.WithDefaults(ipmi.Config{Timeout: 100 * time.Millisecond}))
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	mc := &metricClient{
+		client: &fakeChassis{
+			blades: []*racadm.ServerPowerInfo{{SlotNumber: 2, ServerName: "db-1", PowerState: "ON", BladeType: "PowerEdgeM630"}},
+			ips:    map[int]net.IP{2: net.ParseIP(idrac.Host)},
+		},
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		events:          chassis.NewWatcher(),
+		ipmiWorkers:     1,
+		ipmiHostTimeout: 10 * time.Second,
+		bladeBackend:    backendRedfish,
+		redfish:         pool,
+		idracCreds:      idracCreds{User: "root", Password: "calvin"},
+	}
+
+	memoryErrors := m.selEvents.With(prometheus.Labels{"slot": "2", "sensor_type": "Memory", "severity": "warning"})
+	addMemoryError := func(created string) {
+		idrac.AddSELEntry(redfishsim.SELEntry{
+			Created:    created,
+			Severity:   "Warning",
+			Message:    "Correctable memory error rate exceeded for DIMM A1.",
+			SensorType: "Memory",
+		})
+	}
+	check := func(when string, want float64) {
+		t.Helper()
+		if got := testutil.ToFloat64(memoryErrors); got != want {
+			t.Errorf("%s: memory warnings = %g, want %g", when, got, want)
+		}
+	}
+
+	mc.updateMetrics()
+	check("before any warnings", 0)
+
+	// The iDRAC logs the time to the second, so these look the same apart
+	// from their IDs, and we read the SEL in between them.
+	addMemoryError("2024-03-05T10:00:00-06:00")
+	mc.updateMetrics()
+	check("after the first warning", 1)
+	addMemoryError("2024-03-05T10:00:00-06:00")
+	mc.updateMetrics()
+	check("after a second warning in the same second", 2)
+	mc.updateMetrics()
+	check("after reading the SEL again", 2)
+
+	// Someone changes the iDRAC's password, and then the config to match.
+	// That's a new client, but it's the same SEL.
+	mc.idracCreds.Password = "hunter2"
+	mc.updateMetrics()
+	mc.idracCreds.Password = "calvin"
+	mc.updateMetrics()
+	check("after changing credentials", 2)
+	addMemoryError("2024-03-05T10:00:01-06:00")
+	mc.updateMetrics()
+	check("after a warning with the new credentials", 3)
+}
+
+func TestValidateBackends(t *testing.T) {
+	tests := []struct {
+		desc    string
+		crds    creds
+		wantErr bool
+	}{
+		{"default", creds{}, false},
+		{"redfish", creds{BladeBackend: backendRedfish, IDRAC: &idracCreds{}}, false},
+		{"redfish without idrac", creds{BladeBackends: map[string]string{"1": backendRedfish}}, true},
+		{"unknown", creds{BladeBackend: "snmp"}, true},
+	}
+	for _, test := range tests {
+		err := test.crds.validateBackends()
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: validateBackends() = %v, want error: %t", test.desc, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+)
+
+// snapshot holds the last chassis snapshot we collected.
+type snapshot struct {
+	mu sync.Mutex
+	ch *chassis.Chassis
+}
+
+func (s *snapshot) set(ch *chassis.Chassis) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ch = ch
+}
+
+func (s *snapshot) get() *chassis.Chassis {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ch
+}
+
+// ServeHTTP serves the last snapshot as JSON, or a 503 if we haven't finished
+// collecting one yet.
+func (s *snapshot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ch := s.get()
+	if ch == nil {
+		http.Error(w, "no chassis snapshot yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ch); err != nil {
+		log.Printf("failed to write chassis snapshot: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/chassis"
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/bcspragu/m1000e-prom/racadm"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestServeSnapshot(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 21)
+
+	m, err := newMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("newMetrics: %v", err)
+	}
+	ipmiClient := ipmi.New("root", "calvin",
+		ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { ipmiClient.Close() })
+
+	mc := &metricClient{
+		client: &fakeChassis{
+			blades: []*racadm.ServerPowerInfo{
+				{SlotNumber: 1, ServerName: "web-1", PowerState: "ON", BladeType: "PowerEdgeM610"},
+				{SlotNumber: 2, ServerName: "web-2", PowerState: "OFF", BladeType: "PowerEdgeM610"},
+			},
+			ips: map[int]net.IP{1: net.ParseIP(bmc.Host)},
+		},
+		metrics:         m,
+		ipmi:            ipmiClient,
+		inventory:       newInventory(),
+		events:          chassis.NewWatcher(),
+		ipmiWorkers:     2,
+		ipmiHostTimeout: 10 * time.Second,
+	}
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mc.snapshot.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/chassis", nil))
+		return w
+	}
+	if w := get(); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d before the first poll, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	mc.updateMetrics()
+	w := get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	var got struct {
+		Name  string
+		Slots []struct {
+			Number      int
+			Backend     string
+			AmbientTemp *float64
+			Errors      []struct{ Source, Error string }
+		}
+	}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+	if got.Name != "CMC-TEST" {
+		t.Errorf("chassis name = %q, want CMC-TEST", got.Name)
+	}
+	if len(got.Slots) != 2 {
+		t.Fatalf("got %d slots, want 2", len(got.Slots))
+	}
+	if s := got.Slots[0]; s.Backend != backendIPMI || s.AmbientTemp == nil || *s.AmbientTemp != 21 {
+		t.Errorf("slot 1 = %+v, want an ambient temp of 21 over IPMI", s)
+	}
+	// We don't know where slot 2's iDRAC is.
+	if errs := got.Slots[1].Errors; len(errs) != 1 || errs[0].Source != "getniccfg" || errs[0].Error == "" {
+		t.Errorf("slot 2 errors = %+v, want a getniccfg error", errs)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/bcspragu/m1000e-prom/racadm"
+)
+
+func main() {
+	if err := run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	subcommand := fs.String("command", "", "The racadm subcommand that produced the output, e.g. 'getsysinfo'. Detected from the output if not given.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [-command <subcommand>] [path to saved output, or - for stdin]\n", args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	dat, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read output: %w", err)
+	}
+
+	if *subcommand == "" {
+		if *subcommand, err = racadm.DetectSubcommand(dat); err != nil {
+			return fmt.Errorf("failed to detect command, try passing -command: %w", err)
+		}
+		log.Printf("detected output from %q", *subcommand)
+	}
+
+	resp, err := racadm.Parse(*subcommand, bytes.NewReader(dat))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s output: %w", *subcommand, err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(resp); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
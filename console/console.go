@@ -0,0 +1,442 @@
+// Package console records blade servers' serial consoles, so the last thing
+// a blade printed (like a kernel panic) is still around after it's rebooted.
+// Each blade's console is streamed over IPMI Serial over LAN to a rotating
+// log file, and the last few lines are kept in memory to serve over HTTP.
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session is a console session with a blade, like an *ipmi.SOLSession.
+type Session interface {
+	io.ReadCloser
+	// Ping checks that the other end is still there.
+	Ping(ctx context.Context) error
+}
+
+// DialFunc opens a console session with the BMC at host. If takeover is set,
+// it should take the console over from any other session that has it, like
+// ipmi.WithSOLTakeover does.
+type DialFunc func(ctx context.Context, host string, takeover bool) (Session, error)
+
+const (
+	defaultMaxFileSize = 10 << 20
+	defaultMaxFiles    = 5
+	defaultLines       = 1000
+	defaultKeepAlive   = 30 * time.Second
+	defaultMinBackoff  = 10 * time.Second
+	defaultMaxBackoff  = 10 * time.Minute
+)
+
+// Recorder records the consoles of any number of blades. It's safe for
+// concurrent use.
+type Recorder struct {
+	dial DialFunc
+	dir  string
+
+	maxFileSize int64
+	maxFiles    int
+	lines       int
+	keepAlive   time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	takeover    bool
+
+	mu sync.Mutex
+	// Keyed by slot.
+	consoles map[int]*console
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type Option func(*Recorder)
+
+// WithMaxFileSize sets how big (in bytes) a console log can get before it's
+// rotated, 10 MiB by default.
+func WithMaxFileSize(n int64) Option {
+	return func(r *Recorder) {
+		r.maxFileSize = n
+	}
+}
+
+// WithMaxFiles sets how many rotated console logs we keep for each blade, on
+// top of the current one, 5 by default.
+func WithMaxFiles(n int) Option {
+	return func(r *Recorder) {
+		r.maxFiles = n
+	}
+}
+
+// WithLines sets how many lines of each blade's console we keep in memory,
+// 1000 by default.
+func WithLines(n int) Option {
+	return func(r *Recorder) {
+		r.lines = n
+	}
+}
+
+// WithKeepAlive sets how often we check that a console session is still
+// alive, 30 seconds by default. BMCs forget their sessions when they're reset
+// without telling anyone, so this is how we notice and reconnect.
+func WithKeepAlive(d time.Duration) Option {
+	return func(r *Recorder) {
+		r.keepAlive = d
+	}
+}
+
+// WithBackoff sets how long we wait before reconnecting after a session fails
+// (or can't be opened), which doubles after each consecutive failure up to
+// max. By default, it's 10 seconds up to 10 minutes.
+func WithBackoff(min, max time.Duration) Option {
+	return func(r *Recorder) {
+		r.minBackoff, r.maxBackoff = min, max
+	}
+}
+
+// WithTakeover takes a blade's console over from any other session that has
+// it when we connect, rather than waiting for it to be let go. Even so, if
+// something else takes the console from us, we leave it be and back off until
+// it's done.
+func WithTakeover() Option {
+	return func(r *Recorder) {
+		r.takeover = true
+	}
+}
+
+// New returns a Recorder that opens console sessions with dial, and writes
+// each blade's console to dir/console-<slot>.log.
+func New(dial DialFunc, dir string, opts ...Option) *Recorder {
+	r := &Recorder{
+		dial:        dial,
+		dir:         dir,
+		maxFileSize: defaultMaxFileSize,
+		maxFiles:    defaultMaxFiles,
+		lines:       defaultLines,
+		keepAlive:   defaultKeepAlive,
+		minBackoff:  defaultMinBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		consoles:    make(map[int]*console),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// console is the state of one blade's console.
+type console struct {
+	slot   int
+	host   string
+	cancel context.CancelFunc
+
+	// lines is written by the recording goroutine, and has its own lock for
+	// readers.
+	lines *lineBuffer
+	// done is closed once the recording goroutine has closed the session and
+	// the log file.
+	done chan struct{}
+
+	mu         sync.Mutex
+	connected  bool
+	connects   int
+	reconnects uint64
+	lastErr    error
+	retryAt    time.Time
+}
+
+// Status is the state of a blade's console session.
+type Status struct {
+	Slot      int
+	Host      string
+	Connected bool
+	// Reconnects is how many times we've had to open a new session after the
+	// first one.
+	Reconnects uint64
+	// LastError is why the last session failed, or couldn't be opened.
+	LastError string `json:",omitempty"`
+	// RetryAt is when we'll next try to connect, if we're backing off.
+	RetryAt time.Time `json:",omitempty"`
+}
+
+// SetHost records the console of the blade in the given slot, whose BMC is at
+// host. If we were already recording the slot's console from a different
+// host, we switch to the new one. An empty host stops recording the slot.
+func (r *Recorder) SetHost(slot int, host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	var prev chan struct{}
+	if cur, ok := r.consoles[slot]; ok {
+		if cur.host == host {
+			return
+		}
+		cur.cancel()
+		delete(r.consoles, slot)
+		prev = cur.done
+	}
+	if host == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cn := &console{
+		slot:   slot,
+		host:   host,
+		cancel: cancel,
+		lines:  newLineBuffer(r.lines),
+		done:   make(chan struct{}),
+	}
+	r.consoles[slot] = cn
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(cn.done)
+		if prev != nil {
+			// Wait for the old host's session to let go of the log file.
+			<-prev
+		}
+		r.record(ctx, cn)
+	}()
+}
+
+// Close stops recording every console, and waits for their sessions and log
+// files to be closed.
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	r.closed = true
+	for _, cn := range r.consoles {
+		cn.cancel()
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+// Lines returns the last n lines of the slot's console, or all of the ones we
+// have if n is zero. It returns false if we aren't recording the slot.
+func (r *Recorder) Lines(slot, n int) ([]string, bool) {
+	r.mu.Lock()
+	cn, ok := r.consoles[slot]
+	r.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return cn.lines.last(n), true
+}
+
+// Status returns the state of each console we're recording, by slot.
+func (r *Recorder) Status() []Status {
+	r.mu.Lock()
+	consoles := make([]*console, 0, len(r.consoles))
+	for _, cn := range r.consoles {
+		consoles = append(consoles, cn)
+	}
+	r.mu.Unlock()
+
+	out := make([]Status, 0, len(consoles))
+	for _, cn := range consoles {
+		cn.mu.Lock()
+		s := Status{
+			Slot:       cn.slot,
+			Host:       cn.host,
+			Connected:  cn.connected,
+			Reconnects: cn.reconnects,
+			RetryAt:    cn.retryAt,
+		}
+		if cn.lastErr != nil {
+			s.LastError = cn.lastErr.Error()
+		}
+		cn.mu.Unlock()
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Slot < out[j].Slot })
+	return out
+}
+
+// record streams the console to its log file until ctx is cancelled,
+// reconnecting whenever the session fails.
+func (r *Recorder) record(ctx context.Context, cn *console) {
+	f, err := openRotatingFile(r.dir, fmt.Sprintf("console-%d.log", cn.slot), r.maxFileSize, r.maxFiles)
+	if err != nil {
+		log.Printf("failed to open console log for slot %d: %v", cn.slot, err)
+		cn.setErr(err, time.Time{})
+		return
+	}
+	defer f.Close()
+	w := io.MultiWriter(f, cn.lines)
+
+	failures := 0
+	// preempted is set when something else took the console from us, which
+	// means someone wants it, so we don't take it back until we've managed to
+	// connect without doing so.
+	preempted := false
+	for {
+		connected, err := r.stream(ctx, cn, w, r.takeover && !preempted)
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case errors.Is(err, errDeactivated):
+			// Keep backing off for as long as they want it.
+			preempted = true
+		case connected:
+			// The BMC was fine until just now, so start the backoff from scratch.
+			preempted = false
+			failures = 0
+		}
+		failures++
+		wait := backoff(r.minBackoff, r.maxBackoff, failures)
+		log.Printf("console session for slot %d failed, retrying in %s: %v", cn.slot, wait, err)
+		cn.setErr(err, time.Now().Add(wait))
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// errDeactivated is returned by stream when the BMC ends the session, which it
+// does when another session takes the console over.
+var errDeactivated = errors.New("BMC ended the session, something else may have taken the console")
+
+// stream opens a session and copies its output to w until it fails. It
+// reports whether the session was opened at all.
+func (r *Recorder) stream(ctx context.Context, cn *console, w io.Writer, takeover bool) (bool, error) {
+	sess, err := r.dial(ctx, cn.host, takeover)
+	if err != nil {
+		return false, err
+	}
+	defer sess.Close()
+
+	cn.mu.Lock()
+	if cn.connects > 0 {
+		cn.reconnects++
+	}
+	cn.connects++
+	cn.connected = true
+	cn.lastErr = nil
+	cn.retryAt = time.Time{}
+	cn.mu.Unlock()
+	defer func() {
+		cn.mu.Lock()
+		cn.connected = false
+		cn.mu.Unlock()
+	}()
+
+	// Closing the session is the only way to unblock a Read, so that's what
+	// we do when we're cancelled or the BMC stops answering.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pingErr := make(chan error, 1)
+	go func() {
+		t := time.NewTicker(r.keepAlive)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				sess.Close()
+				return
+			case <-t.C:
+				pctx, pcancel := context.WithTimeout(ctx, r.keepAlive)
+				err := sess.Ping(pctx)
+				pcancel()
+				if err != nil && ctx.Err() == nil {
+					pingErr <- err
+					sess.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	_, err = io.Copy(w, sess)
+	select {
+	case perr := <-pingErr:
+		return true, perr
+	default:
+	}
+	if err == nil {
+		return true, errDeactivated
+	}
+	return true, err
+}
+
+func (cn *console) setErr(err error, retryAt time.Time) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	cn.lastErr = err
+	cn.retryAt = retryAt
+}
+
+// backoff returns how long to wait before reconnecting after the given number
+// of consecutive failures.
+func backoff(min, max time.Duration, failures int) time.Duration {
+	d := min
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// ServeHTTP serves the last lines of a blade's console as plain text at
+// /console/<slot>, with the number of lines set by the lines query parameter.
+// Anything else under /console/ gets the Status of each console as JSON.
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(req.URL.Path, "/console"), "/")
+	if rest == "" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r.Status()); err != nil {
+			log.Printf("failed to write console status: %v", err)
+		}
+		return
+	}
+
+	slot, err := strconv.Atoi(rest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid slot %q", rest), http.StatusBadRequest)
+		return
+	}
+	n := 0
+	if v := req.URL.Query().Get("lines"); v != "" {
+		if n, err = strconv.Atoi(v); err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("invalid number of lines %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+	lines, ok := r.Lines(slot, n)
+	if !ok {
+		http.Error(w, fmt.Sprintf("not recording the console for slot %d", slot), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, l := range lines {
+		if _, err := io.WriteString(w, l+"\n"); err != nil {
+			log.Printf("failed to write console for slot %d: %v", slot, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package console
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecord(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	r, dir := newSimRecorder(t, bmc)
+
+	r.SetHost(3, bmc.Host)
+	waitFor(t, bmc.SOLActive)
+	bmc.WriteConsole([]byte("Booting...\r\nKernel panic - not syncing: Fatal exception\r\nRebooting in 30"))
+
+	want := []string{"Booting...", "Kernel panic - not syncing: Fatal exception", "Rebooting in 30"}
+	waitForLines(t, r, 3, want)
+
+	// The BMC gets reset, and forgets about our session.
+	bmc.Reset()
+	waitFor(t, func() bool {
+		s := r.Status()
+		return len(s) == 1 && s[0].Reconnects == 1 && s[0].Connected && bmc.SOLActive()
+	})
+	bmc.WriteConsole([]byte(" seconds..\r\n"))
+	want[2] = "Rebooting in 30 seconds.."
+	waitForLines(t, r, 3, want)
+
+	r.Close()
+	dat, err := os.ReadFile(filepath.Join(dir, "console-3.log"))
+	if err != nil {
+		t.Fatalf("failed to read console log: %v", err)
+	}
+	if got, want := string(dat), "Booting...\r\nKernel panic - not syncing: Fatal exception\r\nRebooting in 30 seconds..\r\n"; got != want {
+		t.Errorf("console log = %q, want %q", got, want)
+	}
+	if bmc.SOLActive() {
+		t.Error("SOL still active after Close")
+	}
+}
+
+func TestTakeover(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(t, bmc)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Someone's already watching the console.
+	other, err := c.SOL(ctx, bmc.Host)
+	if err != nil {
+		t.Fatalf("SOL: %v", err)
+	}
+	defer other.Close()
+
+	// By default, we leave them be.
+	polite, _ := newSimRecorder(t, bmc)
+	polite.SetHost(1, bmc.Host)
+	waitFor(t, func() bool {
+		s := polite.Status()
+		return len(s) == 1 && !s[0].Connected && strings.Contains(s[0].LastError, ipmi.ErrSOLActive.Error())
+	})
+	polite.Close()
+
+	// Unless we're told to take over.
+	r, _ := newSimRecorder(t, bmc, WithTakeover())
+	r.SetHost(1, bmc.Host)
+	if _, err := other.Read(make([]byte, 10)); err != io.EOF {
+		t.Fatalf("Read on the session we took over = %v, want io.EOF", err)
+	}
+	waitFor(t, func() bool {
+		s := r.Status()
+		return len(s) == 1 && s[0].Connected
+	})
+
+	// But once something takes it back, we don't fight over it.
+	other, err = c.SOL(ctx, bmc.Host, ipmi.WithSOLTakeover())
+	if err != nil {
+		t.Fatalf("SOL with takeover: %v", err)
+	}
+	defer other.Close()
+	waitFor(t, func() bool {
+		s := r.Status()
+		return len(s) == 1 && !s[0].Connected
+	})
+	// Long enough for a few reconnects.
+	time.Sleep(300 * time.Millisecond)
+	bmc.WriteConsole([]byte("still mine"))
+	got := make([]byte, len("still mine"))
+	if _, err := io.ReadFull(other, got); err != nil {
+		t.Fatalf("the recorder took the console back: %v", err)
+	}
+
+	// Once they're done, we pick it up again.
+	other.Close()
+	waitFor(t, func() bool {
+		s := r.Status()
+		return len(s) == 1 && s[0].Connected
+	})
+}
+
+func TestSetHost(t *testing.T) {
+	bmc1 := ipmisim.New(t, "root", "calvin")
+	bmc2 := ipmisim.New(t, "root", "calvin")
+	// Both simulators are on 127.0.0.1, so we make up host names for them.
+	clients := map[string]*ipmi.Client{}
+	for name, bmc := range map[string]*ipmisim.Server{"one": bmc1, "two": bmc2} {
+		c := ipmi.New("root", "calvin", ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+		defer c.Close()
+		clients[name] = c
+	}
+	r := New(func(ctx context.Context, host string, takeover bool) (Session, error) {
+		sess, err := clients[host].SOL(ctx, bmc1.Host)
+		if err != nil {
+			return nil, err
+		}
+		return sess, nil
+	}, t.TempDir(), WithKeepAlive(100*time.Millisecond), WithBackoff(50*time.Millisecond, 50*time.Millisecond))
+	defer r.Close()
+
+	r.SetHost(1, "one")
+	waitFor(t, bmc1.SOLActive)
+	r.SetHost(1, "two")
+	waitFor(t, func() bool { return bmc2.SOLActive() && !bmc1.SOLActive() })
+
+	r.SetHost(1, "")
+	waitFor(t, func() bool { return !bmc2.SOLActive() })
+	if _, ok := r.Lines(1, 0); ok {
+		t.Error("still recording slot 1 after clearing its host")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	r, _ := newSimRecorder(t, bmc)
+	r.SetHost(3, bmc.Host)
+	waitFor(t, bmc.SOLActive)
+	bmc.WriteConsole([]byte("one\r\ntwo\r\nthree\r\n"))
+	waitForLines(t, r, 3, []string{"one", "two", "three"})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"/console/3", http.StatusOK, "one\ntwo\nthree\n"},
+		{"/console/3?lines=2", http.StatusOK, "two\nthree\n"},
+		{"/console/3?lines=abc", http.StatusBadRequest, ""},
+		{"/console/4", http.StatusNotFound, ""},
+		{"/console/abc", http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		resp, err := http.Get(srv.URL + test.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", test.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.wantCode {
+			t.Errorf("GET %s returned %d, want %d", test.path, resp.StatusCode, test.wantCode)
+			continue
+		}
+		if test.wantBody != "" && string(body) != test.wantBody {
+			t.Errorf("GET %s = %q, want %q", test.path, body, test.wantBody)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/console/")
+	if err != nil {
+		t.Fatalf("GET /console/: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `"Connected": true`) {
+		t.Errorf("console status = %s, want it to be connected", body)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	rf, err := openRotatingFile(dir, "console-1.log", 10, 2)
+	if err != nil {
+		t.Fatalf("openRotatingFile: %v", err)
+	}
+	for _, s := range []string{"aaaa", "bbbb", "cccc", "dddd", "eeee", "ffffffffffff", "g"} {
+		if _, err := rf.Write([]byte(s)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := rf.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want := map[string]string{
+		"console-1.log":   "g",
+		"console-1.log.1": "ffffffffffff",
+		"console-1.log.2": "eeee",
+	}
+	got := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, e := range entries {
+		dat, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		got[e.Name()] = string(dat)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected log files (-want +got)\n%s", diff)
+	}
+
+	// Reopening appends to what's there.
+	rf, err = openRotatingFile(dir, "console-1.log", 10, 2)
+	if err != nil {
+		t.Fatalf("openRotatingFile: %v", err)
+	}
+	rf.Write([]byte("h"))
+	rf.Close()
+	if dat, _ := os.ReadFile(filepath.Join(dir, "console-1.log")); string(dat) != "gh" {
+		t.Errorf("reopened log = %q, want %q", dat, "gh")
+	}
+}
+
+func TestLineBuffer(t *testing.T) {
+	lb := newLineBuffer(3)
+	lb.Write([]byte("one\r\ntwo\r\nthr"))
+	lb.Write([]byte("ee\r\nfour\r\nfi"))
+
+	if diff := cmp.Diff([]string{"two", "three", "four", "fi"}, lb.last(0)); diff != "" {
+		t.Errorf("unexpected lines (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"four", "fi"}, lb.last(2)); diff != "" {
+		t.Errorf("unexpected last 2 lines (-want +got)\n%s", diff)
+	}
+
+	lb = newLineBuffer(3)
+	lb.Write([]byte(strings.Repeat("x", maxLineLength+1)))
+	if got := lb.last(0); len(got) != 2 || len(got[0]) != maxLineLength {
+		t.Errorf("long line wasn't split at %d bytes", maxLineLength)
+	}
+}
+
+func newSimRecorder(t *testing.T, bmc *ipmisim.Server, opts ...Option) (*Recorder, string) {
+	t.Helper()
+	c := newSimClient(t, bmc)
+	dial := func(ctx context.Context, host string, takeover bool) (Session, error) {
+		var solOpts []ipmi.SOLOption
+		if takeover {
+			solOpts = append(solOpts, ipmi.WithSOLTakeover())
+		}
+		sess, err := c.SOL(ctx, host, solOpts...)
+		if err != nil {
+			return nil, err
+		}
+		return sess, nil
+	}
+	dir := t.TempDir()
+	opts = append([]Option{WithKeepAlive(100 * time.Millisecond), WithBackoff(50*time.Millisecond, 50*time.Millisecond)}, opts...)
+	r := New(dial, dir, opts...)
+	t.Cleanup(r.Close)
+	return r, dir
+}
+
+func newSimClient(t *testing.T, bmc *ipmisim.Server) *ipmi.Client {
+	t.Helper()
+	c := ipmi.New("root", "calvin", ipmi.WithHostConfig(bmc.Host, ipmi.Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func waitForLines(t *testing.T, r *Recorder, slot int, want []string) {
+	t.Helper()
+	waitFor(t, func() bool {
+		got, _ := r.Lines(slot, 0)
+		return cmp.Equal(want, got)
+	})
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
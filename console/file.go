@@ -0,0 +1,90 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rotatingFile is a log file that's rotated once it gets too big: name is
+// renamed to name.1, name.1 to name.2 and so on, and anything past
+// name.<maxFiles> is deleted.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	f    *os.File
+	size int64
+}
+
+// openRotatingFile opens dir/name for appending, creating dir if it needs to.
+func openRotatingFile(dir, name string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log dir: %w", err)
+	}
+	rf := &rotatingFile{path: filepath.Join(dir, name), maxSize: maxSize, maxFiles: maxFiles}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	rf.f, rf.size = f, fi.Size()
+	return nil
+}
+
+// Write writes p to the file, rotating it first if p would make it too big.
+// Writes are never split across files, so a single write bigger than the
+// limit gets a file to itself.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	if rf.maxFiles < 1 {
+		if err := os.Remove(rf.path); err != nil {
+			return fmt.Errorf("failed to remove log file: %w", err)
+		}
+		return rf.open()
+	}
+
+	for i := rf.maxFiles - 1; i >= 1; i-- {
+		err := os.Rename(rf.rotatedPath(i), rf.rotatedPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(rf.path, rf.rotatedPath(1)); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return rf.open()
+}
+
+func (rf *rotatingFile) rotatedPath(i int) string {
+	return fmt.Sprintf("%s.%d", rf.path, i)
+}
+
+func (rf *rotatingFile) Close() error {
+	return rf.f.Close()
+}
@@ -0,0 +1,77 @@
+package console
+
+import (
+	"strings"
+	"sync"
+)
+
+// lineBuffer keeps the last few lines written to it. Consoles send "\r\n"
+// line endings, and the odd bare "\r" to redraw a line, so we drop carriage
+// returns entirely. Lines longer than maxLineLength are split.
+type lineBuffer struct {
+	mu sync.Mutex
+	// lines is a ring buffer, with the oldest line at start once it's full.
+	lines []string
+	start int
+	max   int
+	// partial is the last line, which hasn't been terminated yet.
+	partial strings.Builder
+}
+
+// maxLineLength is long enough for any reasonable console line, and stops
+// something that never prints a newline from using up all our memory.
+const maxLineLength = 4096
+
+func newLineBuffer(max int) *lineBuffer {
+	if max < 1 {
+		max = 1
+	}
+	return &lineBuffer{max: max}
+}
+
+func (lb *lineBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	for _, b := range p {
+		switch b {
+		case '\r':
+		case '\n':
+			lb.add(lb.partial.String())
+			lb.partial.Reset()
+		default:
+			lb.partial.WriteByte(b)
+			if lb.partial.Len() >= maxLineLength {
+				lb.add(lb.partial.String())
+				lb.partial.Reset()
+			}
+		}
+	}
+	return len(p), nil
+}
+
+func (lb *lineBuffer) add(line string) {
+	if len(lb.lines) < lb.max {
+		lb.lines = append(lb.lines, line)
+		return
+	}
+	lb.lines[lb.start] = line
+	lb.start = (lb.start + 1) % lb.max
+}
+
+// last returns the last n lines, oldest first, including the unterminated
+// one if there is one. If n is zero, it returns all of them.
+func (lb *lineBuffer) last(n int) []string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	all := make([]string, 0, len(lb.lines)+1)
+	all = append(all, lb.lines[lb.start:]...)
+	all = append(all, lb.lines[:lb.start]...)
+	if lb.partial.Len() > 0 {
+		all = append(all, lb.partial.String())
+	}
+	if n > 0 && n < len(all) {
+		return all[len(all)-n:]
+	}
+	return all
+}
@@ -3,15 +3,17 @@ module github.com/bcspragu/m1000e-prom
 go 1.20
 
 require (
-	github.com/bougou/go-ipmi v0.4.0
+	github.com/bougou/go-ipmi v0.7.7
 	github.com/google/go-cmp v0.5.9
 	github.com/prometheus/client_golang v1.14.0
 	golang.org/x/crypto v0.7.0
+	golang.org/x/sync v0.1.0
 )
 
 require (
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/google/uuid v1.1.2 // indirect
 	github.com/kr/pretty v0.3.0 // indirect
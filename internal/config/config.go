@@ -0,0 +1,133 @@
+// Package config is the IPMI section of the creds file, which the exporter and
+// ipmitest both read.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi"
+)
+
+// IPMICreds is how we log in and connect to the blades' BMCs.
+type IPMICreds struct {
+	User     string
+	Password string
+	// Credentials are tried first for matching blades, keyed by slot number,
+	// server name or iDRAC IP address.
+	Credentials map[string]*IPMICredentials
+	// Fallback credentials are tried in order for every blade, after its own
+	// and the ones above.
+	Fallback []*IPMICredentials
+
+	// Workers is how many blades to poll over IPMI at once.
+	Workers int
+	// HostTimeout is how long to spend polling each blade, e.g. "20s".
+	HostTimeout Duration
+
+	// How to connect to BMCs by default.
+	IPMIConfig
+	// Hosts overrides the connection settings for individual BMCs, keyed by
+	// IP address.
+	Hosts map[string]IPMIConfig
+}
+
+// BladeCredentials returns the credentials for specific blades, keyed like
+// Credentials, and named for their key unless they have a name of their own.
+func (ic *IPMICreds) BladeCredentials() map[string]ipmi.Credentials {
+	out := make(map[string]ipmi.Credentials)
+	for key, cred := range ic.Credentials {
+		out[key] = cred.ToIPMI(key)
+	}
+	return out
+}
+
+// FallbackCredentials returns the fallback credentials in order, named
+// "fallback-N" for the Nth unless they have a name of their own.
+func (ic *IPMICreds) FallbackCredentials() []ipmi.Credentials {
+	var out []ipmi.Credentials
+	for i, cred := range ic.Fallback {
+		out = append(out, cred.ToIPMI(fmt.Sprintf("fallback-%d", i+1)))
+	}
+	return out
+}
+
+// Options returns the options for an ipmi.Client that connects to BMCs and
+// falls back to other credentials like the config says. It doesn't include
+// the blade-specific credentials, which are set per host.
+func (ic *IPMICreds) Options() ([]ipmi.Option, error) {
+	defaults := ic.IPMIConfig.ToIPMI()
+	if err := defaults.Validate(); err != nil {
+		return nil, err
+	}
+	opts := []ipmi.Option{ipmi.WithDefaults(defaults)}
+	if fallback := ic.FallbackCredentials(); len(fallback) > 0 {
+		opts = append(opts, ipmi.WithFallbackCredentials(fallback...))
+	}
+	for host, hc := range ic.Hosts {
+		cfg := hc.ToIPMI()
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("for host %q: %w", host, err)
+		}
+		opts = append(opts, ipmi.WithHostConfig(host, cfg))
+	}
+	return opts, nil
+}
+
+// IPMICredentials are a set of credentials for BMCs.
+type IPMICredentials struct {
+	// Name is what we call the credentials in logs and metrics. It defaults to
+	// the key in IPMICreds.Credentials, or "fallback-N" for the Nth fallback.
+	Name     string
+	User     string
+	Password string
+}
+
+// ToIPMI returns the credentials, named defaultName if they don't have a name.
+func (ic *IPMICredentials) ToIPMI(defaultName string) ipmi.Credentials {
+	name := ic.Name
+	if name == "" {
+		name = defaultName
+	}
+	return ipmi.Credentials{Name: name, User: ic.User, Password: ic.Password}
+}
+
+// IPMIConfig is how we connect to a BMC, see ipmi.Config.
+type IPMIConfig struct {
+	// Interface is "lanplus" or "lan".
+	Interface   string
+	Port        int
+	CipherSuite int
+	Privilege   string
+	// Timeout is how long to wait for each response, e.g. "10s".
+	Timeout Duration
+}
+
+// ToIPMI returns the config as an ipmi.Config.
+func (ic IPMIConfig) ToIPMI() ipmi.Config {
+	return ipmi.Config{
+		Interface:     strings.ToLower(ic.Interface),
+		Port:          ic.Port,
+		CipherSuiteID: ic.CipherSuite,
+		Privilege:     ic.Privilege,
+		Timeout:       time.Duration(ic.Timeout),
+	}
+}
+
+// Duration is a time.Duration that's formatted like "30s" in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(dat []byte) error {
+	var s string
+	if err := json.Unmarshal(dat, &s); err != nil {
+		return fmt.Errorf("failed to unmarshal duration: %w", err)
+	}
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("failed to parse duration: %w", err)
+	}
+	*d = Duration(dur)
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIPMICreds(t *testing.T) {
+	dat := `{
+		"User": "root",
+		"Password": "calvin",
+		"Credentials": {
+			"3": {"User": "admin", "Password": "hunter2"},
+			"db-1": {"Name": "db", "User": "db", "Password": "db"}
+		},
+		"Fallback": [
+			{"User": "root", "Password": "root"},
+			{"Name": "factory", "User": "root", "Password": "calvin"}
+		],
+		"HostTimeout": "30s",
+		"Interface": "LANPLUS",
+		"Timeout": "5s",
+		"Hosts": {
+			"10.0.0.5": {"Interface": "lan", "Port": 6230}
+		}
+	}`
+	var ic IPMICreds
+	if err := json.Unmarshal([]byte(dat), &ic); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if got := time.Duration(ic.HostTimeout); got != 30*time.Second {
+		t.Errorf("host timeout = %v, want 30s", got)
+	}
+	wantConfig := ipmi.Config{Interface: "lanplus", Timeout: 5 * time.Second}
+	if diff := cmp.Diff(wantConfig, ic.IPMIConfig.ToIPMI()); diff != "" {
+		t.Errorf("unexpected default config (-want +got)\n%s", diff)
+	}
+
+	wantBlade := map[string]ipmi.Credentials{
+		"3":    {Name: "3", User: "admin", Password: "hunter2"},
+		"db-1": {Name: "db", User: "db", Password: "db"},
+	}
+	if diff := cmp.Diff(wantBlade, ic.BladeCredentials()); diff != "" {
+		t.Errorf("unexpected blade credentials (-want +got)\n%s", diff)
+	}
+	wantFallback := []ipmi.Credentials{
+		{Name: "fallback-1", User: "root", Password: "root"},
+		{Name: "factory", User: "root", Password: "calvin"},
+	}
+	if diff := cmp.Diff(wantFallback, ic.FallbackCredentials()); diff != "" {
+		t.Errorf("unexpected fallback credentials (-want +got)\n%s", diff)
+	}
+
+	if _, err := ic.Options(); err != nil {
+		t.Errorf("Options: %v", err)
+	}
+	ic.Hosts["10.0.0.6"] = IPMIConfig{Interface: "serial"}
+	if _, err := ic.Options(); err == nil {
+		t.Error("Options didn't fail with an invalid host interface")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"1m30s"`), &d); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if got := time.Duration(d); got != 90*time.Second {
+		t.Errorf("duration = %v, want 1m30s", got)
+	}
+	for _, bad := range []string{`90`, `"soon"`} {
+		if err := json.Unmarshal([]byte(bad), &d); err == nil {
+			t.Errorf("unmarshalled %s without an error", bad)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package ipmi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bougou/go-ipmi"
+)
+
+// All DCMI requests and responses start with the DCMI group extension ID.
+const dcmiGroupExtensionID = 0xdc
+
+// PowerReading is the output of the DCMI Get Power Reading command, which
+// reports power draw statistics over the BMC's sampling period.
+type PowerReading struct {
+	CurrentWatts uint16
+	MinWatts     uint16
+	MaxWatts     uint16
+	AverageWatts uint16
+	Timestamp    time.Time
+	// Period is the window that the min, max and average are over.
+	Period time.Duration
+	// Active is false if the BMC isn't currently measuring power, in which case
+	// the readings are meaningless.
+	Active bool
+}
+
+// PowerReading loads the current system power statistics from the host's BMC
+// over DCMI.
+func (c *Client) PowerReading(ctx context.Context, host string) (*PowerReading, error) {
+	resp := &getPowerReadingResponse{}
+	err := c.do(ctx, host, func(cn *conn) error {
+		if err := cn.ic.Exchange(ctx, &getPowerReadingRequest{}, resp); err != nil {
+			return fmt.Errorf("failed to get DCMI power reading: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resp.PowerReading, nil
+}
+
+// go-ipmi doesn't support DCMI, so we implement the one command we need. See
+// section 6.6.1 of the DCMI v1.5 spec.
+type getPowerReadingRequest struct{}
+
+func (*getPowerReadingRequest) Command() ipmi.Command {
+	return ipmi.Command{ID: 0x02, NetFn: ipmi.NetFnGroupExtensionRequest, Name: "DCMI Get Power Reading"}
+}
+
+func (*getPowerReadingRequest) Pack() []byte {
+	return []byte{
+		dcmiGroupExtensionID,
+		0x01,       // Mode: system power statistics
+		0x00, 0x00, // Reserved for the power limit modes we don't use
+	}
+}
+
+type getPowerReadingResponse struct {
+	PowerReading
+}
+
+func (r *getPowerReadingResponse) Unpack(msg []byte) error {
+	if len(msg) < 18 {
+		return errors.New("power reading response too short")
+	}
+	if msg[0] != dcmiGroupExtensionID {
+		return fmt.Errorf("unexpected group extension ID %#02x in power reading response", msg[0])
+	}
+	r.CurrentWatts = binary.LittleEndian.Uint16(msg[1:3])
+	r.MinWatts = binary.LittleEndian.Uint16(msg[3:5])
+	r.MaxWatts = binary.LittleEndian.Uint16(msg[5:7])
+	r.AverageWatts = binary.LittleEndian.Uint16(msg[7:9])
+	r.Timestamp = time.Unix(int64(binary.LittleEndian.Uint32(msg[9:13])), 0)
+	r.Period = time.Duration(binary.LittleEndian.Uint32(msg[13:17])) * time.Millisecond
+	r.Active = msg[17]&0x40 != 0
+	return nil
+}
+
+func (*getPowerReadingResponse) CompletionCodes() map[uint8]string {
+	return map[uint8]string{}
+}
+
+func (r *getPowerReadingResponse) Format() string {
+	return fmt.Sprintf("current %d W, min %d W, max %d W, average %d W over %s", r.CurrentWatts, r.MinWatts, r.MaxWatts, r.AverageWatts, r.Period)
+}
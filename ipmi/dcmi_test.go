@@ -0,0 +1,63 @@
+package ipmi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetPowerReadingResponseUnpack(t *testing.T) {
+	msg := []byte{
+		0xdc,       // Group extension ID
+		0x2c, 0x01, // Current: 300
+		0xb4, 0x00, // Min: 180
+		0xc2, 0x01, // Max: 450
+		0x18, 0x01, // Average: 280
+		0x00, 0x00, 0x00, 0x60, // Timestamp
+		0xe8, 0x03, 0x00, 0x00, // Period: 1000 ms
+		0x40, // Power measurement active
+	}
+
+	var resp getPowerReadingResponse
+	if err := resp.Unpack(msg); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+
+	want := PowerReading{
+		CurrentWatts: 300,
+		MinWatts:     180,
+		MaxWatts:     450,
+		AverageWatts: 280,
+		Timestamp:    time.Unix(0x60000000, 0),
+		Period:       time.Second,
+		Active:       true,
+	}
+	if diff := cmp.Diff(want, resp.PowerReading); diff != "" {
+		t.Errorf("unexpected power reading (-want +got)\n%s", diff)
+	}
+}
+
+func TestGetPowerReadingResponseUnpackErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		msg  []byte
+	}{
+		{
+			desc: "too short",
+			msg:  []byte{0xdc, 0x2c, 0x01},
+		},
+		{
+			desc: "wrong group extension",
+			msg:  append([]byte{0x00}, make([]byte, 17)...),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var resp getPowerReadingResponse
+			if err := resp.Unpack(test.msg); err == nil {
+				t.Error("Unpack succeeded, want an error")
+			}
+		})
+	}
+}
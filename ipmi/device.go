@@ -0,0 +1,59 @@
+package ipmi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bougou/go-ipmi"
+)
+
+// DeviceInfo identifies a BMC and its firmware.
+type DeviceInfo struct {
+	DeviceID       uint8
+	DeviceRevision uint8
+	// Firmware is the BMC's firmware revision, e.g. "2.92".
+	Firmware string
+	// IPMIVersion is the version of the IPMI spec the BMC implements, e.g.
+	// "2.0".
+	IPMIVersion string
+	// ManufacturerID is the manufacturer's IANA enterprise number, e.g. 674 for
+	// Dell, and ProductID is a manufacturer-specific model number.
+	ManufacturerID uint32
+	ProductID      uint16
+	// AuxFirmware is optional manufacturer-specific firmware revision info.
+	AuxFirmware []byte
+	// Available is false while the BMC is updating its firmware or SDR, or is
+	// still starting up.
+	Available bool
+}
+
+// DeviceInfo returns the host's BMC identity and firmware revision, from Get
+// Device ID.
+func (c *Client) DeviceInfo(ctx context.Context, host string) (*DeviceInfo, error) {
+	var res *ipmi.GetDeviceIDResponse
+	err := c.do(ctx, host, func(cn *conn) error {
+		var err error
+		if res, err = cn.ic.GetDeviceID(ctx); err != nil {
+			return fmt.Errorf("failed to get device ID: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromIPMIDeviceID(res), nil
+}
+
+func fromIPMIDeviceID(res *ipmi.GetDeviceIDResponse) *DeviceInfo {
+	return &DeviceInfo{
+		DeviceID:       res.DeviceID,
+		DeviceRevision: res.DeviceRevision,
+		// The minor revision is two BCD digits, so 2.05 comes through as 5.
+		Firmware:       fmt.Sprintf("%d.%02d", res.MajorFirmwareRevision, res.MinorFirmwareRevision),
+		IPMIVersion:    fmt.Sprintf("%d.%d", res.MajorIPMIVersion, res.MinorIPMIVersion),
+		ManufacturerID: res.ManufacturerID,
+		ProductID:      res.ProductID,
+		AuxFirmware:    res.AuxiliaryFirmwareRevision,
+		Available:      res.DeviceAvailable,
+	}
+}
@@ -0,0 +1,73 @@
+package ipmi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDeviceInfo(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		id   ipmisim.DeviceID
+		want *DeviceInfo
+	}{
+		{
+			// An iDRAC6 on an M610.
+			id: ipmisim.DeviceID{
+				DeviceID:       0x20,
+				DeviceRevision: 1,
+				FirmwareMajor:  3,
+				FirmwareMinor:  65,
+				ManufacturerID: 674,
+				ProductID:      0x0100,
+				AuxFirmware:    [4]byte{0x00, 0x0b, 0x00, 0x00},
+			},
+			want: &DeviceInfo{
+				DeviceID:       0x20,
+				DeviceRevision: 1,
+				Firmware:       "3.65",
+				IPMIVersion:    "2.0",
+				ManufacturerID: 674,
+				ProductID:      0x0100,
+				AuxFirmware:    []byte{0x00, 0x0b, 0x00, 0x00},
+				Available:      true,
+			},
+		},
+		{
+			// Mid firmware update, with a single digit minor revision.
+			id: ipmisim.DeviceID{
+				DeviceID:       0x20,
+				FirmwareMajor:  2,
+				FirmwareMinor:  5,
+				ManufacturerID: 674,
+				Unavailable:    true,
+			},
+			want: &DeviceInfo{
+				DeviceID:       0x20,
+				Firmware:       "2.05",
+				IPMIVersion:    "2.0",
+				ManufacturerID: 674,
+				AuxFirmware:    []byte{0, 0, 0, 0},
+				Available:      false,
+			},
+		},
+	}
+	for _, test := range tests {
+		bmc.SetDeviceID(test.id)
+		got, err := c.DeviceInfo(ctx, bmc.Host)
+		if err != nil {
+			t.Fatalf("DeviceInfo: %v", err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("unexpected device info (-want +got)\n%s", diff)
+		}
+	}
+}
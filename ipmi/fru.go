@@ -0,0 +1,69 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bougou/go-ipmi"
+)
+
+// FRU is the Field Replaceable Unit inventory data for a BMC's system board.
+type FRU struct {
+	// From the board info area.
+	BoardManufacturer string
+	BoardProduct      string
+	BoardSerial       string
+	BoardPartNumber   string
+	ManufactureDate   time.Time
+
+	// From the product info area, if the BMC has one. On Dell systems, the
+	// product serial is the service tag.
+	ProductName   string
+	ProductSerial string
+	AssetTag      string
+}
+
+// FRU reads and decodes the built-in FRU device (ID 0) on the host's BMC.
+func (c *Client) FRU(ctx context.Context, host string) (*FRU, error) {
+	var fru *ipmi.FRU
+	err := c.do(ctx, host, func(cn *conn) error {
+		var err error
+		if fru, err = cn.ic.GetFRU(ctx, 0, "Builtin FRU"); err != nil {
+			return fmt.Errorf("failed to read FRU: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !fru.Present() {
+		return nil, errors.New("BMC has no built-in FRU data")
+	}
+	return fromIPMIFRU(fru), nil
+}
+
+func fromIPMIFRU(fru *ipmi.FRU) *FRU {
+	out := &FRU{}
+	if b := fru.BoardInfoArea; b != nil {
+		out.BoardManufacturer = fruString(b.Manufacturer)
+		out.BoardProduct = fruString(b.ProductName)
+		out.BoardSerial = fruString(b.SerialNumber)
+		out.BoardPartNumber = fruString(b.PartNumber)
+		out.ManufactureDate = b.MfgDateTime.UTC()
+	}
+	if p := fru.ProductInfoArea; p != nil {
+		out.ProductName = fruString(p.Name)
+		out.ProductSerial = fruString(p.SerialNumber)
+		out.AssetTag = fruString(p.AssetTag)
+	}
+	return out
+}
+
+// fruString cleans up an (already unpacked) FRU field, which are often padded
+// out to a fixed width with spaces or NULs.
+func fruString(chars []byte) string {
+	return strings.TrimSpace(strings.TrimRight(string(chars), "\x00"))
+}
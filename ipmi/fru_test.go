@@ -0,0 +1,57 @@
+package ipmi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bougou/go-ipmi"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFromIPMIFRU(t *testing.T) {
+	// A board info area, in 8-bit ASCII except for the BCD serial.
+	board := []byte{
+		0x01,             // Format version
+		0x00,             // Length, filled in below
+		0x00,             // Language code
+		0xa0, 0x3b, 0x5a, // Manufactured 5913504 minutes after 1996-01-01
+		0xc4, 'D', 'E', 'L', 'L',
+		0xd0, 'P', 'o', 'w', 'e', 'r', 'E', 'd', 'g', 'e', ' ', 'M', '6', '1', '0', ' ', ' ',
+		0x43, 0x21, 0x43, 0x65, // BCD plus "123456"
+		0xc6, '0', 'N', '5', '8', '2', 'M',
+		0xc0, // Empty FRU file ID
+		0xc1, // End of fields
+	}
+	for len(board)%8 != 7 {
+		board = append(board, 0x00)
+	}
+	board = append(board, 0x00) // Checksum, which we don't check
+	board[1] = byte(len(board) / 8)
+
+	var boardArea ipmi.FRUBoardInfoArea
+	if err := boardArea.Unpack(board); err != nil {
+		t.Fatalf("failed to unpack board area: %v", err)
+	}
+
+	got := fromIPMIFRU(&ipmi.FRU{
+		BoardInfoArea: &boardArea,
+		ProductInfoArea: &ipmi.FRUProductInfoArea{
+			Name:         []byte("PowerEdge M610"),
+			SerialNumber: []byte("ABC1234"),
+			AssetTag:     []byte("\x00\x00\x00"),
+		},
+	})
+
+	want := &FRU{
+		BoardManufacturer: "DELL",
+		BoardProduct:      "PowerEdge M610",
+		BoardSerial:       "123456",
+		BoardPartNumber:   "0N582M",
+		ManufactureDate:   time.Date(2007, time.March, 30, 14, 24, 0, 0, time.UTC),
+		ProductName:       "PowerEdge M610",
+		ProductSerial:     "ABC1234",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected FRU (-want +got)\n%s", diff)
+	}
+}
@@ -1,33 +1,232 @@
 package ipmi
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/bougou/go-ipmi"
 )
 
+// Client talks IPMI to any number of BMCs. It's safe for concurrent use:
+// requests to different hosts run in parallel, and requests to the same host
+// are serialized, since BMCs (and go-ipmi sessions) don't deal well with
+// concurrent requests.
 type Client struct {
-	user    string
-	pass    string
-	clients map[string]*ipmi.Client
+	// creds are tried in order for every host, after any host-specific ones.
+	creds []Credentials
+	now   func() time.Time
+
+	defaults Config
+	// Keyed by host.
+	hostConfigs map[string]Config
+
+	mu sync.Mutex
+	// Keyed by host.
+	conns map[string]*conn
+	// Keyed by host.
+	hostCreds map[string][]Credentials
+	// closed is set by Close, after which we won't open any more sessions.
+	closed bool
+}
+
+// Credentials are a username and password for logging in to a BMC.
+type Credentials struct {
+	// Name identifies the credentials in logs and stats, so we don't have to
+	// print the password to say which ones worked.
+	Name     string
+	User     string
+	Password string
+}
+
+// DefaultCredentials is the name of the credentials passed to New.
+const DefaultCredentials = "default"
+
+type Option func(*Client)
+
+// Config controls how we connect to a BMC. Zero values mean "use the default".
+type Config struct {
+	// Interface is "lanplus" (IPMI v2.0/RMCP+, the default) or "lan" (IPMI
+	// v1.5).
+	Interface string
+	// Port is the BMC's UDP port, 623 by default.
+	Port int
+	// CipherSuiteID is the RMCP+ cipher suite to use with the lanplus
+	// interface. By default, we use the best suite the BMC supports. Because
+	// zero means the default, cipher suite 0 (no authentication) can't be
+	// requested, which is for the best.
+	CipherSuiteID int
+	// Privilege is the maximum privilege level to request for the session,
+	// one of "callback", "user", "operator" or "administrator" (the default).
+	Privilege string
+	// Timeout is how long to wait for each response from the BMC, 10 seconds
+	// by default.
+	Timeout time.Duration
+}
+
+var defaultConfig = Config{
+	Interface: string(ipmi.InterfaceLanplus),
+	Port:      623,
+	Privilege: "administrator",
+	Timeout:   10 * time.Second,
+}
+
+var privilegeLevels = map[string]ipmi.PrivilegeLevel{
+	"callback":      ipmi.PrivilegeLevelCallback,
+	"user":          ipmi.PrivilegeLevelUser,
+	"operator":      ipmi.PrivilegeLevelOperator,
+	"administrator": ipmi.PrivilegeLevelAdministrator,
+}
+
+// Validate reports whether the config's values are all valid.
+func (cfg Config) Validate() error {
+	switch ipmi.Interface(cfg.Interface) {
+	case "", ipmi.InterfaceLan, ipmi.InterfaceLanplus:
+	default:
+		return fmt.Errorf("unknown interface %q, must be lan or lanplus", cfg.Interface)
+	}
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return fmt.Errorf("invalid port %d", cfg.Port)
+	}
+	if cfg.CipherSuiteID < 0 || cfg.CipherSuiteID > 0xff {
+		return fmt.Errorf("invalid cipher suite ID %d", cfg.CipherSuiteID)
+	}
+	if _, ok := privilegeLevels[strings.ToLower(cfg.Privilege)]; cfg.Privilege != "" && !ok {
+		return fmt.Errorf("unknown privilege level %q", cfg.Privilege)
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %s", cfg.Timeout)
+	}
+	return nil
+}
+
+// merge returns cfg with any non-zero fields in override replacing its own.
+func (cfg Config) merge(override Config) Config {
+	if override.Interface != "" {
+		cfg.Interface = override.Interface
+	}
+	if override.Port != 0 {
+		cfg.Port = override.Port
+	}
+	if override.CipherSuiteID != 0 {
+		cfg.CipherSuiteID = override.CipherSuiteID
+	}
+	if override.Privilege != "" {
+		cfg.Privilege = override.Privilege
+	}
+	if override.Timeout != 0 {
+		cfg.Timeout = override.Timeout
+	}
+	return cfg
+}
+
+// WithDefaults sets the config for all hosts that don't have their own, see
+// WithHostConfig.
+func WithDefaults(cfg Config) Option {
+	return func(c *Client) {
+		c.defaults = c.defaults.merge(cfg)
+	}
+}
+
+// WithHostConfig sets the config for a single host, on top of the defaults.
+func WithHostConfig(host string, cfg Config) Option {
+	return func(c *Client) {
+		c.hostConfigs[host] = cfg
+	}
+}
+
+// WithFallbackCredentials adds credentials to try, in order, for hosts that
+// don't accept the ones passed to New, e.g. Dell's default root/calvin.
+func WithFallbackCredentials(creds ...Credentials) Option {
+	return func(c *Client) {
+		c.creds = append(c.creds, creds...)
+	}
+}
+
+// New returns a client that logs in to BMCs with the given username and
+// password, and any fallback credentials from WithFallbackCredentials.
+func New(user, pass string, opts ...Option) *Client {
+	c := &Client{
+		creds:       []Credentials{{Name: DefaultCredentials, User: user, Password: pass}},
+		now:         time.Now,
+		defaults:    defaultConfig,
+		hostConfigs: make(map[string]Config),
+		conns:       make(map[string]*conn),
+		hostCreds:   make(map[string][]Credentials),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Config returns the config we use for the given host.
+func (c *Client) Config(host string) Config {
+	return c.defaults.merge(c.hostConfigs[host])
 }
 
-func New(user, pass string) *Client {
-	return &Client{
-		user:    user,
-		pass:    pass,
-		clients: make(map[string]*ipmi.Client),
+// SetHostCredentials sets credentials to try for the given host before the
+// ones every host gets. They're used the next time we connect to the host, and
+// don't affect an existing session.
+func (c *Client) SetHostCredentials(host string, creds ...Credentials) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hostCreds[host] = creds
+}
+
+// credentials returns the credentials to try for the given host, in order.
+// The credentials named preferred, which are usually the ones that worked
+// last time, go first.
+func (c *Client) credentials(host, preferred string) []Credentials {
+	c.mu.Lock()
+	all := append(append([]Credentials{}, c.hostCreds[host]...), c.creds...)
+	c.mu.Unlock()
+
+	type userPass struct{ user, pass string }
+	seen := make(map[userPass]bool)
+	var out []Credentials
+	for _, cred := range all {
+		up := userPass{cred.User, cred.Password}
+		if seen[up] {
+			continue
+		}
+		seen[up] = true
+		if cred.Name == preferred {
+			out = append([]Credentials{cred}, out...)
+		} else {
+			out = append(out, cred)
+		}
 	}
+	return out
 }
 
 func (c *Client) Close() error {
+	// We can't hold c.mu while waiting on the conns, since whoever holds one
+	// may need c.mu to reconnect.
+	c.mu.Lock()
+	c.closed = true
+	conns := make([]*conn, 0, len(c.conns))
+	for _, cn := range c.conns {
+		conns = append(conns, cn)
+	}
+	c.mu.Unlock()
+
 	var errs []error
-	for _, client := range c.clients {
-		if err := client.Close(); err != nil {
-			errs = append(errs, err)
+	for _, cn := range conns {
+		// Wait for anything in flight to finish.
+		cn.sem <- struct{}{}
+		if cn.ic != nil {
+			if err := closeSession(cn.ic); err != nil {
+				errs = append(errs, err)
+			}
+			cn.ic = nil
 		}
+		<-cn.sem
 	}
 	if len(errs) > 0 {
 		return errors.Join(errs...)
@@ -35,30 +234,93 @@ func (c *Client) Close() error {
 	return nil
 }
 
-func (c *Client) AmbientTemp(host string, port int) (float64, error) {
-	// Note: We assume this isn't accessed concurrently for now.
-
-	ic, ok := c.clients[host]
-	if !ok {
-		log.Printf("initing IPMI to host %q", host)
+// connect opens a session with the host, trying each of its credentials in
+// turn until one works. It returns the name of the credentials that worked.
+func (c *Client) connect(ctx context.Context, host, preferred string) (*ipmi.Client, string, error) {
+	cfg := c.Config(host)
+	if err := cfg.Validate(); err != nil {
+		return nil, "", fmt.Errorf("invalid IPMI config for %q: %w", host, err)
+	}
 
-		tmp, err := ipmi.NewClient(host, port, c.user, c.pass)
-		if err != nil {
-			return 0, fmt.Errorf("failed to init IPMI client: %w", err)
+	var errs []error
+	for _, cred := range c.credentials(host, preferred) {
+		ic, err := c.connectWith(ctx, host, cfg, cred)
+		if err == nil {
+			return ic, cred.Name, nil
 		}
-		tmp.Interface = ipmi.InterfaceLanplus
-
-		if err := tmp.Connect(); err != nil {
-			return 0, fmt.Errorf("failed to connect to server at %q over IPMI: %w", host, err)
+		errs = append(errs, fmt.Errorf("with %s credentials: %w", cred.Name, err))
+		if Unreachable(err) {
+			// Other credentials won't help if we can't talk to the BMC at all.
+			break
 		}
-		ic = tmp
-		c.clients[host] = ic
 	}
+	return nil, "", errors.Join(errs...)
+}
 
-	sdr, err := ic.GetSDRBySensorName("Ambient Temp")
+// Unreachable reports whether a failed connection was because the BMC didn't
+// answer (or we ran out of time), rather than because it turned us away.
+func Unreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.Canceled)
+}
+
+func (c *Client) connectWith(ctx context.Context, host string, cfg Config, cred Credentials) (*ipmi.Client, error) {
+	log.Printf("initing IPMI to host %q with %s credentials", host, cred.Name)
+
+	ic, err := ipmi.NewClient(host, cfg.Port, cred.User, cred.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init IPMI client: %w", err)
+	}
+	ic.WithInterface(ipmi.Interface(cfg.Interface))
+	ic.WithTimeout(cfg.Timeout)
+	ic.WithMaxPrivilegeLevel(privilegeLevels[strings.ToLower(cfg.Privilege)])
+	if cfg.CipherSuiteID != 0 {
+		ic.WithCipherSuiteID(ipmi.CipherSuiteID(cfg.CipherSuiteID))
+	}
+
+	if err := ic.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect to server at %q over IPMI: %w", host, err)
+	}
+	return ic, nil
+}
+
+// TestCredentials checks whether the host's BMC accepts the given
+// credentials, by opening a session with them and closing it again. It
+// doesn't affect our session with the host, or which credentials we try
+// first.
+func (c *Client) TestCredentials(ctx context.Context, host string, cred Credentials) error {
+	cfg := c.Config(host)
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid IPMI config for %q: %w", host, err)
+	}
+	ic, err := c.connectWith(ctx, host, cfg, cred)
 	if err != nil {
-		return 0, fmt.Errorf("failed to load sdr ambient temp: %w", err)
+		return err
 	}
+	return closeSession(ic)
+}
 
-	return sdr.Full.SensorValue, nil
-}
\ No newline at end of file
+// AmbientTemp returns the reading of the host's "Ambient Temp" sensor.
+func (c *Client) AmbientTemp(ctx context.Context, host string) (float64, error) {
+	var temp float64
+	err := c.do(ctx, host, func(cn *conn) error {
+		sdrs, err := cn.sdr(ctx)
+		if err != nil {
+			return err
+		}
+		s := sdrs.byName("Ambient Temp")
+		if s == nil {
+			return errors.New("no ambient temp sensor in SDR")
+		}
+		reading, err := cn.read(ctx, s)
+		if err != nil {
+			return err
+		}
+		if !reading.HasReading {
+			return fmt.Errorf("ambient temp sensor has no reading (status %s)", reading.Status)
+		}
+		temp = reading.Value
+		return nil
+	})
+	return temp, err
+}
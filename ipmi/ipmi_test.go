@@ -0,0 +1,281 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestConfig(t *testing.T) {
+	c := New("root", "calvin",
+		WithDefaults(Config{Timeout: 5 * time.Second}),
+		WithHostConfig("10.0.0.6", Config{Interface: "lan", Port: 6230}),
+		WithHostConfig("10.0.0.7", Config{CipherSuiteID: 3, Privilege: "operator"}),
+	)
+
+	tests := []struct {
+		host string
+		want Config
+	}{
+		{
+			host: "10.0.0.5",
+			want: Config{Interface: "lanplus", Port: 623, Privilege: "administrator", Timeout: 5 * time.Second},
+		},
+		{
+			host: "10.0.0.6",
+			want: Config{Interface: "lan", Port: 6230, Privilege: "administrator", Timeout: 5 * time.Second},
+		},
+		{
+			host: "10.0.0.7",
+			want: Config{Interface: "lanplus", Port: 623, CipherSuiteID: 3, Privilege: "operator", Timeout: 5 * time.Second},
+		},
+	}
+	for _, test := range tests {
+		if diff := cmp.Diff(test.want, c.Config(test.host)); diff != "" {
+			t.Errorf("unexpected config for %q (-want +got)\n%s", test.host, diff)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := []Config{
+		{},
+		{Interface: "lan"},
+		{Interface: "lanplus", CipherSuiteID: 17, Privilege: "Administrator"},
+	}
+	for _, cfg := range valid {
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want no error", cfg, err)
+		}
+	}
+
+	invalid := []Config{
+		{Interface: "serial"},
+		{Port: 70000},
+		{CipherSuiteID: 256},
+		{Privilege: "root"},
+		{Timeout: -time.Second},
+	}
+	for _, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	std := Credentials{Name: "standard", User: "admin", Password: "hunter2"}
+	dell := Credentials{Name: "dell-default", User: "root", Password: "calvin"}
+	c := New(std.User, std.Password, WithFallbackCredentials(dell))
+
+	blade := Credentials{Name: "slot-3", User: "admin", Password: "s3cret"}
+	c.SetHostCredentials("10.0.0.3", blade)
+	// Same as the default credentials, so it shouldn't be tried twice.
+	c.SetHostCredentials("10.0.0.4", Credentials{Name: "slot-4", User: std.User, Password: std.Password})
+
+	def := Credentials{Name: DefaultCredentials, User: std.User, Password: std.Password}
+	tests := []struct {
+		host      string
+		preferred string
+		want      []Credentials
+	}{
+		{
+			host: "10.0.0.2",
+			want: []Credentials{def, dell},
+		},
+		{
+			host:      "10.0.0.2",
+			preferred: "dell-default",
+			want:      []Credentials{dell, def},
+		},
+		{
+			host: "10.0.0.3",
+			want: []Credentials{blade, def, dell},
+		},
+		{
+			host:      "10.0.0.3",
+			preferred: "dell-default",
+			want:      []Credentials{dell, blade, def},
+		},
+		{
+			host: "10.0.0.4",
+			want: []Credentials{{Name: "slot-4", User: std.User, Password: std.Password}, dell},
+		},
+	}
+	for _, test := range tests {
+		got := c.credentials(test.host, test.preferred)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("unexpected credentials for %q, preferring %q (-want +got)\n%s", test.host, test.preferred, diff)
+		}
+	}
+}
+
+func TestAmbientTemp(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x01, Name: "Inlet Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 19)
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 23, 25)
+
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, want := range []float64{23, 25} {
+		got, err := c.AmbientTemp(ctx, bmc.Host)
+		if err != nil {
+			t.Fatalf("AmbientTemp: %v", err)
+		}
+		if got != want {
+			t.Errorf("AmbientTemp = %g, want %g", got, want)
+		}
+	}
+
+	// Both reads should have used the same session.
+	if n := bmc.ActiveSessions(); n != 1 {
+		t.Errorf("BMC has %d active sessions, want 1", n)
+	}
+}
+
+func TestSensorsFromSimulator(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 23)
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x30, Name: "FAN 1 RPM", Type: ipmisim.TypeFan, Unit: ipmisim.UnitRPM, M: 120}, 5400)
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x40, Name: "12V", Type: ipmisim.TypeVoltage, Unit: ipmisim.UnitVolts, RExp: -1}, 12.1)
+	// No readings, so it'll report that the reading is unavailable.
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x50, Name: "Exhaust Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC})
+
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	got, err := c.Sensors(ctx, bmc.Host)
+	if err != nil {
+		t.Fatalf("Sensors: %v", err)
+	}
+
+	want := []*Sensor{
+		{Number: 0x0e, Name: "Ambient Temp", Type: "Temperature", Unit: "degrees C", Value: 23, HasReading: true, Status: "ok"},
+		{Number: 0x30, Name: "FAN 1 RPM", Type: "Fan", Unit: "RPM", Value: 5400, HasReading: true, Status: "ok"},
+		{Number: 0x40, Name: "12V", Type: "Voltage", Unit: "Volts", Value: 12.1, HasReading: true, Status: "ok"},
+		{Number: 0x50, Name: "Exhaust Temp", Type: "Temperature", Unit: "degrees C", HasReading: false, Status: "N/A"},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("unexpected sensors (-want +got)\n%s", diff)
+	}
+}
+
+func TestInjectedFailure(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 23)
+
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	bmc.Fail(ipmisim.GetSDR, ipmisim.CompletionNodeBusy)
+	if _, err := c.AmbientTemp(ctx, bmc.Host); err == nil {
+		t.Fatal("AmbientTemp succeeded with a busy BMC, want an error")
+	}
+	// The session is still fine, so we shouldn't have dropped it.
+	if s := c.SessionStats()[0]; s.State != SessionConnected || s.Evictions != 0 {
+		t.Errorf("after a failed command, session is %s with %d evictions, want connected with none", s.State, s.Evictions)
+	}
+
+	bmc.Fail(ipmisim.GetSDR, ipmisim.CompletionOK)
+	if got, err := c.AmbientTemp(ctx, bmc.Host); err != nil || got != 23 {
+		t.Errorf("AmbientTemp = %g, %v, want 23", got, err)
+	}
+}
+
+func TestBMCReset(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 23)
+
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := c.AmbientTemp(ctx, bmc.Host); err != nil {
+		t.Fatalf("AmbientTemp: %v", err)
+	}
+
+	// The BMC forgets our session, so the next request times out, and we should
+	// notice the session is dead and drop it.
+	bmc.Reset()
+	if _, err := c.AmbientTemp(ctx, bmc.Host); err == nil {
+		t.Fatal("AmbientTemp succeeded after a BMC reset, want an error")
+	}
+	if s := c.SessionStats()[0]; s.State != SessionDisconnected || s.Evictions != 1 {
+		t.Errorf("after a BMC reset, session is %s with %d evictions, want disconnected with 1", s.State, s.Evictions)
+	}
+
+	if got, err := c.AmbientTemp(ctx, bmc.Host); err != nil || got != 23 {
+		t.Fatalf("AmbientTemp = %g, %v, want 23", got, err)
+	}
+	if s := c.SessionStats()[0]; s.State != SessionConnected || s.Reconnects != 1 {
+		t.Errorf("after reconnecting, session is %s with %d reconnects, want connected with 1", s.State, s.Reconnects)
+	}
+}
+
+func TestCredentialFallback(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 23)
+
+	c := newSimClient(bmc, "admin", "hunter2",
+		WithFallbackCredentials(Credentials{Name: "dell-default", User: "root", Password: "calvin"}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := c.AmbientTemp(ctx, bmc.Host); err != nil {
+		t.Fatalf("AmbientTemp: %v", err)
+	}
+	if got := c.AcceptedCredentials(bmc.Host); got != "dell-default" {
+		t.Errorf("AcceptedCredentials = %q, want dell-default", got)
+	}
+
+	// A wrong password should fail the handshake too.
+	c = newSimClient(bmc, "root", "hunter2")
+	if _, err := c.AmbientTemp(ctx, bmc.Host); err == nil || errors.Is(err, ErrBackoff) {
+		t.Errorf("AmbientTemp with the wrong password returned %v, want an authentication error", err)
+	}
+}
+
+func TestTestCredentials(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.TestCredentials(ctx, bmc.Host, Credentials{Name: "good", User: "root", Password: "calvin"}); err != nil {
+		t.Errorf("TestCredentials with good credentials: %v", err)
+	}
+	if bmc.ActiveSessions() != 0 {
+		t.Errorf("TestCredentials left %d sessions open", bmc.ActiveSessions())
+	}
+
+	err := c.TestCredentials(ctx, bmc.Host, Credentials{Name: "bad", User: "root", Password: "hunter2"})
+	if err == nil || Unreachable(err) {
+		t.Errorf("TestCredentials with bad credentials returned %v, want them rejected", err)
+	}
+
+	bmc.SetUnresponsive(true)
+	err = c.TestCredentials(ctx, bmc.Host, Credentials{Name: "good", User: "root", Password: "calvin"})
+	if !Unreachable(err) {
+		t.Errorf("TestCredentials with an unresponsive BMC returned %v, want it unreachable", err)
+	}
+	if got := c.AcceptedCredentials(bmc.Host); got != "" {
+		t.Errorf("AcceptedCredentials = %q, want none, since we never polled the host", got)
+	}
+}
+
+// newSimClient returns a client for talking to the simulated BMC, with a short
+// timeout so tests of unresponsive BMCs don't take forever.
+func newSimClient(bmc *ipmisim.Server, user, pass string, opts ...Option) *Client {
+	opts = append(opts, WithHostConfig(bmc.Host, Config{Port: bmc.Port, Timeout: 500 * time.Millisecond}))
+	c := New(user, pass, opts...)
+	return c
+}
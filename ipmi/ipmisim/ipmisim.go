@@ -0,0 +1,1290 @@
+// Package ipmisim is a simulated BMC, for testing IPMI clients without a
+// chassis. It speaks just enough RMCP+ (IPMI v2.0 over LAN) to establish a
+// session and answer the commands we use to read sensors, with readings and
+// failures scripted by the test. It also does Serial over LAN, with console
+// output written by the test.
+//
+// Only cipher suites 3 and 17 are supported, and IPMI v1.5 (the "lan"
+// interface) isn't supported at all.
+package ipmisim
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// SensorType is the IPMI sensor type, see table 42-3 of the IPMI spec.
+type SensorType uint8
+
+const (
+	TypeTemperature SensorType = 0x01
+	TypeVoltage     SensorType = 0x02
+	TypeCurrent     SensorType = 0x03
+	TypeFan         SensorType = 0x04
+)
+
+// Unit is the IPMI sensor unit type, see table 43-15 of the IPMI spec.
+type Unit uint8
+
+const (
+	UnitDegreesC Unit = 1
+	UnitVolts    Unit = 4
+	UnitAmps     Unit = 5
+	UnitWatts    Unit = 6
+	UnitRPM      Unit = 18
+)
+
+// Sensor is a threshold sensor in the simulated BMC's SDR repository.
+type Sensor struct {
+	Number uint8
+	// Name is at most 16 characters.
+	Name string
+	Type SensorType
+	Unit Unit
+	// Readings are encoded as a signed byte, and converted back with
+	// value = M * raw * 10^RExp, like real BMCs do. M defaults to 1, so
+	// sensors that read above 127 (like fans) need a bigger M, and fractional
+	// ones (like voltages) need a negative RExp.
+	M    int16
+	RExp int8
+}
+
+// DeviceID is what the simulated BMC returns for Get Device ID.
+type DeviceID struct {
+	DeviceID       uint8
+	DeviceRevision uint8
+	FirmwareMajor  uint8
+	// FirmwareMinor is sent BCD-encoded, so it should be at most 99.
+	FirmwareMinor  uint8
+	ManufacturerID uint32
+	ProductID      uint16
+	AuxFirmware    [4]byte
+	// Unavailable means the device is busy, e.g. updating its firmware.
+	Unavailable bool
+}
+
+// Watchdog is what the simulated BMC returns for Get Watchdog Timer, see
+// section 27.7 of the IPMI spec.
+type Watchdog struct {
+	Running bool
+	// Use is the timer use, e.g. 0x04 for SMS/OS.
+	Use uint8
+	// Action is the timeout action, e.g. 0x01 for a hard reset.
+	Action uint8
+	// ExpirationFlags has bit N set if the timer has expired for use N.
+	ExpirationFlags uint8
+	// Countdowns are in tenths of a second.
+	InitialCountdown uint16
+	PresentCountdown uint16
+}
+
+// FRU is the BMC's built-in FRU inventory, see the IPMI Platform Management
+// FRU Information Storage Definition. Fields are at most 63 characters.
+type FRU struct {
+	BoardManufacturer string
+	BoardProduct      string
+	BoardSerial       string
+	BoardPartNumber   string
+	// ManufactureDate has minute resolution.
+	ManufactureDate time.Time
+	ProductName     string
+	// ProductSerial is the service tag on Dell blades.
+	ProductSerial string
+}
+
+// SELEntry is a standard event record in the BMC's System Event Log, see
+// section 32.1 of the IPMI spec.
+type SELEntry struct {
+	// Timestamp has second resolution.
+	Timestamp    time.Time
+	SensorType   uint8
+	SensorNumber uint8
+	// EventDirType has the event direction in the top bit (set for
+	// deassertions), and the event/reading type in the rest.
+	EventDirType uint8
+	EventData    [3]byte
+}
+
+// Handler answers a command with a completion code and response data. It's
+// called with the Server locked, so it can't call the Server's methods.
+type Handler func(data []byte) (completionCode uint8, resp []byte)
+
+// Command identifies an IPMI command by its network function and number.
+type Command struct {
+	NetFn uint8
+	Cmd   uint8
+}
+
+const (
+	netFnChassis     = 0x00
+	netFnSensorEvent = 0x04
+	netFnApp         = 0x06
+	netFnStorage     = 0x0a
+)
+
+// The commands the simulated BMC understands. Anything else gets
+// CompletionInvalidCommand.
+var (
+	GetChassisStatus           = Command{netFnChassis, 0x01}
+	GetDeviceID                = Command{netFnApp, 0x01}
+	GetSelfTestResults         = Command{netFnApp, 0x04}
+	GetWatchdogTimer           = Command{netFnApp, 0x25}
+	GetChannelAuthCapabilities = Command{netFnApp, 0x38}
+	SetSessionPrivilegeLevel   = Command{netFnApp, 0x3b}
+	CloseSession               = Command{netFnApp, 0x3c}
+	GetSessionInfo             = Command{netFnApp, 0x3d}
+	ActivatePayload            = Command{netFnApp, 0x48}
+	DeactivatePayload          = Command{netFnApp, 0x49}
+	GetChannelCipherSuites     = Command{netFnApp, 0x54}
+	GetFRUInventoryAreaInfo    = Command{netFnStorage, 0x10}
+	ReadFRUData                = Command{netFnStorage, 0x11}
+	GetSDRRepositoryInfo       = Command{netFnStorage, 0x20}
+	ReserveSDRRepository       = Command{netFnStorage, 0x22}
+	GetSDR                     = Command{netFnStorage, 0x23}
+	GetSELInfo                 = Command{netFnStorage, 0x40}
+	GetSELEntry                = Command{netFnStorage, 0x43}
+	GetSensorReading           = Command{netFnSensorEvent, 0x2d}
+)
+
+// Commands that can be sent before a session is established.
+var sessionlessCommands = []Command{GetChannelAuthCapabilities, GetChannelCipherSuites}
+
+// Dell's IANA enterprise number.
+const dellIANA = 674
+
+var defaultDeviceID = DeviceID{DeviceID: 0x20, FirmwareMajor: 1, ManufacturerID: dellIANA}
+
+// The most console output we send in one SOL packet.
+const maxSOLData = 200
+
+// Sent to a session that's had Serial over LAN deactivated by another.
+const solStatusDeactivating = 0x10
+
+var errBadPayload = errors.New("malformed encrypted payload")
+
+// Completion codes, see table 5-2 of the IPMI spec.
+const (
+	CompletionOK = 0x00
+	// CompletionPayloadActive is only for Activate and Deactivate Payload,
+	// which return it when the payload is already (de)activated.
+	CompletionPayloadActive    = 0x80
+	CompletionNodeBusy         = 0xc0
+	CompletionInvalidCommand   = 0xc1
+	CompletionTimeout          = 0xc3
+	CompletionInvalidLength    = 0xc7
+	CompletionOutOfRange       = 0xc9
+	CompletionNotPresent       = 0xcb
+	CompletionInsufficientPriv = 0xd4
+	CompletionUnspecifiedError = 0xff
+)
+
+// RMCP+ payload types, see table 13-16 of the IPMI spec.
+const (
+	payloadIPMI                = 0x00
+	payloadSOL                 = 0x01
+	payloadOpenSessionRequest  = 0x10
+	payloadOpenSessionResponse = 0x11
+	payloadRAKP1               = 0x12
+	payloadRAKP2               = 0x13
+	payloadRAKP3               = 0x14
+	payloadRAKP4               = 0x15
+)
+
+// RMCP+ status codes, see table 13-15 of the IPMI spec.
+const (
+	statusOK                    = 0x00
+	statusInvalidSessionID      = 0x02
+	statusUnauthorizedName      = 0x0d
+	statusInvalidIntegrityCheck = 0x0f
+	statusNoCipherSuiteMatch    = 0x11
+)
+
+const (
+	rmcpVersion      = 0x06
+	rmcpClassIPMI    = 0x07
+	authTypeRMCPPlus = 0x06
+	bmcAddr          = 0x20
+	privAdmin        = 0x04
+)
+
+type cipherSuite struct {
+	id           uint8
+	authAlg      uint8
+	integrityAlg uint8
+	cryptAlg     uint8
+	hash         func() hash.Hash
+	// integrityLen is how much of the integrity HMAC goes in each packet.
+	integrityLen int
+}
+
+var cipherSuites = []cipherSuite{
+	// RAKP-HMAC-SHA1, HMAC-SHA1-96, AES-CBC-128
+	{id: 3, authAlg: 0x01, integrityAlg: 0x01, cryptAlg: 0x01, hash: sha1.New, integrityLen: 12},
+	// RAKP-HMAC-SHA256, HMAC-SHA256-128, AES-CBC-128
+	{id: 17, authAlg: 0x03, integrityAlg: 0x04, cryptAlg: 0x01, hash: sha256.New, integrityLen: 16},
+}
+
+// Server is a simulated BMC listening on a local UDP port.
+type Server struct {
+	// Host and Port are where the BMC is listening.
+	Host string
+	Port int
+
+	pc   net.PacketConn
+	guid [16]byte
+
+	mu           sync.Mutex
+	latency      time.Duration
+	users        map[string]string
+	deviceID     DeviceID
+	powerOn      bool
+	selfTest     [2]byte
+	watchdog     Watchdog
+	fru          []byte
+	sensors      []*sensor
+	sdrUpdated   time.Time
+	sel          []*selRecord
+	lastSELID    uint16
+	selAdded     time.Time
+	selErased    time.Time
+	failures     map[Command]uint8
+	handlers     map[Command]Handler
+	unresponsive bool
+	sessions     map[uint32]*session
+	lastID       uint32
+	reservation  uint16
+	requests     map[Command]int
+	consoleAcks  int
+}
+
+type sensor struct {
+	Sensor
+	recordID uint16
+	// readings are returned in order, and the last one sticks.
+	readings []float64
+}
+
+type selRecord struct {
+	SELEntry
+	recordID uint16
+}
+
+type session struct {
+	id        uint32
+	consoleID uint32
+	suite     cipherSuite
+	active    bool
+	seq       uint32
+
+	user        string
+	pass        string
+	role        uint8
+	consoleRand [16]byte
+	bmcRand     [16]byte
+	k1, k2      []byte
+
+	// addr is where the session's last packet came from, which is where we
+	// send SOL packets.
+	addr      net.Addr
+	solActive bool
+	solSeq    uint8
+}
+
+// New starts a simulated BMC that accepts the given credentials. It's shut
+// down when the test finishes.
+func New(tb testing.TB, user, pass string) *Server {
+	tb.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("net.ListenPacket: %v", err)
+	}
+	tb.Cleanup(func() { pc.Close() })
+
+	addr := pc.LocalAddr().(*net.UDPAddr)
+	s := &Server{
+		Host:       addr.IP.String(),
+		Port:       addr.Port,
+		pc:         pc,
+		users:      map[string]string{user: pass},
+		deviceID:   defaultDeviceID,
+		powerOn:    true,
+		selfTest:   [2]byte{0x55, 0x00},
+		sdrUpdated: time.Now().Truncate(time.Second),
+		failures:   make(map[Command]uint8),
+		handlers:   make(map[Command]Handler),
+		sessions:   make(map[uint32]*session),
+		requests:   make(map[Command]int),
+	}
+	if _, err := rand.Read(s.guid[:]); err != nil {
+		tb.Fatalf("failed to generate GUID: %v", err)
+	}
+	go s.serve()
+	return s
+}
+
+// AddUser adds another set of credentials the BMC accepts.
+func (s *Server) AddUser(user, pass string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user] = pass
+}
+
+// AddSensor adds a sensor to the BMC's SDR repository, which updates the
+// repository's last-modified time. See SetReadings for what readings does.
+func (s *Server) AddSensor(sn Sensor, readings ...float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sn.M == 0 {
+		sn.M = 1
+	}
+	s.sensors = append(s.sensors, &sensor{
+		Sensor:   sn,
+		recordID: uint16(len(s.sensors) + 1),
+		readings: readings,
+	})
+	s.touchSDR()
+}
+
+// touchSDR updates the SDR repository's last-modified time. Timestamps only
+// have second resolution, so we make sure it changes even if it was last
+// modified less than a second ago.
+func (s *Server) touchSDR() {
+	s.sdrUpdated = nextTimestamp(s.sdrUpdated)
+}
+
+// nextTimestamp returns the current time to the second, or a second after
+// last if that isn't after it.
+func nextTimestamp(last time.Time) time.Time {
+	now := time.Now().Truncate(time.Second)
+	if !now.After(last) {
+		now = last.Add(time.Second)
+	}
+	return now
+}
+
+// SetReadings scripts the readings for a sensor. Each Get Sensor Reading
+// returns the next one, and the last one is repeated. With no readings, the
+// sensor reports that its reading is unavailable.
+func (s *Server) SetReadings(number uint8, readings ...float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sn := range s.sensors {
+		if sn.Number == number {
+			sn.readings = readings
+		}
+	}
+}
+
+// SetDeviceID sets what the BMC returns for Get Device ID.
+func (s *Server) SetDeviceID(id DeviceID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deviceID = id
+}
+
+// SetPowerOn sets whether the host's main power is on, which it is by
+// default.
+func (s *Server) SetPowerOn(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.powerOn = on
+}
+
+// SetSelfTest sets what the BMC returns for Get Self Test Results. By default
+// it's 0x55 0x00, meaning the self test passed.
+func (s *Server) SetSelfTest(code, detail uint8) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.selfTest = [2]byte{code, detail}
+}
+
+// SetWatchdog sets the state of the BMC's watchdog timer. By default, it's
+// never been set up.
+func (s *Server) SetWatchdog(wd Watchdog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchdog = wd
+}
+
+// SetFRU sets the BMC's built-in FRU inventory. By default, and with a nil
+// FRU, the BMC says it has none.
+func (s *Server) SetFRU(fru *FRU) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fru == nil {
+		s.fru = nil
+		return
+	}
+	s.fru = fru.encode()
+}
+
+// AddSELEntry adds an entry to the end of the BMC's SEL, and returns its
+// record ID. Record IDs start from 1 again after ClearSEL, like they do on
+// iDRACs.
+func (s *Server) AddSELEntry(e SELEntry) uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSELID++
+	s.sel = append(s.sel, &selRecord{SELEntry: e, recordID: s.lastSELID})
+	s.selAdded = nextTimestamp(s.selAdded)
+	return s.lastSELID
+}
+
+// DeleteSELEntry removes a single entry from the BMC's SEL, which doesn't
+// count as erasing it. It reports whether the entry was there.
+func (s *Server) DeleteSELEntry(recordID uint16) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.sel {
+		if r.recordID == recordID {
+			s.sel = append(s.sel[:i], s.sel[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearSEL erases the BMC's SEL, which updates its most recent erase time.
+func (s *Server) ClearSEL() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sel = nil
+	s.lastSELID = 0
+	s.selErased = nextTimestamp(s.selErased)
+}
+
+// Fail makes the BMC answer every request for the given command with the
+// given completion code, or answer it normally again if the code is
+// CompletionOK.
+func (s *Server) Fail(cmd Command, completionCode uint8) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if completionCode == CompletionOK {
+		delete(s.failures, cmd)
+		return
+	}
+	s.failures[cmd] = completionCode
+}
+
+// Handle makes the BMC answer the given command with the handler, for
+// commands (like OEM ones) that the simulator doesn't know about. It takes
+// precedence over the built-in handling, but not over Fail.
+func (s *Server) Handle(cmd Command, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[cmd] = h
+}
+
+// SetUnresponsive makes the BMC ignore all packets, like a BMC that's
+// rebooting or unplugged.
+func (s *Server) SetUnresponsive(unresponsive bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unresponsive = unresponsive
+}
+
+// SetLatency makes the BMC wait before answering each packet. Real BMCs
+// (especially old ones) take a few milliseconds per round trip, so this makes
+// benchmarks more representative.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Reset drops every session, like a BMC reset does. Packets for the old
+// sessions are ignored, and clients will need to open new ones.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[uint32]*session)
+}
+
+// WriteConsole sends output from the host's serial console to the session
+// with Serial over LAN active, if there is one, and reports whether there
+// was.
+func (s *Server) WriteConsole(data []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.solSession()
+	if sess == nil {
+		return false
+	}
+	for len(data) > 0 {
+		n := len(data)
+		if n > maxSOLData {
+			n = maxSOLData
+		}
+		sess.sendSOL(s.pc, data[:n], 0)
+		data = data[n:]
+	}
+	return true
+}
+
+// SOLActive reports whether a session has Serial over LAN active.
+func (s *Server) SOLActive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.solSession() != nil
+}
+
+// ConsoleAcks returns how many SOL packets the BMC has had acknowledged.
+func (s *Server) ConsoleAcks() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.consoleAcks
+}
+
+func (s *Server) solSession() *session {
+	for _, sess := range s.sessions {
+		if sess.solActive {
+			return sess
+		}
+	}
+	return nil
+}
+
+// Requests returns how many times the BMC has been sent the given command.
+func (s *Server) Requests(cmd Command) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[cmd]
+}
+
+// ActiveSessions returns how many sessions are currently open.
+func (s *Server) ActiveSessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, sess := range s.sessions {
+		if sess.active {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) serve() {
+	buf := make([]byte, 4096)
+	for {
+		n, addr, err := s.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		resp := s.handle(buf[:n], addr)
+		if resp == nil {
+			continue
+		}
+		s.mu.Lock()
+		latency := s.latency
+		s.mu.Unlock()
+		time.Sleep(latency)
+		s.pc.WriteTo(resp, addr)
+	}
+}
+
+// handle returns the response to a packet, or nil if it should be ignored.
+func (s *Server) handle(pkt []byte, addr net.Addr) []byte {
+	// RMCP header (4 bytes), then the RMCP+ session header (12 bytes).
+	if len(pkt) < 16 || pkt[0] != rmcpVersion || pkt[3] != rmcpClassIPMI || pkt[4] != authTypeRMCPPlus {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unresponsive {
+		return nil
+	}
+
+	payloadType := pkt[5] & 0x3f
+	encrypted := pkt[5]&0x80 != 0
+	authenticated := pkt[5]&0x40 != 0
+	sessionID := binary.LittleEndian.Uint32(pkt[6:10])
+	length := int(binary.LittleEndian.Uint16(pkt[14:16]))
+	if len(pkt) < 16+length {
+		return nil
+	}
+	payload := pkt[16 : 16+length]
+
+	switch payloadType {
+	case payloadOpenSessionRequest:
+		return s.openSession(payload)
+	case payloadRAKP1:
+		return s.rakp1(payload)
+	case payloadRAKP3:
+		return s.rakp3(payload)
+	case payloadIPMI, payloadSOL:
+	default:
+		return nil
+	}
+
+	if sessionID == 0 {
+		if payloadType != payloadIPMI {
+			return nil
+		}
+		resp := s.handleIPMI(nil, payload)
+		if resp == nil {
+			return nil
+		}
+		return packet(payloadIPMI, 0, 0, resp)
+	}
+
+	// Real BMCs silently drop packets for sessions they don't know about,
+	// e.g. after a reset, so clients find out by timing out.
+	sess, ok := s.sessions[sessionID]
+	if !ok || !sess.active || !authenticated {
+		return nil
+	}
+	authCode := pkt[len(pkt)-sess.suite.integrityLen:]
+	if !hmac.Equal(authCode, sess.integrity(pkt[4:len(pkt)-sess.suite.integrityLen])) {
+		return nil
+	}
+	if encrypted {
+		var err error
+		if payload, err = sess.decrypt(payload); err != nil {
+			return nil
+		}
+	}
+	sess.addr = addr
+
+	if payloadType == payloadSOL {
+		// Our SOL packets are never lost, so all we need from the console's are
+		// its ACKs.
+		if len(payload) >= 4 && payload[1] != 0 {
+			s.consoleAcks++
+		}
+		return nil
+	}
+
+	resp := s.handleIPMI(sess, payload)
+	if resp == nil {
+		return nil
+	}
+	return sess.packet(payloadIPMI, resp)
+}
+
+func (s *Server) openSession(req []byte) []byte {
+	if len(req) < 32 {
+		return nil
+	}
+	tag := req[0]
+	consoleID := binary.LittleEndian.Uint32(req[4:8])
+	authAlg, integrityAlg, cryptAlg := req[12], req[20], req[28]
+
+	var suite cipherSuite
+	found := false
+	for _, cs := range cipherSuites {
+		if cs.authAlg == authAlg && cs.integrityAlg == integrityAlg && cs.cryptAlg == cryptAlg {
+			suite, found = cs, true
+		}
+	}
+	if !found {
+		return packet(payloadOpenSessionResponse, 0, 0, setupError(tag, statusNoCipherSuiteMatch, consoleID))
+	}
+
+	s.lastID++
+	sess := &session{id: s.lastID, consoleID: consoleID, suite: suite}
+	s.sessions[sess.id] = sess
+
+	resp := make([]byte, 36)
+	resp[0] = tag
+	resp[1] = statusOK
+	resp[2] = privAdmin
+	binary.LittleEndian.PutUint32(resp[4:8], consoleID)
+	binary.LittleEndian.PutUint32(resp[8:12], sess.id)
+	copy(resp[12:20], []byte{0x00, 0, 0, 8, authAlg, 0, 0, 0})
+	copy(resp[20:28], []byte{0x01, 0, 0, 8, integrityAlg, 0, 0, 0})
+	copy(resp[28:36], []byte{0x02, 0, 0, 8, cryptAlg, 0, 0, 0})
+	return packet(payloadOpenSessionResponse, 0, 0, resp)
+}
+
+func (s *Server) rakp1(req []byte) []byte {
+	if len(req) < 28 || len(req) < 28+int(req[27]) {
+		return nil
+	}
+	tag := req[0]
+	sess, ok := s.sessions[binary.LittleEndian.Uint32(req[4:8])]
+	if !ok || sess.active {
+		return packet(payloadRAKP2, 0, 0, setupError(tag, statusInvalidSessionID, 0))
+	}
+	copy(sess.consoleRand[:], req[8:24])
+	sess.role = req[24]
+	sess.user = string(req[28 : 28+int(req[27])])
+
+	pass, ok := s.users[sess.user]
+	if !ok {
+		delete(s.sessions, sess.id)
+		return packet(payloadRAKP2, 0, 0, setupError(tag, statusUnauthorizedName, sess.consoleID))
+	}
+	sess.pass = pass
+	if _, err := rand.Read(sess.bmcRand[:]); err != nil {
+		return nil
+	}
+
+	var input bytes.Buffer
+	binary.Write(&input, binary.LittleEndian, sess.consoleID)
+	binary.Write(&input, binary.LittleEndian, sess.id)
+	input.Write(sess.consoleRand[:])
+	input.Write(sess.bmcRand[:])
+	input.Write(s.guid[:])
+	input.Write(sess.roleAndUser())
+
+	resp := []byte{tag, statusOK, 0, 0}
+	resp = binary.LittleEndian.AppendUint32(resp, sess.consoleID)
+	resp = append(resp, sess.bmcRand[:]...)
+	resp = append(resp, s.guid[:]...)
+	resp = append(resp, sess.mac(sess.key(), input.Bytes())...)
+	return packet(payloadRAKP2, 0, 0, resp)
+}
+
+func (s *Server) rakp3(req []byte) []byte {
+	if len(req) < 8 {
+		return nil
+	}
+	tag := req[0]
+	sess, ok := s.sessions[binary.LittleEndian.Uint32(req[4:8])]
+	if !ok || sess.active || sess.pass == "" {
+		return packet(payloadRAKP4, 0, 0, setupError(tag, statusInvalidSessionID, 0))
+	}
+
+	var input bytes.Buffer
+	input.Write(sess.bmcRand[:])
+	binary.Write(&input, binary.LittleEndian, sess.consoleID)
+	input.Write(sess.roleAndUser())
+	if !hmac.Equal(req[8:], sess.mac(sess.key(), input.Bytes())) {
+		delete(s.sessions, sess.id)
+		return packet(payloadRAKP4, 0, 0, setupError(tag, statusInvalidIntegrityCheck, sess.consoleID))
+	}
+
+	input.Reset()
+	input.Write(sess.consoleRand[:])
+	input.Write(sess.bmcRand[:])
+	input.Write(sess.roleAndUser())
+	sik := sess.mac(sess.key(), input.Bytes())
+	sess.k1 = sess.mac(sik, bytes.Repeat([]byte{0x01}, 20))
+	sess.k2 = sess.mac(sik, bytes.Repeat([]byte{0x02}, 20))
+
+	input.Reset()
+	input.Write(sess.consoleRand[:])
+	binary.Write(&input, binary.LittleEndian, sess.id)
+	input.Write(s.guid[:])
+	icv := sess.mac(sik, input.Bytes())[:sess.suite.integrityLen]
+
+	sess.active = true
+
+	resp := []byte{tag, statusOK, 0, 0}
+	resp = binary.LittleEndian.AppendUint32(resp, sess.consoleID)
+	resp = append(resp, icv...)
+	return packet(payloadRAKP4, 0, 0, resp)
+}
+
+// handleIPMI answers an IPMI message, see section 13.8 of the IPMI spec. The
+// session is nil for messages sent outside of one.
+func (s *Server) handleIPMI(sess *session, msg []byte) []byte {
+	if len(msg) < 7 || checksum(msg[:3]) != 0 || checksum(msg[3:]) != 0 {
+		return nil
+	}
+	rsAddr, rsLUN := msg[0], msg[1]&0x03
+	rqAddr, rqSeq, rqLUN := msg[3], msg[4]>>2, msg[4]&0x03
+	cmd := Command{NetFn: msg[1] >> 2, Cmd: msg[5]}
+	data := msg[6 : len(msg)-1]
+
+	s.requests[cmd]++
+	cc, respData := s.command(sess, cmd, data)
+
+	resp := []byte{rqAddr, (cmd.NetFn+1)<<2 | rqLUN, 0, rsAddr, rqSeq<<2 | rsLUN, cmd.Cmd, cc}
+	resp[2] = checksum(resp[:2])
+	resp = append(resp, respData...)
+	return append(resp, checksum(resp[3:]))
+}
+
+func (s *Server) command(sess *session, cmd Command, data []byte) (uint8, []byte) {
+	if cc, ok := s.failures[cmd]; ok {
+		return cc, nil
+	}
+	if sess == nil && !isSessionless(cmd) {
+		return CompletionInsufficientPriv, nil
+	}
+	if h, ok := s.handlers[cmd]; ok {
+		return h(data)
+	}
+
+	switch cmd {
+	case GetChannelAuthCapabilities:
+		// Channel 1, IPMI v2.0 extended capabilities, non-null usernames only.
+		return CompletionOK, []byte{0x01, 0x80, 0x04, 0x02, 0, 0, 0, 0}
+
+	case GetChannelCipherSuites:
+		if len(data) < 3 {
+			return CompletionInvalidLength, nil
+		}
+		resp := []byte{0x01}
+		if data[2]&0x3f == 0 {
+			for _, cs := range cipherSuites {
+				resp = append(resp, 0xc0, cs.id, cs.authAlg, 0x40|cs.integrityAlg, 0x80|cs.cryptAlg)
+			}
+		}
+		return CompletionOK, resp
+
+	case SetSessionPrivilegeLevel:
+		if len(data) < 1 {
+			return CompletionInvalidLength, nil
+		}
+		level := data[0]
+		if level == 0 {
+			level = privAdmin
+		}
+		return CompletionOK, []byte{level}
+
+	case CloseSession:
+		if len(data) < 4 {
+			return CompletionInvalidLength, nil
+		}
+		delete(s.sessions, binary.LittleEndian.Uint32(data[:4]))
+		return CompletionOK, nil
+
+	case ActivatePayload:
+		if len(data) < 2 {
+			return CompletionInvalidLength, nil
+		}
+		if data[0] != payloadSOL || data[1] != 1 {
+			return CompletionOutOfRange, nil
+		}
+		if s.solSession() != nil {
+			return CompletionPayloadActive, nil
+		}
+		sess.solActive = true
+		resp := []byte{0, 0, 0, 0}
+		resp = binary.LittleEndian.AppendUint16(resp, maxSOLData+4)
+		resp = binary.LittleEndian.AppendUint16(resp, maxSOLData+4)
+		resp = binary.LittleEndian.AppendUint16(resp, uint16(s.Port))
+		return CompletionOK, binary.LittleEndian.AppendUint16(resp, 0xffff)
+
+	case DeactivatePayload:
+		if len(data) < 2 {
+			return CompletionInvalidLength, nil
+		}
+		active := s.solSession()
+		if data[0] != payloadSOL || data[1] != 1 || active == nil {
+			return CompletionPayloadActive, nil
+		}
+		active.solActive = false
+		if active != sess {
+			// Let the session that had it know it's been kicked off.
+			active.sendSOL(s.pc, nil, solStatusDeactivating)
+		}
+		return CompletionOK, nil
+
+	case GetSessionInfo:
+		active := 0
+		for _, sess := range s.sessions {
+			if sess.active {
+				active++
+			}
+		}
+		return CompletionOK, []byte{0x01, 0x04, uint8(active), 0x02, privAdmin, 0x01}
+
+	case GetChassisStatus:
+		// Restore power to its previous state after losing AC.
+		state := byte(0x20)
+		if s.powerOn {
+			state |= 0x01
+		}
+		return CompletionOK, []byte{state, 0, 0}
+
+	case GetDeviceID:
+		id := s.deviceID
+		fwMajor := id.FirmwareMajor & 0x7f
+		if id.Unavailable {
+			fwMajor |= 0x80
+		}
+		resp := []byte{
+			id.DeviceID,
+			id.DeviceRevision & 0x0f,
+			fwMajor,
+			(id.FirmwareMinor/10)<<4 | id.FirmwareMinor%10,
+			0x02, // IPMI v2.0
+			0x1f, // SDR repository, sensors, SEL, FRU and IPMB event receiver.
+			byte(id.ManufacturerID), byte(id.ManufacturerID >> 8), byte(id.ManufacturerID >> 16),
+			byte(id.ProductID), byte(id.ProductID >> 8),
+		}
+		return CompletionOK, append(resp, id.AuxFirmware[:]...)
+
+	case GetSelfTestResults:
+		return CompletionOK, s.selfTest[:]
+
+	case GetWatchdogTimer:
+		wd := s.watchdog
+		use := wd.Use & 0x07
+		if wd.Running {
+			use |= 0x40
+		}
+		resp := []byte{use, wd.Action & 0x07, 0, wd.ExpirationFlags}
+		resp = binary.LittleEndian.AppendUint16(resp, wd.InitialCountdown)
+		return CompletionOK, binary.LittleEndian.AppendUint16(resp, wd.PresentCountdown)
+
+	case GetFRUInventoryAreaInfo:
+		if len(data) < 1 {
+			return CompletionInvalidLength, nil
+		}
+		if data[0] != 0 || s.fru == nil {
+			return CompletionNotPresent, nil
+		}
+		// Accessed by bytes.
+		return CompletionOK, append(binary.LittleEndian.AppendUint16(nil, uint16(len(s.fru))), 0)
+
+	case ReadFRUData:
+		if len(data) < 4 {
+			return CompletionInvalidLength, nil
+		}
+		if data[0] != 0 || s.fru == nil {
+			return CompletionNotPresent, nil
+		}
+		offset, count := int(binary.LittleEndian.Uint16(data[1:3])), int(data[3])
+		if offset >= len(s.fru) {
+			return CompletionOutOfRange, nil
+		}
+		if offset+count > len(s.fru) {
+			count = len(s.fru) - offset
+		}
+		return CompletionOK, append([]byte{byte(count)}, s.fru[offset:offset+count]...)
+
+	case GetSDRRepositoryInfo:
+		resp := []byte{0x51}
+		resp = binary.LittleEndian.AppendUint16(resp, uint16(len(s.sensors)))
+		resp = binary.LittleEndian.AppendUint16(resp, 0xffff)
+		resp = binary.LittleEndian.AppendUint32(resp, uint32(s.sdrUpdated.Unix()))
+		resp = binary.LittleEndian.AppendUint32(resp, 0)
+		// Supports Reserve SDR Repository.
+		return CompletionOK, append(resp, 0x02)
+
+	case ReserveSDRRepository:
+		s.reservation++
+		return CompletionOK, binary.LittleEndian.AppendUint16(nil, s.reservation)
+
+	case GetSDR:
+		if len(data) < 6 {
+			return CompletionInvalidLength, nil
+		}
+		return s.getSDR(binary.LittleEndian.Uint16(data[2:4]), int(data[4]), int(data[5]))
+
+	case GetSELInfo:
+		resp := []byte{0x51}
+		resp = binary.LittleEndian.AppendUint16(resp, uint16(len(s.sel)))
+		resp = binary.LittleEndian.AppendUint16(resp, 0xffff)
+		resp = binary.LittleEndian.AppendUint32(resp, selTimestamp(s.selAdded))
+		resp = binary.LittleEndian.AppendUint32(resp, selTimestamp(s.selErased))
+		// No operations beyond reading.
+		return CompletionOK, append(resp, 0)
+
+	case GetSELEntry:
+		if len(data) < 6 {
+			return CompletionInvalidLength, nil
+		}
+		return s.getSELEntry(binary.LittleEndian.Uint16(data[2:4]), int(data[4]), int(data[5]))
+
+	case GetSensorReading:
+		if len(data) < 1 {
+			return CompletionInvalidLength, nil
+		}
+		for _, sn := range s.sensors {
+			if sn.Number == data[0] {
+				return CompletionOK, sn.reading()
+			}
+		}
+		return CompletionNotPresent, nil
+	}
+
+	return CompletionInvalidCommand, nil
+}
+
+func (s *Server) getSDR(recordID uint16, offset, count int) (uint8, []byte) {
+	idx := -1
+	for i, sn := range s.sensors {
+		if recordID == 0 || sn.recordID == recordID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return CompletionNotPresent, nil
+	}
+
+	rec := s.sensors[idx].record()
+	if offset > len(rec) {
+		return CompletionOutOfRange, nil
+	}
+	if count == 0xff || offset+count > len(rec) {
+		count = len(rec) - offset
+	}
+
+	next := uint16(0xffff)
+	if idx+1 < len(s.sensors) {
+		next = s.sensors[idx+1].recordID
+	}
+	resp := binary.LittleEndian.AppendUint16(nil, next)
+	return CompletionOK, append(resp, rec[offset:offset+count]...)
+}
+
+func (s *Server) getSELEntry(recordID uint16, offset, count int) (uint8, []byte) {
+	idx := -1
+	for i, r := range s.sel {
+		// 0x0000 is the first record, and 0xffff the last.
+		if (recordID == 0 && i == 0) || r.recordID == recordID || (recordID == 0xffff && i == len(s.sel)-1) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return CompletionNotPresent, nil
+	}
+
+	rec := s.sel[idx].record()
+	if offset > len(rec) {
+		return CompletionOutOfRange, nil
+	}
+	if count == 0xff || offset+count > len(rec) {
+		count = len(rec) - offset
+	}
+
+	next := uint16(0xffff)
+	if idx+1 < len(s.sel) {
+		next = s.sel[idx+1].recordID
+	}
+	resp := binary.LittleEndian.AppendUint16(nil, next)
+	return CompletionOK, append(resp, rec[offset:offset+count]...)
+}
+
+// record returns the entry's 16 byte system event record.
+func (r *selRecord) record() []byte {
+	rec := binary.LittleEndian.AppendUint16(nil, r.recordID)
+	rec = append(rec, 0x02) // System event record
+	rec = binary.LittleEndian.AppendUint32(rec, selTimestamp(r.Timestamp))
+	rec = append(rec, bmcAddr, 0) // Generator ID
+	rec = append(rec, 0x04)       // Event message format version
+	rec = append(rec, r.SensorType, r.SensorNumber, r.EventDirType)
+	return append(rec, r.EventData[:]...)
+}
+
+// selTimestamp encodes a SEL timestamp, where zero means unspecified.
+func selTimestamp(t time.Time) uint32 {
+	if t.IsZero() {
+		return 0xffffffff
+	}
+	return uint32(t.Unix())
+}
+
+// record returns the sensor's full sensor record, see section 43.1 of the
+// IPMI spec.
+func (sn *sensor) record() []byte {
+	name := sn.Name
+	if len(name) > 16 {
+		name = name[:16]
+	}
+	rec := make([]byte, 48, 48+len(name))
+	binary.LittleEndian.PutUint16(rec[0:2], sn.recordID)
+	rec[2] = 0x51 // SDR version
+	rec[3] = 0x01 // Full sensor record
+	rec[4] = byte(48 + len(name) - 5)
+	rec[5] = bmcAddr
+	rec[7] = sn.Number
+	rec[8] = 0x07 // System board
+	rec[9] = 0x01
+	rec[10] = 0x03 // Scanning and events enabled
+	rec[12] = byte(sn.Type)
+	rec[13] = 0x01 // Threshold-based
+	rec[20] = 0x80 // 2's complement readings
+	rec[21] = byte(sn.Unit)
+	m := uint16(sn.M) & 0x3ff
+	rec[24] = byte(m)
+	rec[25] = byte(m>>8) << 6
+	rec[29] = byte(sn.RExp) << 4
+	rec[47] = 0xc0 | byte(len(name)) // 8-bit ASCII
+	return append(rec, name...)
+}
+
+// reading returns the sensor's Get Sensor Reading response.
+func (sn *sensor) reading() []byte {
+	if len(sn.readings) == 0 {
+		// Scanning enabled, reading unavailable.
+		return []byte{0, 0x60, 0}
+	}
+	v := sn.readings[0]
+	if len(sn.readings) > 1 {
+		sn.readings = sn.readings[1:]
+	}
+
+	raw := math.Round(v / (float64(sn.M) * math.Pow(10, float64(sn.RExp))))
+	raw = math.Max(math.Min(raw, math.MaxInt8), math.MinInt8)
+	// Event messages and scanning enabled.
+	return []byte{byte(int8(raw)), 0xc0, 0}
+}
+
+// fruEpoch is when FRU manufacture dates are counted from.
+var fruEpoch = time.Date(1996, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// encode returns the FRU's inventory area: a common header, then a board info
+// area and a product info area.
+func (f *FRU) encode() []byte {
+	mins := uint32(f.ManufactureDate.Sub(fruEpoch) / time.Minute)
+	board := []byte{0x01, 0, 0, byte(mins), byte(mins >> 8), byte(mins >> 16)}
+	board = appendFRUFields(board, f.BoardManufacturer, f.BoardProduct, f.BoardSerial, f.BoardPartNumber, "")
+	board = padFRUArea(board)
+
+	// Manufacturer, name, part number, version, serial, asset tag and FRU
+	// file ID.
+	product := []byte{0x01, 0, 0}
+	product = appendFRUFields(product, "", f.ProductName, "", "", f.ProductSerial, "", "")
+	product = padFRUArea(product)
+
+	header := []byte{0x01, 0, 0, 1, byte(1 + len(board)/8), 0, 0, 0}
+	header[7] = checksum(header[:7])
+	out := append(header, board...)
+	return append(out, product...)
+}
+
+// appendFRUFields appends 8-bit ASCII fields to a FRU area, followed by the
+// end of fields marker.
+func appendFRUFields(area []byte, fields ...string) []byte {
+	for _, f := range fields {
+		if len(f) > 0x3f {
+			f = f[:0x3f]
+		}
+		area = append(area, 0xc0|byte(len(f)))
+		area = append(area, f...)
+	}
+	return append(area, 0xc1)
+}
+
+// padFRUArea pads a FRU area out to a multiple of 8 bytes, and fills in its
+// length and checksum.
+func padFRUArea(area []byte) []byte {
+	for len(area)%8 != 7 {
+		area = append(area, 0)
+	}
+	area[1] = byte((len(area) + 1) / 8)
+	return append(area, checksum(area))
+}
+
+func isSessionless(cmd Command) bool {
+	for _, c := range sessionlessCommands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// key is the user's password, which authenticates the RAKP messages.
+func (sess *session) key() []byte {
+	key := make([]byte, 20)
+	copy(key, sess.pass)
+	return key
+}
+
+func (sess *session) roleAndUser() []byte {
+	return append([]byte{sess.role, byte(len(sess.user))}, sess.user...)
+}
+
+func (sess *session) mac(key, data []byte) []byte {
+	h := hmac.New(sess.suite.hash, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (sess *session) integrity(data []byte) []byte {
+	return sess.mac(sess.k1, data)[:sess.suite.integrityLen]
+}
+
+// decrypt decrypts an AES-CBC-128 payload, see section 13.29 of the IPMI spec.
+func (sess *session) decrypt(payload []byte) ([]byte, error) {
+	if len(payload) < 2*aes.BlockSize || len(payload)%aes.BlockSize != 0 {
+		return nil, errBadPayload
+	}
+	block, err := aes.NewCipher(sess.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(payload)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, payload[:aes.BlockSize]).CryptBlocks(out, payload[aes.BlockSize:])
+	pad := int(out[len(out)-1])
+	if pad >= len(out) {
+		return nil, errBadPayload
+	}
+	return out[:len(out)-pad-1], nil
+}
+
+func (sess *session) encrypt(payload []byte) []byte {
+	pad := (aes.BlockSize - (len(payload)+1)%aes.BlockSize) % aes.BlockSize
+	plain := append([]byte{}, payload...)
+	for i := 1; i <= pad; i++ {
+		plain = append(plain, byte(i))
+	}
+	plain = append(plain, byte(pad))
+
+	out := make([]byte, aes.BlockSize+len(plain))
+	rand.Read(out[:aes.BlockSize])
+	block, _ := aes.NewCipher(sess.k2[:16])
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], plain)
+	return out
+}
+
+// sendSOL sends an SOL packet to the session, see section 15.9 of the IPMI
+// spec.
+func (sess *session) sendSOL(pc net.PacketConn, data []byte, status uint8) {
+	if sess.addr == nil {
+		return
+	}
+	// Sequence numbers go from 1 to 15, since 0 is for ACK-only packets.
+	sess.solSeq = sess.solSeq%15 + 1
+	payload := append([]byte{sess.solSeq, 0, 0, status}, data...)
+	pc.WriteTo(sess.packet(payloadSOL, payload), sess.addr)
+}
+
+// packet wraps a payload in an encrypted and authenticated RMCP+ packet for
+// the session.
+func (sess *session) packet(payloadType uint8, msg []byte) []byte {
+	sess.seq++
+	pkt := packet(0xc0|payloadType, sess.consoleID, sess.seq, sess.encrypt(msg))
+
+	pad := (4 - (len(pkt)-4+2)%4) % 4
+	pkt = append(pkt, bytes.Repeat([]byte{0xff}, pad)...)
+	pkt = append(pkt, byte(pad), 0x07)
+	return append(pkt, sess.integrity(pkt[4:])...)
+}
+
+// packet wraps a payload in an RMCP+ packet, without a session trailer.
+func packet(payloadType uint8, sessionID, seq uint32, payload []byte) []byte {
+	pkt := []byte{rmcpVersion, 0, 0xff, rmcpClassIPMI, authTypeRMCPPlus, payloadType}
+	pkt = binary.LittleEndian.AppendUint32(pkt, sessionID)
+	pkt = binary.LittleEndian.AppendUint32(pkt, seq)
+	pkt = binary.LittleEndian.AppendUint16(pkt, uint16(len(payload)))
+	return append(pkt, payload...)
+}
+
+// setupError is the short response to a failed session setup message.
+func setupError(tag, status uint8, consoleID uint32) []byte {
+	return binary.LittleEndian.AppendUint32([]byte{tag, status, 0, 0}, consoleID)
+}
+
+func checksum(b []byte) uint8 {
+	var sum uint8
+	for _, v := range b {
+		sum += v
+	}
+	return -sum
+}
@@ -0,0 +1,230 @@
+package ipmi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// OEMCommand is a vendor-specific command that we know how to send and
+// decode.
+type OEMCommand struct {
+	Name        string
+	Description string
+	// ManufacturerID is the IANA enterprise number of the vendor whose BMCs
+	// support the command, e.g. 674 for Dell.
+	ManufacturerID uint32
+
+	// run sends the command, which can take more than one request, and
+	// decodes the result.
+	run func(ctx context.Context, cn *conn) (any, error)
+}
+
+// Dell's IANA enterprise number, which its BMCs report in Get Device ID.
+const dellManufacturerID = 674
+
+// Dell's OEM network function.
+const netFnDellOEM = 0x30
+
+// Get System Info Parameters, which Dell uses for some of its OEM data. See
+// section 22.14a of the IPMI spec.
+const (
+	netFnApp             = 0x06
+	cmdGetSystemInfo     = 0x59
+	systemInfoGetParam   = 0x00
+	paramDellLCDString   = 0xc1
+	paramDellAvgPower    = 0xeb
+	paramDellPeakPower   = 0xec
+	cmdDellPowerHeadroom = 0xbb
+)
+
+var oemCommands = map[string]*OEMCommand{}
+
+func registerOEMCommand(cmd *OEMCommand) {
+	if _, ok := oemCommands[cmd.Name]; ok {
+		panic(fmt.Sprintf("OEM command %q registered twice", cmd.Name))
+	}
+	oemCommands[cmd.Name] = cmd
+}
+
+func init() {
+	registerOEMCommand(&OEMCommand{
+		Name:           "dell-lcd-string",
+		Description:    "The user-defined string shown on the front panel LCD, as a string.",
+		ManufacturerID: dellManufacturerID,
+		run:            dellLCDString,
+	})
+	registerOEMCommand(&OEMCommand{
+		Name:           "dell-avg-power-history",
+		Description:    "Average power consumption over the last minute, hour, day and week, as a *PowerHistory.",
+		ManufacturerID: dellManufacturerID,
+		run:            dellPowerHistory(paramDellAvgPower),
+	})
+	registerOEMCommand(&OEMCommand{
+		Name:           "dell-peak-power-history",
+		Description:    "Peak power consumption over the last minute, hour, day and week, and when each peak happened, as a *PowerHistory.",
+		ManufacturerID: dellManufacturerID,
+		run:            dellPowerHistory(paramDellPeakPower),
+	})
+	registerOEMCommand(&OEMCommand{
+		Name:           "dell-power-headroom",
+		Description:    "How much more power the system could draw before hitting its power budget, as a *PowerHeadroom.",
+		ManufacturerID: dellManufacturerID,
+		run:            dellPowerHeadroom,
+	})
+}
+
+// OEMCommands returns every OEM command we know how to decode, sorted by
+// name.
+func OEMCommands() []*OEMCommand {
+	out := make([]*OEMCommand, 0, len(oemCommands))
+	for _, cmd := range oemCommands {
+		out = append(out, cmd)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// OEM runs the named OEM command (see OEMCommands) against the host's BMC and
+// returns the decoded result, whose type depends on the command.
+func (c *Client) OEM(ctx context.Context, host, name string) (any, error) {
+	cmd, ok := oemCommands[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown OEM command %q", name)
+	}
+	var out any
+	err := c.do(ctx, host, func(cn *conn) error {
+		var err error
+		if out, err = cmd.run(ctx, cn); err != nil {
+			return fmt.Errorf("failed to run OEM command %q: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PowerHistory is a system's power consumption over the last minute, hour,
+// day and week.
+type PowerHistory struct {
+	LastMinuteWatts uint16
+	LastHourWatts   uint16
+	LastDayWatts    uint16
+	LastWeekWatts   uint16
+	// For peaks, when each of them happened. These are zero for averages.
+	LastMinuteAt time.Time
+	LastHourAt   time.Time
+	LastDayAt    time.Time
+	LastWeekAt   time.Time
+}
+
+// PowerHeadroom is how much more power a system could draw before hitting its
+// power budget, based on its current and peak draw.
+type PowerHeadroom struct {
+	InstantaneousWatts uint16
+	PeakWatts          uint16
+}
+
+// systemInfo reads a block of a Get System Info Parameters parameter, and
+// returns the data after the parameter revision.
+func (cn *conn) systemInfo(ctx context.Context, param, set uint8) ([]byte, error) {
+	resp, err := cn.raw(ctx, netFnApp, cmdGetSystemInfo, []byte{systemInfoGetParam, param, set, 0x00})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 1 {
+		return nil, errors.New("empty system info response")
+	}
+	return resp[1:], nil
+}
+
+// dellLCDString reads the LCD string, which is stored like the standard system
+// info strings: in blocks of 16 bytes, where the first block starts with the
+// encoding and total length.
+func dellLCDString(ctx context.Context, cn *conn) (any, error) {
+	var (
+		text   []byte
+		length = -1
+	)
+	for block := uint8(0); length < 0 || len(text) < length; block++ {
+		data, err := cn.systemInfo(ctx, paramDellLCDString, block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LCD string block %d: %w", block, err)
+		}
+		// The first byte echoes the block number.
+		if len(data) < 1 {
+			return nil, fmt.Errorf("LCD string block %d is empty", block)
+		}
+		data = data[1:]
+		if block == 0 {
+			if len(data) < 2 {
+				return nil, errors.New("LCD string block 0 is too short")
+			}
+			length = int(data[1])
+			data = data[2:]
+			if length == 0 {
+				// Nobody's set it.
+				return "", nil
+			}
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("LCD string block %d has no data, with %d of %d bytes read", block, len(text), length)
+		}
+		text = append(text, data...)
+	}
+	return string(text[:length]), nil
+}
+
+func dellPowerHistory(param uint8) func(ctx context.Context, cn *conn) (any, error) {
+	return func(ctx context.Context, cn *conn) (any, error) {
+		data, err := cn.systemInfo(ctx, param, 0)
+		if err != nil {
+			return nil, err
+		}
+		return decodeDellPowerHistory(data, param == paramDellPeakPower)
+	}
+}
+
+func decodeDellPowerHistory(data []byte, peak bool) (*PowerHistory, error) {
+	want := 8
+	if peak {
+		want += 16
+	}
+	if len(data) < want {
+		return nil, fmt.Errorf("power history is %d bytes, want %d", len(data), want)
+	}
+
+	le := binary.LittleEndian
+	h := &PowerHistory{
+		LastMinuteWatts: le.Uint16(data[0:2]),
+		LastHourWatts:   le.Uint16(data[2:4]),
+		LastDayWatts:    le.Uint16(data[4:6]),
+		LastWeekWatts:   le.Uint16(data[6:8]),
+	}
+	if peak {
+		h.LastMinuteAt = time.Unix(int64(le.Uint32(data[8:12])), 0)
+		h.LastHourAt = time.Unix(int64(le.Uint32(data[12:16])), 0)
+		h.LastDayAt = time.Unix(int64(le.Uint32(data[16:20])), 0)
+		h.LastWeekAt = time.Unix(int64(le.Uint32(data[20:24])), 0)
+	}
+	return h, nil
+}
+
+func dellPowerHeadroom(ctx context.Context, cn *conn) (any, error) {
+	data, err := cn.raw(ctx, netFnDellOEM, cmdDellPowerHeadroom, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("power headroom is %d bytes, want 4", len(data))
+	}
+	return &PowerHeadroom{
+		InstantaneousWatts: binary.LittleEndian.Uint16(data[0:2]),
+		PeakWatts:          binary.LittleEndian.Uint16(data[2:4]),
+	}, nil
+}
@@ -0,0 +1,115 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/bougou/go-ipmi"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRaw(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.SetDeviceID(ipmisim.DeviceID{DeviceID: 0x20, FirmwareMajor: 3, FirmwareMinor: 65, ManufacturerID: 674})
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	got, err := c.Raw(ctx, bmc.Host, 0x06, 0x01, nil)
+	if err != nil {
+		t.Fatalf("Raw: %v", err)
+	}
+	want := []byte{0x20, 0x00, 0x03, 0x65, 0x02, 0x1f, 0xa2, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected Get Device ID response (-want +got)\n%s", diff)
+	}
+
+	// Commands the BMC doesn't support should come back as a completion code.
+	_, err = c.Raw(ctx, bmc.Host, 0x30, 0xff, []byte{0x01})
+	var respErr *ipmi.ResponseError
+	if !errors.As(err, &respErr) || respErr.CompletionCode() != ipmisim.CompletionInvalidCommand {
+		t.Errorf("Raw with an unknown command returned %v, want an invalid command completion code", err)
+	}
+}
+
+func TestDellOEM(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Every Get System Info Parameters response starts with the parameter
+	// revision.
+	const rev = 0x11
+	// Longer than one block, so it takes two reads.
+	lcd := "rack 3, shelf 2 - web tier"
+	params := map[uint8][][]byte{
+		paramDellLCDString: {
+			append([]byte{rev, 0x00, 0x00, byte(len(lcd))}, lcd[:14]...),
+			append([]byte{rev, 0x01}, lcd[14:]...),
+		},
+		paramDellAvgPower: {{
+			rev,
+			0xb0, 0x00, 0xb4, 0x00, 0xbe, 0x00, 0xc8, 0x00,
+		}},
+		paramDellPeakPower: {{
+			rev,
+			0xf0, 0x00, 0x18, 0x01, 0x2c, 0x01, 0x40, 0x01,
+			0x00, 0x00, 0x00, 0x60, 0x10, 0x00, 0x00, 0x60, 0x20, 0x00, 0x00, 0x60, 0x30, 0x00, 0x00, 0x60,
+		}},
+	}
+	bmc.Handle(ipmisim.Command{NetFn: netFnApp, Cmd: cmdGetSystemInfo}, func(data []byte) (uint8, []byte) {
+		blocks, ok := params[data[1]]
+		if !ok || int(data[2]) >= len(blocks) {
+			return 0x80, nil // Parameter not supported
+		}
+		return ipmisim.CompletionOK, blocks[data[2]]
+	})
+	bmc.Handle(ipmisim.Command{NetFn: netFnDellOEM, Cmd: cmdDellPowerHeadroom}, func([]byte) (uint8, []byte) {
+		return ipmisim.CompletionOK, []byte{0x90, 0x01, 0x40, 0x01}
+	})
+
+	tests := []struct {
+		name string
+		want any
+	}{
+		{"dell-lcd-string", lcd},
+		{"dell-avg-power-history", &PowerHistory{LastMinuteWatts: 176, LastHourWatts: 180, LastDayWatts: 190, LastWeekWatts: 200}},
+		{"dell-peak-power-history", &PowerHistory{
+			LastMinuteWatts: 240, LastHourWatts: 280, LastDayWatts: 300, LastWeekWatts: 320,
+			LastMinuteAt: time.Unix(0x60000000, 0),
+			LastHourAt:   time.Unix(0x60000010, 0),
+			LastDayAt:    time.Unix(0x60000020, 0),
+			LastWeekAt:   time.Unix(0x60000030, 0),
+		}},
+		{"dell-power-headroom", &PowerHeadroom{InstantaneousWatts: 400, PeakWatts: 320}},
+	}
+	for _, test := range tests {
+		got, err := c.OEM(ctx, bmc.Host, test.name)
+		if err != nil {
+			t.Errorf("OEM(%q): %v", test.name, err)
+			continue
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("unexpected result for %q (-want +got)\n%s", test.name, diff)
+		}
+	}
+
+	// An LCD string that's never been set has a length of zero, and no data.
+	bmc.Handle(ipmisim.Command{NetFn: netFnApp, Cmd: cmdGetSystemInfo}, func(data []byte) (uint8, []byte) {
+		return ipmisim.CompletionOK, []byte{rev, 0x00, 0x00, 0x00}
+	})
+	if got, err := c.OEM(ctx, bmc.Host, "dell-lcd-string"); err != nil || got != "" {
+		t.Errorf("OEM(dell-lcd-string) with no LCD string = %q, %v, want an empty string", got, err)
+	}
+
+	if len(OEMCommands()) != len(tests) {
+		t.Errorf("got %d OEM commands, but only tested %d", len(OEMCommands()), len(tests))
+	}
+	if _, err := c.OEM(ctx, bmc.Host, "hp-something"); err == nil {
+		t.Error("OEM with an unknown command succeeded, want an error")
+	}
+}
@@ -0,0 +1,47 @@
+package ipmi
+
+import (
+	"context"
+	"fmt"
+)
+
+// PowerStatus is the state of a host's main power, as seen by its BMC.
+type PowerStatus struct {
+	On bool
+	// Fault is a fault in the main power subsystem, and ControlFault means the
+	// BMC tried to turn the power on or off, but it didn't take.
+	Fault        bool
+	ControlFault bool
+	// Overload and Interlock mean the host was shut down because of a power
+	// overload or an active panel interlock switch.
+	Overload  bool
+	Interlock bool
+	// RestorePolicy is what the host does when AC power comes back, one of
+	// "always-off", "previous" or "always-on".
+	RestorePolicy string
+}
+
+// PowerStatus reads the host's chassis power status from its BMC. BMCs run on
+// standby power, so this works even when the host is off.
+func (c *Client) PowerStatus(ctx context.Context, host string) (*PowerStatus, error) {
+	var ps *PowerStatus
+	err := c.do(ctx, host, func(cn *conn) error {
+		res, err := cn.ic.GetChassisStatus(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get chassis status: %w", err)
+		}
+		ps = &PowerStatus{
+			On:            res.PowerIsOn,
+			Fault:         res.PowerFault,
+			ControlFault:  res.PowerControlFault,
+			Overload:      res.PowerOverload,
+			Interlock:     res.InterLock,
+			RestorePolicy: res.PowerRestorePolicy.String(),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
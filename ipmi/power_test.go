@@ -0,0 +1,29 @@
+package ipmi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPowerStatus(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, on := range []bool{true, false} {
+		bmc.SetPowerOn(on)
+		got, err := c.PowerStatus(ctx, bmc.Host)
+		if err != nil {
+			t.Fatalf("PowerStatus: %v", err)
+		}
+		want := &PowerStatus{On: on, RestorePolicy: "previous"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected power status (-want +got)\n%s", diff)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package ipmi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bougou/go-ipmi"
+)
+
+// Raw sends an arbitrary request to the host's BMC, and returns the response
+// data after the completion code. Completion codes other than 0x00 are
+// returned as an *ipmi.ResponseError from go-ipmi.
+//
+// This is for commands we don't have a typed method for, like vendor OEM
+// commands, see OEM for the ones we know how to decode.
+func (c *Client) Raw(ctx context.Context, host string, netFn, cmd uint8, data []byte) ([]byte, error) {
+	var resp []byte
+	err := c.do(ctx, host, func(cn *conn) error {
+		var err error
+		resp, err = cn.raw(ctx, netFn, cmd, data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (cn *conn) raw(ctx context.Context, netFn, cmd uint8, data []byte) ([]byte, error) {
+	res, err := cn.ic.RawCommand(ctx, ipmi.NetFn(netFn), cmd, data, fmt.Sprintf("raw %#02x %#02x", netFn, cmd))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send raw command (netfn %#02x, cmd %#02x): %w", netFn, cmd, err)
+	}
+	return res.Response, nil
+}
@@ -0,0 +1,541 @@
+package ipmi
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rmcpSession is a bare-bones RMCP+ (IPMI v2.0 over LAN) session. go-ipmi
+// only knows how to send IPMI messages over its sessions, and Serial over LAN
+// needs its own payload type, so SOL sessions use this instead. It only
+// supports cipher suites 3 and 17, which is everything an iDRAC offers.
+//
+// Once the session is established, a single goroutine reads every packet
+// from the BMC, and hands IPMI responses to whoever's waiting for them and
+// SOL packets to onSOL.
+type rmcpSession struct {
+	udp     *net.UDPConn
+	timeout time.Duration
+	suite   rmcpCipherSuite
+
+	consoleID, bmcID uint32
+	k1, k2           []byte
+
+	// onSOL is called from the read loop with each decrypted SOL payload.
+	onSOL func(payload []byte)
+
+	mu    sync.Mutex
+	seq   uint32
+	rqSeq uint8
+	// waiting receives the IPMI response for the request in flight, keyed by
+	// its sequence number.
+	waiting map[uint8]chan []byte
+
+	// done is closed when the read loop exits, after setting err.
+	done chan struct{}
+	err  error
+}
+
+type rmcpCipherSuite struct {
+	id                           uint8
+	authAlg, integrityAlg, crypt uint8
+	hash                         func() hash.Hash
+	// integrityLen is how much of the integrity HMAC goes in each packet.
+	integrityLen int
+}
+
+var rmcpCipherSuites = []rmcpCipherSuite{
+	// RAKP-HMAC-SHA256, HMAC-SHA256-128, AES-CBC-128
+	{id: 17, authAlg: 0x03, integrityAlg: 0x04, crypt: 0x01, hash: sha256.New, integrityLen: 16},
+	// RAKP-HMAC-SHA1, HMAC-SHA1-96, AES-CBC-128
+	{id: 3, authAlg: 0x01, integrityAlg: 0x01, crypt: 0x01, hash: sha1.New, integrityLen: 12},
+}
+
+// RMCP+ payload types, see table 13-16 of the IPMI spec.
+const (
+	payloadIPMI                = 0x00
+	payloadSOL                 = 0x01
+	payloadOpenSessionRequest  = 0x10
+	payloadOpenSessionResponse = 0x11
+	payloadRAKP1               = 0x12
+	payloadRAKP2               = 0x13
+	payloadRAKP3               = 0x14
+	payloadRAKP4               = 0x15
+)
+
+// RMCP+ status codes, see table 13-15 of the IPMI spec.
+const (
+	rmcpStatusOK                 = 0x00
+	rmcpStatusNoCipherSuiteMatch = 0x11
+)
+
+const (
+	rmcpVersion      = 0x06
+	rmcpClassIPMI    = 0x07
+	authTypeRMCPPlus = 0x06
+	bmcAddr          = 0x20
+	remoteConsole    = 0x81
+	// Look up the user by name only, rather than by name and role.
+	rakpNameOnlyLookup = 0x10
+)
+
+var (
+	errRMCPMalformed    = errors.New("malformed RMCP+ packet")
+	errRMCPNoSuiteMatch = errors.New("BMC doesn't support the cipher suite")
+	errRMCPClosed       = errors.New("RMCP+ session closed")
+)
+
+// rmcpStatusError is a failed session setup step.
+type rmcpStatusError struct {
+	step   string
+	status uint8
+}
+
+func (e *rmcpStatusError) Error() string {
+	return fmt.Sprintf("%s failed with RMCP+ status %#02x", e.step, e.status)
+}
+
+// openRMCPSession opens an RMCP+ session with the BMC at host:port, at the
+// given privilege level, with the cipher suite (if non-zero) or the best one
+// the BMC supports.
+func openRMCPSession(ctx context.Context, host string, cfg Config, cred Credentials) (*rmcpSession, error) {
+	suites := rmcpCipherSuites
+	if cfg.CipherSuiteID != 0 {
+		suites = nil
+		for _, cs := range rmcpCipherSuites {
+			if int(cs.id) == cfg.CipherSuiteID {
+				suites = append(suites, cs)
+			}
+		}
+		if len(suites) == 0 {
+			return nil, fmt.Errorf("cipher suite %d isn't supported for SOL, only 3 and 17 are", cfg.CipherSuiteID)
+		}
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve BMC address: %w", err)
+	}
+
+	for _, suite := range suites {
+		udp, err := net.DialUDP("udp", nil, raddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial BMC: %w", err)
+		}
+		s := &rmcpSession{
+			udp:     udp,
+			timeout: cfg.Timeout,
+			suite:   suite,
+			waiting: make(map[uint8]chan []byte),
+			done:    make(chan struct{}),
+		}
+		err = s.handshake(ctx, cred, uint8(privilegeLevels[strings.ToLower(cfg.Privilege)]))
+		if err == nil {
+			return s, nil
+		}
+		udp.Close()
+		if !errors.Is(err, errRMCPNoSuiteMatch) {
+			return nil, err
+		}
+	}
+	return nil, errRMCPNoSuiteMatch
+}
+
+// handshake runs the RMCP+ Open Session and RAKP exchanges, see section 13.17
+// of the IPMI spec, and starts the read loop.
+func (s *rmcpSession) handshake(ctx context.Context, cred Credentials, priv uint8) error {
+	var consoleRand, bmcRand, guid [16]byte
+	if _, err := rand.Read(consoleRand[:]); err != nil {
+		return fmt.Errorf("failed to generate random number: %w", err)
+	}
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	s.consoleID = binary.LittleEndian.Uint32(id[:]) | 1
+
+	// Open Session.
+	req := []byte{0, priv, 0, 0}
+	req = binary.LittleEndian.AppendUint32(req, s.consoleID)
+	req = append(req, 0x00, 0, 0, 8, s.suite.authAlg, 0, 0, 0)
+	req = append(req, 0x01, 0, 0, 8, s.suite.integrityAlg, 0, 0, 0)
+	req = append(req, 0x02, 0, 0, 8, s.suite.crypt, 0, 0, 0)
+	resp, err := s.setup(ctx, payloadOpenSessionRequest, payloadOpenSessionResponse, req)
+	if err != nil {
+		return err
+	}
+	if resp[1] == rmcpStatusNoCipherSuiteMatch {
+		return errRMCPNoSuiteMatch
+	}
+	if resp[1] != rmcpStatusOK {
+		return &rmcpStatusError{step: "open session", status: resp[1]}
+	}
+	if len(resp) < 12 {
+		return errRMCPMalformed
+	}
+	s.bmcID = binary.LittleEndian.Uint32(resp[8:12])
+
+	// RAKP 1 and 2.
+	role := rakpNameOnlyLookup | priv
+	roleAndUser := append([]byte{role, byte(len(cred.User))}, cred.User...)
+	req = []byte{0, 0, 0, 0}
+	req = binary.LittleEndian.AppendUint32(req, s.bmcID)
+	req = append(req, consoleRand[:]...)
+	req = append(req, role, 0, 0, byte(len(cred.User)))
+	req = append(req, cred.User...)
+	resp, err = s.setup(ctx, payloadRAKP1, payloadRAKP2, req)
+	if err != nil {
+		return err
+	}
+	if resp[1] != rmcpStatusOK {
+		return &rmcpStatusError{step: "RAKP 2", status: resp[1]}
+	}
+	if len(resp) < 40 {
+		return errRMCPMalformed
+	}
+	copy(bmcRand[:], resp[8:24])
+	copy(guid[:], resp[24:40])
+
+	key := make([]byte, 20)
+	copy(key, cred.Password)
+
+	var input bytes.Buffer
+	binary.Write(&input, binary.LittleEndian, s.consoleID)
+	binary.Write(&input, binary.LittleEndian, s.bmcID)
+	input.Write(consoleRand[:])
+	input.Write(bmcRand[:])
+	input.Write(guid[:])
+	input.Write(roleAndUser)
+	if !hmac.Equal(resp[40:], s.mac(key, input.Bytes())) {
+		return errors.New("BMC's RAKP 2 auth code doesn't match, the password is probably wrong")
+	}
+
+	// RAKP 3 and 4.
+	input.Reset()
+	input.Write(bmcRand[:])
+	binary.Write(&input, binary.LittleEndian, s.consoleID)
+	input.Write(roleAndUser)
+	req = []byte{0, rmcpStatusOK, 0, 0}
+	req = binary.LittleEndian.AppendUint32(req, s.bmcID)
+	req = append(req, s.mac(key, input.Bytes())...)
+	resp, err = s.setup(ctx, payloadRAKP3, payloadRAKP4, req)
+	if err != nil {
+		return err
+	}
+	if resp[1] != rmcpStatusOK {
+		return &rmcpStatusError{step: "RAKP 4", status: resp[1]}
+	}
+
+	input.Reset()
+	input.Write(consoleRand[:])
+	input.Write(bmcRand[:])
+	input.Write(roleAndUser)
+	sik := s.mac(key, input.Bytes())
+	s.k1 = s.mac(sik, bytes.Repeat([]byte{0x01}, 20))
+	s.k2 = s.mac(sik, bytes.Repeat([]byte{0x02}, 20))
+
+	input.Reset()
+	input.Write(consoleRand[:])
+	binary.Write(&input, binary.LittleEndian, s.bmcID)
+	input.Write(guid[:])
+	if !hmac.Equal(resp[8:], s.mac(sik, input.Bytes())[:s.suite.integrityLen]) {
+		return errors.New("BMC's RAKP 4 integrity check value doesn't match")
+	}
+
+	go s.readLoop()
+
+	if _, err := s.command(ctx, netFnApp, cmdSetSessionPrivilegeLevel, []byte{priv}); err != nil {
+		s.close()
+		return fmt.Errorf("failed to set session privilege level: %w", err)
+	}
+	return nil
+}
+
+// setup sends a session setup message, and waits for the response to it.
+func (s *rmcpSession) setup(ctx context.Context, reqType, respType uint8, req []byte) ([]byte, error) {
+	if err := s.write(rmcpPacket(reqType, 0, 0, req)); err != nil {
+		return nil, err
+	}
+	deadline := s.deadline(ctx)
+	buf := make([]byte, 1024)
+	for {
+		s.udp.SetReadDeadline(deadline)
+		n, err := s.udp.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		pkt := buf[:n]
+		if len(pkt) < 16 || pkt[5]&0x3f != respType {
+			continue
+		}
+		payload, ok := rmcpPayload(pkt)
+		// Every setup response starts with the tag and a status, and then
+		// (unless the status is an error) our session ID.
+		if !ok || len(payload) < 2 {
+			continue
+		}
+		return payload, nil
+	}
+}
+
+// command sends an IPMI request to the BMC and returns the response data,
+// after the completion code.
+func (s *rmcpSession) command(ctx context.Context, netFn, cmd uint8, data []byte) ([]byte, error) {
+	s.mu.Lock()
+	s.rqSeq = (s.rqSeq + 1) & 0x3f
+	rqSeq := s.rqSeq
+	ch := make(chan []byte, 1)
+	s.waiting[rqSeq] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.waiting, rqSeq)
+		s.mu.Unlock()
+	}()
+
+	msg := []byte{bmcAddr, netFn << 2, 0, remoteConsole, rqSeq << 2, cmd}
+	msg[2] = ipmbChecksum(msg[:2])
+	msg = append(msg, data...)
+	msg = append(msg, ipmbChecksum(msg[3:]))
+	if err := s.send(payloadIPMI, msg); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(time.Until(s.deadline(ctx)))
+	defer timer.Stop()
+	select {
+	case resp := <-ch:
+		if len(resp) < 8 {
+			return nil, errRMCPMalformed
+		}
+		if cc := resp[6]; cc != 0 {
+			return nil, &completionError{netFn: netFn, cmd: cmd, code: cc}
+		}
+		return resp[7 : len(resp)-1], nil
+	case <-s.done:
+		return nil, s.err
+	case <-timer.C:
+		return nil, &net.OpError{Op: "read", Net: "udp", Err: errRMCPTimeout{}}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// errRMCPTimeout is a net.Error, so callers can tell the BMC didn't answer.
+type errRMCPTimeout struct{}
+
+func (errRMCPTimeout) Error() string   { return "timed out waiting for BMC" }
+func (errRMCPTimeout) Timeout() bool   { return true }
+func (errRMCPTimeout) Temporary() bool { return true }
+
+// completionError is an IPMI response with a non-zero completion code.
+type completionError struct {
+	netFn, cmd, code uint8
+}
+
+func (e *completionError) Error() string {
+	return fmt.Sprintf("command %#02x/%#02x failed with completion code %#02x", e.netFn, e.cmd, e.code)
+}
+
+// send encrypts and authenticates the payload, and sends it to the BMC.
+func (s *rmcpSession) send(payloadType uint8, payload []byte) error {
+	s.mu.Lock()
+	s.seq++
+	seq := s.seq
+	s.mu.Unlock()
+
+	enc, err := s.encrypt(payload)
+	if err != nil {
+		return err
+	}
+	pkt := rmcpPacket(0xc0|payloadType, s.bmcID, seq, enc)
+	pad := (4 - (len(pkt)-4+2)%4) % 4
+	pkt = append(pkt, bytes.Repeat([]byte{0xff}, pad)...)
+	pkt = append(pkt, byte(pad), 0x07)
+	pkt = append(pkt, s.integrity(pkt[4:])...)
+	return s.write(pkt)
+}
+
+func (s *rmcpSession) write(pkt []byte) error {
+	if _, err := s.udp.Write(pkt); err != nil {
+		return fmt.Errorf("failed to send to BMC: %w", err)
+	}
+	return nil
+}
+
+// readLoop reads packets from the BMC until the session is closed.
+func (s *rmcpSession) readLoop() {
+	buf := make([]byte, 2048)
+	for {
+		s.udp.SetReadDeadline(time.Time{})
+		n, err := s.udp.Read(buf)
+		if err != nil {
+			s.mu.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.mu.Unlock()
+			close(s.done)
+			return
+		}
+		payloadType, payload, ok := s.open(buf[:n])
+		if !ok {
+			continue
+		}
+		switch payloadType {
+		case payloadIPMI:
+			if len(payload) < 7 {
+				continue
+			}
+			s.mu.Lock()
+			ch := s.waiting[payload[4]>>2]
+			s.mu.Unlock()
+			if ch != nil {
+				select {
+				case ch <- payload:
+				default:
+				}
+			}
+		case payloadSOL:
+			s.mu.Lock()
+			onSOL := s.onSOL
+			s.mu.Unlock()
+			if onSOL != nil {
+				onSOL(payload)
+			}
+		}
+	}
+}
+
+// open checks a session packet's integrity and decrypts its payload.
+func (s *rmcpSession) open(pkt []byte) (uint8, []byte, bool) {
+	if len(pkt) < 16 || pkt[0] != rmcpVersion || pkt[3] != rmcpClassIPMI || pkt[4] != authTypeRMCPPlus {
+		return 0, nil, false
+	}
+	if pkt[5]&0xc0 != 0xc0 || binary.LittleEndian.Uint32(pkt[6:10]) != s.consoleID {
+		return 0, nil, false
+	}
+	n := len(pkt) - s.suite.integrityLen
+	if n < 16 || !hmac.Equal(pkt[n:], s.integrity(pkt[4:n])) {
+		return 0, nil, false
+	}
+	payload, ok := rmcpPayload(pkt)
+	if !ok {
+		return 0, nil, false
+	}
+	plain, err := s.decrypt(payload)
+	if err != nil {
+		return 0, nil, false
+	}
+	return pkt[5] & 0x3f, plain, true
+}
+
+// close closes the session's socket, without telling the BMC, and waits for
+// the read loop to exit.
+func (s *rmcpSession) close() {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = errRMCPClosed
+	}
+	s.mu.Unlock()
+	s.udp.Close()
+	<-s.done
+}
+
+func (s *rmcpSession) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+func (s *rmcpSession) mac(key, data []byte) []byte {
+	h := hmac.New(s.suite.hash, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (s *rmcpSession) integrity(data []byte) []byte {
+	return s.mac(s.k1, data)[:s.suite.integrityLen]
+}
+
+// encrypt encrypts a payload with AES-CBC-128, see section 13.29 of the IPMI
+// spec.
+func (s *rmcpSession) encrypt(payload []byte) ([]byte, error) {
+	pad := (aes.BlockSize - (len(payload)+1)%aes.BlockSize) % aes.BlockSize
+	plain := append([]byte{}, payload...)
+	for i := 1; i <= pad; i++ {
+		plain = append(plain, byte(i))
+	}
+	plain = append(plain, byte(pad))
+
+	out := make([]byte, aes.BlockSize+len(plain))
+	if _, err := rand.Read(out[:aes.BlockSize]); err != nil {
+		return nil, fmt.Errorf("failed to generate IV: %w", err)
+	}
+	block, err := aes.NewCipher(s.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], plain)
+	return out, nil
+}
+
+func (s *rmcpSession) decrypt(payload []byte) ([]byte, error) {
+	if len(payload) < 2*aes.BlockSize || len(payload)%aes.BlockSize != 0 {
+		return nil, errRMCPMalformed
+	}
+	block, err := aes.NewCipher(s.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(payload)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, payload[:aes.BlockSize]).CryptBlocks(out, payload[aes.BlockSize:])
+	pad := int(out[len(out)-1])
+	if pad >= len(out) {
+		return nil, errRMCPMalformed
+	}
+	return out[:len(out)-pad-1], nil
+}
+
+// rmcpPacket wraps a payload in an RMCP+ packet, without a session trailer.
+func rmcpPacket(payloadType uint8, sessionID, seq uint32, payload []byte) []byte {
+	pkt := []byte{rmcpVersion, 0, 0xff, rmcpClassIPMI, authTypeRMCPPlus, payloadType}
+	pkt = binary.LittleEndian.AppendUint32(pkt, sessionID)
+	pkt = binary.LittleEndian.AppendUint32(pkt, seq)
+	pkt = binary.LittleEndian.AppendUint16(pkt, uint16(len(payload)))
+	return append(pkt, payload...)
+}
+
+// rmcpPayload returns the payload of an RMCP+ packet.
+func rmcpPayload(pkt []byte) ([]byte, bool) {
+	length := int(binary.LittleEndian.Uint16(pkt[14:16]))
+	if len(pkt) < 16+length {
+		return nil, false
+	}
+	return pkt[16 : 16+length], true
+}
+
+func ipmbChecksum(b []byte) uint8 {
+	var sum uint8
+	for _, v := range b {
+		sum += v
+	}
+	return -sum
+}
@@ -0,0 +1,194 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bougou/go-ipmi"
+)
+
+// sdrCache is our copy of a host's sensor data repository (SDR), which
+// describes each sensor: its number, name, units and how to convert its raw
+// readings. Walking the repository takes a round trip per record, which is
+// slow on older BMCs, and it almost never changes, so we only reload it when
+// the BMC says it has.
+type sdrCache struct {
+	lastAddition time.Time
+	lastErase    time.Time
+	sensors      []*sdrSensor
+}
+
+// sdrSensor is a full or compact sensor record from the SDR.
+type sdrSensor struct {
+	number uint8
+	name   string
+	owner  ipmi.GeneratorID
+	// full is nil for compact records, which can't describe analog readings.
+	full             *ipmi.SDRFull
+	sensorType       ipmi.SensorType
+	eventReadingType ipmi.EventReadingType
+	unit             ipmi.SensorUnit
+}
+
+// sdr returns the host's SDR, reloading it if the BMC reports that it's
+// changed since we last read it.
+func (cn *conn) sdr(ctx context.Context) (*sdrCache, error) {
+	info, err := cn.ic.GetSDRRepoInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SDR repository info: %w", err)
+	}
+
+	cur := cn.sdrs
+	if cur != nil && info.MostRecentAdditionTime.Equal(cur.lastAddition) && info.MostRecentEraseTime.Equal(cur.lastErase) {
+		return cur, nil
+	}
+
+	sensors, err := cn.loadSDR(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cn.sdrs = &sdrCache{
+		lastAddition: info.MostRecentAdditionTime,
+		lastErase:    info.MostRecentEraseTime,
+		sensors:      sensors,
+	}
+	return cn.sdrs, nil
+}
+
+// loadSDR walks the host's whole SDR, without reading any sensors.
+func (cn *conn) loadSDR(ctx context.Context) ([]*sdrSensor, error) {
+	var out []*sdrSensor
+	recordID := uint16(0)
+	for {
+		res, err := cn.ic.GetSDR(ctx, recordID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SDR record %#04x: %w", recordID, err)
+		}
+		sdr, err := ipmi.ParseSDR(res.RecordData, res.NextRecordID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SDR record %#04x: %w", recordID, err)
+		}
+
+		switch sdr.RecordHeader.RecordType {
+		case ipmi.SDRRecordTypeFullSensor:
+			out = append(out, &sdrSensor{
+				number:           uint8(sdr.Full.SensorNumber),
+				name:             strings.TrimSpace(string(sdr.Full.IDStringBytes)),
+				owner:            sdr.Full.GeneratorID,
+				full:             sdr.Full,
+				sensorType:       sdr.Full.SensorType,
+				eventReadingType: sdr.Full.SensorEventReadingType,
+				unit:             sdr.Full.SensorUnit,
+			})
+		case ipmi.SDRRecordTypeCompactSensor:
+			out = append(out, &sdrSensor{
+				number:           uint8(sdr.Compact.SensorNumber),
+				name:             strings.TrimSpace(string(sdr.Compact.IDStringBytes)),
+				owner:            sdr.Compact.GeneratorID,
+				sensorType:       sdr.Compact.SensorType,
+				eventReadingType: sdr.Compact.SensorEventReadingType,
+				unit:             sdr.Compact.SensorUnit,
+			})
+		}
+
+		if res.NextRecordID == sdrLastRecordID {
+			return out, nil
+		}
+		recordID = res.NextRecordID
+	}
+}
+
+// The end-of-list marker for SDR record IDs.
+const sdrLastRecordID = 0xffff
+
+// byName returns the first sensor with the given name, or nil if there isn't
+// one.
+func (sc *sdrCache) byName(name string) *sdrSensor {
+	for _, s := range sc.sensors {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// read reads the sensor's current value with Get Sensor Reading.
+func (cn *conn) read(ctx context.Context, s *sdrSensor) (*Sensor, error) {
+	out := &Sensor{
+		Number: s.number,
+		Name:   s.name,
+		Type:   s.sensorType.String(),
+		Unit:   s.unit.String(),
+		Status: "N/A",
+	}
+
+	// Sensors can belong to controllers other than the BMC, which it forwards
+	// the request to.
+	ctx = ipmi.WithCommandContext(ctx, (&ipmi.CommandContext{}).
+		WithResponderAddr(uint8(s.owner.OwnerID())).
+		WithResponderLUN(uint8(s.owner.LUN())))
+
+	res, err := cn.ic.GetSensorReading(ctx, s.number)
+	if err != nil {
+		var respErr *ipmi.ResponseError
+		if errors.As(err, &respErr) && respErr.CompletionCode() == ipmi.CompletionCodeRequestedDataNotPresent {
+			// The sensor isn't there, e.g. a DIMM slot that's empty.
+			return out, nil
+		}
+		return nil, fmt.Errorf("failed to read sensor %q (%#02x): %w", s.name, s.number, err)
+	}
+	if res.SensorScanningDisabled || res.ReadingUnavailable {
+		return out, nil
+	}
+
+	analog := s.full != nil && s.unit.IsAnalog()
+	out.HasReading = analog
+	if analog {
+		out.Value = s.full.ConvertReading(res.Reading)
+	}
+	if s.eventReadingType.IsThreshold() && analog {
+		out.Status = thresholdStatus(res)
+	} else {
+		out.Status = fmt.Sprintf("0x%04x", discreteStates(res.ActiveStates))
+	}
+	return out, nil
+}
+
+// thresholdStatus returns the most severe threshold the reading has crossed,
+// or "ok".
+func thresholdStatus(res *ipmi.GetSensorReadingResponse) string {
+	switch {
+	case res.Above_UNR:
+		return string(ipmi.SensorThresholdStatus_UNR)
+	case res.Below_LNR:
+		return string(ipmi.SensorThresholdStatus_LNR)
+	case res.Above_UCR:
+		return string(ipmi.SensorThresholdStatus_UCR)
+	case res.Below_LCR:
+		return string(ipmi.SensorThresholdStatus_LCR)
+	case res.Above_UNC:
+		return string(ipmi.SensorThresholdStatus_UNC)
+	case res.Below_LNC:
+		return string(ipmi.SensorThresholdStatus_LNC)
+	}
+	return string(ipmi.SensorThresholdStatus_OK)
+}
+
+// discreteStates packs a discrete sensor's active states into a bitmask, with
+// state 0 as the lowest bit.
+func discreteStates(m ipmi.Mask_DiscreteEvent) uint16 {
+	states := []bool{
+		m.State_0, m.State_1, m.State_2, m.State_3, m.State_4, m.State_5, m.State_6, m.State_7,
+		m.State_8, m.State_9, m.State_10, m.State_11, m.State_12, m.State_13, m.State_14,
+	}
+	var out uint16
+	for i, set := range states {
+		if set {
+			out |= 1 << i
+		}
+	}
+	return out
+}
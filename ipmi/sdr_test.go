@@ -0,0 +1,136 @@
+package ipmi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+)
+
+func TestSDRCache(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x01, Name: "Inlet Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 19)
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 23)
+
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	checkRequests := func(wantSDR, wantReadings int) {
+		t.Helper()
+		if got := bmc.Requests(ipmisim.GetSDR); got != wantSDR {
+			t.Errorf("BMC got %d Get SDR requests, want %d", got, wantSDR)
+		}
+		if got := bmc.Requests(ipmisim.GetSensorReading); got != wantReadings {
+			t.Errorf("BMC got %d Get Sensor Reading requests, want %d", got, wantReadings)
+		}
+	}
+
+	// The first read loads the whole SDR, then reads just the one sensor.
+	if _, err := c.AmbientTemp(ctx, bmc.Host); err != nil {
+		t.Fatalf("AmbientTemp: %v", err)
+	}
+	checkRequests(2, 1)
+
+	// After that, we only need to read the sensor.
+	if _, err := c.AmbientTemp(ctx, bmc.Host); err != nil {
+		t.Fatalf("AmbientTemp: %v", err)
+	}
+	if _, err := c.Sensors(ctx, bmc.Host); err != nil {
+		t.Fatalf("Sensors: %v", err)
+	}
+	checkRequests(2, 4)
+
+	// Changing the SDR should make us reload it.
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x02, Name: "Exhaust Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 30)
+	sensors, err := c.Sensors(ctx, bmc.Host)
+	if err != nil {
+		t.Fatalf("Sensors: %v", err)
+	}
+	if len(sensors) != 3 {
+		t.Errorf("got %d sensors after adding one, want 3", len(sensors))
+	}
+	checkRequests(5, 7)
+}
+
+func TestSensorStatus(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	bmc.AddSensor(ipmisim.Sensor{Number: 0x0e, Name: "Ambient Temp", Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 23)
+
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := c.AmbientTemp(ctx, bmc.Host); err != nil {
+		t.Fatalf("AmbientTemp: %v", err)
+	}
+
+	// A failing read should fail the whole request, rather than silently
+	// leaving out the sensor.
+	bmc.Fail(ipmisim.GetSensorReading, ipmisim.CompletionNodeBusy)
+	if _, err := c.Sensors(ctx, bmc.Host); err == nil {
+		t.Error("Sensors succeeded with a busy BMC, want an error")
+	}
+
+	// But a sensor that isn't there is just missing a reading.
+	bmc.Fail(ipmisim.GetSensorReading, ipmisim.CompletionNotPresent)
+	sensors, err := c.Sensors(ctx, bmc.Host)
+	if err != nil {
+		t.Fatalf("Sensors: %v", err)
+	}
+	if len(sensors) != 1 || sensors[0].HasReading || sensors[0].Status != "N/A" {
+		t.Errorf("got sensors %+v, want one without a reading", sensors)
+	}
+	if _, err := c.AmbientTemp(ctx, bmc.Host); err == nil {
+		t.Error("AmbientTemp succeeded without a reading, want an error")
+	}
+}
+
+// BenchmarkPoll measures how long it takes to poll one blade for its sensors
+// and ambient temp, over a link with a realistic round trip time. The "walk"
+// case is how we used to do it, walking the SDR (and reading thresholds) every
+// time, and "cached" reads each sensor by number from our cached copy of the
+// SDR.
+func BenchmarkPoll(b *testing.B) {
+	bmc := ipmisim.New(b, "root", "calvin")
+	// An M610's iDRAC6 has around 30 sensors.
+	for i := 1; i <= 30; i++ {
+		name := fmt.Sprintf("Temp %d", i)
+		if i == 20 {
+			name = "Ambient Temp"
+		}
+		bmc.AddSensor(ipmisim.Sensor{Number: uint8(i), Name: name, Type: ipmisim.TypeTemperature, Unit: ipmisim.UnitDegreesC}, 25)
+	}
+	bmc.SetLatency(time.Millisecond)
+
+	c := newSimClient(bmc, "root", "calvin")
+	ctx := context.Background()
+
+	b.Run("walk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := c.do(ctx, bmc.Host, func(cn *conn) error {
+				if _, err := cn.ic.GetSensors(ctx); err != nil {
+					return err
+				}
+				_, err := cn.ic.GetSDRBySensorName(ctx, "Ambient Temp")
+				return err
+			})
+			if err != nil {
+				b.Fatalf("failed to poll: %v", err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := c.Sensors(ctx, bmc.Host); err != nil {
+				b.Fatalf("Sensors: %v", err)
+			}
+			if _, err := c.AmbientTemp(ctx, bmc.Host); err != nil {
+				b.Fatalf("AmbientTemp: %v", err)
+			}
+		}
+	})
+}
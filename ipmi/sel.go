@@ -0,0 +1,218 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bougou/go-ipmi"
+)
+
+// Severity is how worried we should be about an event.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Event is a decoded record from a BMC's System Event Log (SEL).
+type Event struct {
+	RecordID  uint16
+	Timestamp time.Time
+	// SensorType is the IPMI sensor type of the sensor that logged the event,
+	// e.g. "Memory" or "Power Supply", or "OEM" for OEM records.
+	SensorType   string
+	SensorNumber uint8
+	// Deassertion is true if the event is the condition going away, e.g. a
+	// temperature dropping back below a threshold.
+	Deassertion bool
+	Description string
+	Severity    Severity
+}
+
+// selCursor tracks how far into a host's SEL we've read.
+type selCursor struct {
+	lastRecordID uint16
+	lastErase    time.Time
+}
+
+// The end-of-list marker for SEL record IDs.
+const selLastRecordID = 0xffff
+
+// NewEvents returns the events that have been added to the host's SEL since the
+// last call. The first call returns everything currently in the SEL.
+func (c *Client) NewEvents(ctx context.Context, host string) ([]*Event, error) {
+	var events []*Event
+	err := c.do(ctx, host, func(cn *conn) error {
+		var err error
+		events, err = cn.newEvents(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (cn *conn) newEvents(ctx context.Context) ([]*Event, error) {
+	info, err := cn.ic.GetSELInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SEL info: %w", err)
+	}
+
+	cur := cn.sel
+	if cur == nil || !info.RecentEraseTime.Equal(cur.lastErase) || info.Entries == 0 {
+		// The SEL was cleared (or we've never read it), start from the top.
+		cur = &selCursor{lastErase: info.RecentEraseTime}
+		cn.sel = cur
+	}
+	if info.Entries == 0 {
+		return nil, nil
+	}
+
+	start := uint16(0)
+	if cur.lastRecordID != 0 {
+		entry, err := cn.ic.GetSELEntry(ctx, 0, cur.lastRecordID)
+		if err != nil {
+			var respErr *ipmi.ResponseError
+			if !errors.As(err, &respErr) {
+				return nil, fmt.Errorf("failed to load last seen SEL entry: %w", err)
+			}
+			// The record we last saw is gone, which happens when the SEL is
+			// cleared in between polls. Start from the top.
+			log.Printf("last seen SEL entry %#04x on %q is gone, reading SEL from the start: %v", cur.lastRecordID, cn.host, err)
+		} else if entry.NextRecordID == selLastRecordID {
+			return nil, nil
+		} else {
+			start = entry.NextRecordID
+		}
+	}
+
+	sels, err := cn.ic.GetSELEntries(ctx, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SEL entries: %w", err)
+	}
+
+	out := make([]*Event, 0, len(sels))
+	for _, sel := range sels {
+		out = append(out, decodeEvent(sel))
+		cur.lastRecordID = sel.RecordID
+	}
+	return out, nil
+}
+
+func decodeEvent(sel *ipmi.SEL) *Event {
+	e := &Event{
+		RecordID:   sel.RecordID,
+		SensorType: "OEM",
+		Severity:   SeverityInfo,
+	}
+
+	switch {
+	case sel.Standard != nil:
+		s := sel.Standard
+		e.Timestamp = s.Timestamp
+		e.SensorType = s.SensorType.String()
+		e.SensorNumber = uint8(s.SensorNumber)
+		e.Deassertion = bool(s.EventDir)
+		e.Description = s.EventString()
+		e.Severity = eventSeverity(s)
+	case sel.OEMTimestamped != nil:
+		e.Timestamp = sel.OEMTimestamped.Timestamp
+		e.Description = fmt.Sprintf("OEM record from manufacturer %d", sel.OEMTimestamped.ManufacturerID)
+	default:
+		e.Description = "OEM record"
+	}
+	if e.Description == "" {
+		e.Description = fmt.Sprintf("unknown event (type %#02x, data %s)", uint8(sel.Standard.EventReadingType), sel.Standard.EventData.String())
+	}
+	return e
+}
+
+// Severities for the sensor-specific event offsets we care about, see table
+// 42-3 in the IPMI v2 spec. Anything not listed here is info.
+var sensorSpecificSeverities = map[ipmi.SensorType]map[uint8]Severity{
+	ipmi.SensorTypeProcessor: {
+		0x00: SeverityCritical, // IERR
+		0x01: SeverityCritical, // Thermal Trip
+		0x02: SeverityCritical, // FRB1/BIST failure
+		0x03: SeverityCritical, // FRB2/Hang in POST failure
+		0x04: SeverityCritical, // FRB3/Processor Startup/Initialization failure
+		0x05: SeverityCritical, // Configuration Error
+		0x08: SeverityInfo,     // Processor Presence detected
+		0x0a: SeverityWarning,  // Processor Automatically Throttled
+		0x0b: SeverityCritical, // Machine Check Exception
+	},
+	ipmi.SensorTypePowerSupply: {
+		0x01: SeverityCritical, // Power Supply Failure detected
+		0x02: SeverityWarning,  // Predictive Failure
+		0x03: SeverityCritical, // Power Supply input lost (AC/DC)
+		0x04: SeverityCritical, // Power Supply input lost or out-of-range
+		0x05: SeverityWarning,  // Power Supply input out-of-range, but present
+		0x06: SeverityWarning,  // Configuration error
+	},
+	ipmi.SensorTypeMemory: {
+		0x00: SeverityWarning,  // Correctable ECC
+		0x01: SeverityCritical, // Uncorrectable ECC
+		0x02: SeverityCritical, // Parity
+		0x03: SeverityCritical, // Memory Scrub Failed
+		0x04: SeverityCritical, // Memory Device Disabled
+		0x05: SeverityWarning,  // Correctable ECC logging limit reached
+		0x07: SeverityWarning,  // Configuration error
+		0x0a: SeverityCritical, // Critical Overtemperature
+	},
+	ipmi.SensorTypeWatchdog1: {
+		0x00: SeverityCritical, // BIOS Watchdog Reset
+		0x01: SeverityCritical, // OS Watchdog Reset
+		0x02: SeverityCritical, // OS Watchdog Shut Down
+		0x03: SeverityCritical, // OS Watchdog Power Down
+		0x04: SeverityCritical, // OS Watchdog Power Cycle
+		0x05: SeverityWarning,  // OS Watchdog NMI / Diagnostic Interrupt
+		0x06: SeverityWarning,  // OS Watchdog Expired, status only
+	},
+	ipmi.SensorTypeWatchdog2: {
+		0x00: SeverityWarning,  // Timer expired, status only
+		0x01: SeverityCritical, // Hard Reset
+		0x02: SeverityCritical, // Power Down
+		0x03: SeverityCritical, // Power Cycle
+		0x08: SeverityWarning,  // Timer interrupt
+	},
+	ipmi.SensorTypeCriticalInterrupt: {
+		0x00: SeverityCritical, // Front Panel NMI / Diagnostic Interrupt
+		0x04: SeverityCritical, // PCI PERR
+		0x05: SeverityCritical, // PCI SERR
+		0x07: SeverityWarning,  // Bus Correctable Error
+		0x08: SeverityCritical, // Bus Uncorrectable Error
+		0x09: SeverityCritical, // Fatal NMI
+		0x0a: SeverityCritical, // Bus Fatal Error
+	},
+}
+
+func eventSeverity(s *ipmi.SELStandard) Severity {
+	// Things getting better isn't worth worrying about.
+	if s.EventDir == ipmi.EventDirDeassertion {
+		return SeverityInfo
+	}
+
+	offset := s.EventData.EventReadingOffset()
+	switch s.EventReadingType {
+	case ipmi.EventReadingTypeThreshold:
+		// Offsets go in pairs (going low, going high) of lower non-critical,
+		// lower critical, lower non-recoverable, then the same for upper.
+		switch offset / 2 {
+		case 0, 3:
+			return SeverityWarning
+		case 1, 2, 4, 5:
+			return SeverityCritical
+		}
+	case ipmi.EventReadingTypeSensorSpecific:
+		if sev, ok := sensorSpecificSeverities[s.SensorType][offset]; ok {
+			return sev
+		}
+	}
+	return SeverityInfo
+}
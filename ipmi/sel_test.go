@@ -0,0 +1,276 @@
+package ipmi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/bougou/go-ipmi"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		desc       string
+		sensorType uint8
+		// Event direction in the top bit, event/reading type in the rest.
+		dirType      uint8
+		offset       uint8
+		wantType     string
+		wantSeverity Severity
+		wantDeassert bool
+	}{
+		{
+			desc:         "correctable ECC",
+			sensorType:   0x0c,
+			dirType:      0x6f,
+			offset:       0x00,
+			wantType:     "Memory",
+			wantSeverity: SeverityWarning,
+		},
+		{
+			desc:         "uncorrectable ECC",
+			sensorType:   0x0c,
+			dirType:      0x6f,
+			offset:       0x01,
+			wantType:     "Memory",
+			wantSeverity: SeverityCritical,
+		},
+		{
+			desc:         "PSU failure",
+			sensorType:   0x08,
+			dirType:      0x6f,
+			offset:       0x01,
+			wantType:     "Power Supply",
+			wantSeverity: SeverityCritical,
+		},
+		{
+			desc:         "PSU presence",
+			sensorType:   0x08,
+			dirType:      0x6f,
+			offset:       0x00,
+			wantType:     "Power Supply",
+			wantSeverity: SeverityInfo,
+		},
+		{
+			desc:         "thermal trip",
+			sensorType:   0x07,
+			dirType:      0x6f,
+			offset:       0x01,
+			wantType:     "Processor",
+			wantSeverity: SeverityCritical,
+		},
+		{
+			desc:         "watchdog hard reset",
+			sensorType:   0x23,
+			dirType:      0x6f,
+			offset:       0x01,
+			wantType:     "Watchdog2",
+			wantSeverity: SeverityCritical,
+		},
+		{
+			desc:         "upper critical going high",
+			sensorType:   0x01,
+			dirType:      0x01,
+			offset:       0x09,
+			wantType:     "Temperature",
+			wantSeverity: SeverityCritical,
+		},
+		{
+			desc:         "upper non-critical going high",
+			sensorType:   0x01,
+			dirType:      0x01,
+			offset:       0x07,
+			wantType:     "Temperature",
+			wantSeverity: SeverityWarning,
+		},
+		{
+			desc:         "upper critical deasserted",
+			sensorType:   0x01,
+			dirType:      0x81,
+			offset:       0x09,
+			wantType:     "Temperature",
+			wantSeverity: SeverityInfo,
+			wantDeassert: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			rec := []byte{
+				0x2a, 0x00, // Record ID
+				0x02,                   // Record type: standard
+				0x00, 0x00, 0x00, 0x60, // Timestamp
+				0x20, 0x00, // Generator ID
+				0x04, // EvM Rev
+				test.sensorType,
+				0x30, // Sensor number
+				test.dirType,
+				test.offset, 0xff, 0xff, // Event data
+			}
+			sel, err := ipmi.ParseSEL(rec)
+			if err != nil {
+				t.Fatalf("ParseSEL: %v", err)
+			}
+
+			got := decodeEvent(sel)
+			if got.RecordID != 0x2a {
+				t.Errorf("RecordID = %#x, want 0x2a", got.RecordID)
+			}
+			if got.SensorNumber != 0x30 {
+				t.Errorf("SensorNumber = %#x, want 0x30", got.SensorNumber)
+			}
+			if got.SensorType != test.wantType {
+				t.Errorf("SensorType = %q, want %q", got.SensorType, test.wantType)
+			}
+			if got.Severity != test.wantSeverity {
+				t.Errorf("Severity = %q, want %q", got.Severity, test.wantSeverity)
+			}
+			if got.Deassertion != test.wantDeassert {
+				t.Errorf("Deassertion = %t, want %t", got.Deassertion, test.wantDeassert)
+			}
+			if got.Description == "" {
+				t.Error("got no description")
+			}
+		})
+	}
+}
+
+func TestNewEvents(t *testing.T) {
+	// Correctable ECC errors on DIMMs, by sensor number.
+	ecc := func(dimm uint8) ipmisim.SELEntry {
+		return ipmisim.SELEntry{
+			Timestamp:    time.Date(2023, 6, 1, 12, 0, int(dimm), 0, time.UTC),
+			SensorType:   0x0c,
+			SensorNumber: dimm,
+			EventDirType: 0x6f,
+			EventData:    [3]byte{0x00, 0xff, 0xff},
+		}
+	}
+
+	tests := []struct {
+		desc string
+		// before runs before the first call to NewEvents, and between runs
+		// between the first and second.
+		before, between func(bmc *ipmisim.Server)
+		// The record IDs we want from each call.
+		wantFirst, wantSecond []uint16
+	}{
+		{
+			desc:       "empty",
+			before:     func(bmc *ipmisim.Server) {},
+			between:    func(bmc *ipmisim.Server) {},
+			wantFirst:  nil,
+			wantSecond: nil,
+		},
+		{
+			desc: "nothing new",
+			before: func(bmc *ipmisim.Server) {
+				bmc.AddSELEntry(ecc(1))
+				bmc.AddSELEntry(ecc(2))
+			},
+			between:   func(bmc *ipmisim.Server) {},
+			wantFirst: []uint16{1, 2},
+		},
+		{
+			desc: "resume from last record",
+			before: func(bmc *ipmisim.Server) {
+				bmc.AddSELEntry(ecc(1))
+				bmc.AddSELEntry(ecc(2))
+			},
+			between: func(bmc *ipmisim.Server) {
+				bmc.AddSELEntry(ecc(3))
+				bmc.AddSELEntry(ecc(4))
+			},
+			wantFirst:  []uint16{1, 2},
+			wantSecond: []uint16{3, 4},
+		},
+		{
+			desc:   "first events",
+			before: func(bmc *ipmisim.Server) {},
+			between: func(bmc *ipmisim.Server) {
+				bmc.AddSELEntry(ecc(1))
+			},
+			wantSecond: []uint16{1},
+		},
+		{
+			desc: "cleared",
+			before: func(bmc *ipmisim.Server) {
+				bmc.AddSELEntry(ecc(1))
+				bmc.AddSELEntry(ecc(2))
+			},
+			between: func(bmc *ipmisim.Server) {
+				// Record IDs start over, so the last one we saw is there again,
+				// but it's a different record.
+				bmc.ClearSEL()
+				bmc.AddSELEntry(ecc(3))
+				bmc.AddSELEntry(ecc(4))
+				bmc.AddSELEntry(ecc(5))
+			},
+			wantFirst:  []uint16{1, 2},
+			wantSecond: []uint16{1, 2, 3},
+		},
+		{
+			desc: "cleared and empty",
+			before: func(bmc *ipmisim.Server) {
+				bmc.AddSELEntry(ecc(1))
+			},
+			between: func(bmc *ipmisim.Server) {
+				bmc.ClearSEL()
+			},
+			wantFirst: []uint16{1},
+		},
+		{
+			desc: "last record missing",
+			before: func(bmc *ipmisim.Server) {
+				bmc.AddSELEntry(ecc(1))
+				bmc.AddSELEntry(ecc(2))
+			},
+			between: func(bmc *ipmisim.Server) {
+				// Without the last record we saw, we can't tell where we left off,
+				// so we start from the top.
+				bmc.DeleteSELEntry(2)
+				bmc.AddSELEntry(ecc(3))
+			},
+			wantFirst:  []uint16{1, 2},
+			wantSecond: []uint16{1, 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			bmc := ipmisim.New(t, "root", "calvin")
+			c := newSimClient(bmc, "root", "calvin")
+			t.Cleanup(func() { c.Close() })
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			recordIDs := func() []uint16 {
+				t.Helper()
+				events, err := c.NewEvents(ctx, bmc.Host)
+				if err != nil {
+					t.Fatalf("NewEvents: %v", err)
+				}
+				var ids []uint16
+				for _, e := range events {
+					ids = append(ids, e.RecordID)
+				}
+				return ids
+			}
+
+			test.before(bmc)
+			if diff := cmp.Diff(test.wantFirst, recordIDs()); diff != "" {
+				t.Errorf("unexpected records from first call (-want +got)\n%s", diff)
+			}
+			test.between(bmc)
+			if diff := cmp.Diff(test.wantSecond, recordIDs()); diff != "" {
+				t.Errorf("unexpected records from second call (-want +got)\n%s", diff)
+			}
+			// Nothing's changed since.
+			if diff := cmp.Diff([]uint16(nil), recordIDs()); diff != "" {
+				t.Errorf("unexpected records from third call (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package ipmi
+
+import (
+	"context"
+	"fmt"
+)
+
+// SelfTest is the result of a BMC's power-on self test.
+type SelfTest struct {
+	// Passed is true if the BMC didn't find anything wrong, or doesn't do a
+	// self test at all, in which case Implemented is false.
+	Passed      bool
+	Implemented bool
+	// Failures lists what's wrong if the self test failed, e.g.
+	// "sdr-inaccessible" or "firmware-corrupted". See SelfTestFailures.
+	Failures []string
+}
+
+// SelfTestFailures are the possible values of SelfTest.Failures, besides
+// "device-specific-0xNNNN" for failures only the BMC's manufacturer knows the
+// meaning of.
+var SelfTestFailures = []string{
+	"firmware-corrupted",
+	"boot-block-corrupted",
+	"fru-internal-use-corrupted",
+	"sdr-empty",
+	"ipmb-signal-lines",
+	"fru-inaccessible",
+	"sdr-inaccessible",
+	"sel-inaccessible",
+	"fatal-hardware-error",
+}
+
+// Self test result codes, see section 20.4 of the IPMI spec.
+const (
+	selfTestPassed         = 0x55
+	selfTestNotImplemented = 0x56
+	// The second byte says which devices are broken, with a bit for each of
+	// the first 8 SelfTestFailures.
+	selfTestCorrupted = 0x57
+	selfTestFatal     = 0x58
+)
+
+// SelfTest returns the host's BMC self test results, with Get Self Test
+// Results.
+func (c *Client) SelfTest(ctx context.Context, host string) (*SelfTest, error) {
+	var st *SelfTest
+	err := c.do(ctx, host, func(cn *conn) error {
+		res, err := cn.ic.GetSelfTestResults(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get self test results: %w", err)
+		}
+		st = parseSelfTest(res.Byte1, res.Byte2)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func parseSelfTest(code, detail uint8) *SelfTest {
+	switch code {
+	case selfTestPassed:
+		return &SelfTest{Passed: true, Implemented: true}
+	case selfTestNotImplemented:
+		return &SelfTest{Passed: true}
+	case selfTestCorrupted:
+		st := &SelfTest{Implemented: true}
+		for i, f := range SelfTestFailures[:8] {
+			if detail&(1<<i) != 0 {
+				st.Failures = append(st.Failures, f)
+			}
+		}
+		return st
+	case selfTestFatal:
+		return &SelfTest{Implemented: true, Failures: []string{"fatal-hardware-error"}}
+	}
+	return &SelfTest{
+		Implemented: true,
+		Failures:    []string{fmt.Sprintf("device-specific-%#02x%02x", code, detail)},
+	}
+}
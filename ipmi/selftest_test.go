@@ -0,0 +1,42 @@
+package ipmi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		desc         string
+		code, detail uint8
+		want         *SelfTest
+	}{
+		{"passed", 0x55, 0x00, &SelfTest{Passed: true, Implemented: true}},
+		{"not implemented", 0x56, 0x00, &SelfTest{Passed: true}},
+		{"corrupted", 0x57, 0x81, &SelfTest{Implemented: true, Failures: []string{"firmware-corrupted", "sel-inaccessible"}}},
+		{"fatal", 0x58, 0x00, &SelfTest{Implemented: true, Failures: []string{"fatal-hardware-error"}}},
+		{"device specific", 0x60, 0x12, &SelfTest{Implemented: true, Failures: []string{"device-specific-0x6012"}}},
+	}
+
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			bmc.SetSelfTest(test.code, test.detail)
+			got, err := c.SelfTest(ctx, bmc.Host)
+			if err != nil {
+				t.Fatalf("SelfTest: %v", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("unexpected self test results (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package ipmi
+
+import "context"
+
+// Sensor is the current reading of one sensor from a BMC's sensor data
+// repository (SDR).
+type Sensor struct {
+	Number uint8
+	Name   string
+	// Type is the IPMI sensor type, e.g. "Temperature", "Voltage" or "Fan".
+	Type string
+	// Unit is e.g. "degrees C", "Volts" or "RPM", or "discrete" for sensors
+	// that report states rather than numeric readings.
+	Unit string
+	// Value is only meaningful if HasReading is true.
+	Value      float64
+	HasReading bool
+	// Status is "ok" or a threshold the reading has crossed (e.g. "ucr" for
+	// upper critical) for threshold sensors, the active states as a hex
+	// bitmask for discrete sensors, and "N/A" if there's no reading.
+	Status string
+}
+
+// Sensors returns the current reading for each sensor in the host's SDR.
+func (c *Client) Sensors(ctx context.Context, host string) ([]*Sensor, error) {
+	var out []*Sensor
+	err := c.do(ctx, host, func(cn *conn) error {
+		sdrs, err := cn.sdr(ctx)
+		if err != nil {
+			return err
+		}
+		out = make([]*Sensor, 0, len(sdrs.sensors))
+		for _, s := range sdrs.sensors {
+			reading, err := cn.read(ctx, s)
+			if err != nil {
+				return err
+			}
+			out = append(out, reading)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
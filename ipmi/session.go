@@ -0,0 +1,330 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bougou/go-ipmi"
+)
+
+// SessionState is the state of our session with a single BMC.
+type SessionState int
+
+const (
+	// SessionDisconnected means we don't have a session, and will open one on
+	// the next request.
+	SessionDisconnected SessionState = iota
+	SessionConnected
+	// SessionBackoff means our last attempt to connect failed, and requests
+	// will fail fast until it's time to try again.
+	SessionBackoff
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case SessionDisconnected:
+		return "disconnected"
+	case SessionConnected:
+		return "connected"
+	case SessionBackoff:
+		return "backoff"
+	default:
+		return "unknown"
+	}
+}
+
+// AllSessionStates is every SessionState, for exporting them as an enum.
+var AllSessionStates = []SessionState{SessionDisconnected, SessionConnected, SessionBackoff}
+
+// ErrBackoff is returned for requests to a host that we recently failed to
+// connect to.
+var ErrBackoff = errors.New("backing off after failed connection")
+
+// ErrClosed is returned for requests made after the Client is closed.
+var ErrClosed = errors.New("IPMI client is closed")
+
+const (
+	// If a session has sat idle for this long, check that it's still alive
+	// before using it. go-ipmi keeps sessions alive in the background, but
+	// BMCs still drop them when they reset.
+	healthCheckInterval = 2 * time.Minute
+	// How long to wait for the BMC to answer a health check.
+	probeTimeout = 5 * time.Second
+	// How long to wait for the BMC to acknowledge closing a session, which is
+	// usually dead by the time we're closing it.
+	closeTimeout = 2 * time.Second
+
+	// After a failed connection, we wait minBackoff before trying again,
+	// doubling with each failure up to maxBackoff.
+	minBackoff = 10 * time.Second
+	maxBackoff = 10 * time.Minute
+)
+
+// conn is our session with a single BMC, along with any per-host state.
+type conn struct {
+	host string
+	// sem is held while using the conn. It's a channel rather than a mutex so
+	// that waiting on it can be canceled.
+	sem chan struct{}
+
+	// These are guarded by sem.
+
+	// ic is nil until we've successfully connected, and after the session has
+	// been evicted.
+	ic       *ipmi.Client
+	lastUsed time.Time
+	sel      *selCursor
+	sdrs     *sdrCache
+
+	// mu guards the stats below, which can be read while someone else holds
+	// sem.
+	mu         sync.Mutex
+	state      SessionState
+	failures   int
+	retryAt    time.Time
+	connects   uint64
+	reconnects uint64
+	evictions  uint64
+	lastErr    error
+	// creds is the name of the credentials the host last accepted, which we
+	// try first when reconnecting.
+	creds string
+}
+
+// SessionStats describes our session with a single BMC.
+type SessionStats struct {
+	Host  string
+	State SessionState
+	// Reconnects is how many times we've opened a new session after the first.
+	Reconnects uint64
+	// Evictions is how many sessions we've dropped after finding them dead.
+	Evictions uint64
+	// ConsecutiveFailures is how many connection attempts have failed since
+	// the last success.
+	ConsecutiveFailures int
+	// RetryAt is when we'll next try to connect, if we're in SessionBackoff.
+	RetryAt time.Time
+	// LastError is why we last failed to connect or dropped a session.
+	LastError string
+	// Credentials is the name of the credentials the host last accepted, or
+	// empty if we've never connected.
+	Credentials string
+}
+
+// SessionStats returns the state of our session with every host we've talked
+// to, sorted by host.
+func (c *Client) SessionStats() []SessionStats {
+	c.mu.Lock()
+	conns := make([]*conn, 0, len(c.conns))
+	for _, cn := range c.conns {
+		conns = append(conns, cn)
+	}
+	c.mu.Unlock()
+
+	out := make([]SessionStats, 0, len(conns))
+	for _, cn := range conns {
+		cn.mu.Lock()
+		s := SessionStats{
+			Host:                cn.host,
+			State:               cn.state,
+			Reconnects:          cn.reconnects,
+			Evictions:           cn.evictions,
+			ConsecutiveFailures: cn.failures,
+			RetryAt:             cn.retryAt,
+			Credentials:         cn.creds,
+		}
+		if cn.lastErr != nil {
+			s.LastError = cn.lastErr.Error()
+		}
+		cn.mu.Unlock()
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+// AcceptedCredentials returns the name of the credentials the given host last
+// accepted, or empty if we've never connected to it.
+func (c *Client) AcceptedCredentials(host string) string {
+	c.mu.Lock()
+	cn, ok := c.conns[host]
+	c.mu.Unlock()
+	if !ok {
+		return ""
+	}
+
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	return cn.creds
+}
+
+// do runs fn with exclusive use of a healthy session with the given host. If
+// fn fails, we check whether the session is still alive, and drop it if it
+// isn't, so the next request gets a fresh one.
+func (c *Client) do(ctx context.Context, host string, fn func(cn *conn) error) error {
+	cn, err := c.lock(ctx, host)
+	if err != nil {
+		return err
+	}
+	defer cn.unlock()
+
+	if err := fn(cn); err != nil {
+		if ctx.Err() != nil {
+			// We gave up waiting, so we don't know what state the session is in,
+			// and we're out of time to check. Start fresh next time.
+			c.evict(cn, fmt.Errorf("request timed out: %w", err))
+		} else if perr := c.probe(cn); perr != nil {
+			c.evict(cn, fmt.Errorf("session failed health check after error %q: %w", err, perr))
+		}
+		return err
+	}
+	cn.lastUsed = c.now()
+	return nil
+}
+
+// lock waits for exclusive use of our session with the given host, connecting
+// if we need to. Callers must call unlock on the returned conn when they're
+// done with it.
+//
+// The context bounds how long we'll wait for other callers to finish with the
+// host, and for the BMC if we need to connect.
+func (c *Client) lock(ctx context.Context, host string) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	cn, ok := c.conns[host]
+	if !ok {
+		cn = &conn{host: host, sem: make(chan struct{}, 1)}
+		c.conns[host] = cn
+	}
+	c.mu.Unlock()
+
+	select {
+	case cn.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed waiting for IPMI session with %q: %w", host, ctx.Err())
+	}
+
+	if cn.ic != nil && c.now().Sub(cn.lastUsed) > healthCheckInterval {
+		if err := c.probe(cn); err != nil {
+			c.evict(cn, fmt.Errorf("idle session failed health check: %w", err))
+		}
+	}
+
+	if cn.ic == nil {
+		// Close may have run while we were waiting, in which case it's
+		// already been through our conn and won't close a new session.
+		if c.isClosed() {
+			cn.unlock()
+			return nil, ErrClosed
+		}
+		if err := c.reconnect(ctx, cn); err != nil {
+			cn.unlock()
+			return nil, err
+		}
+	}
+
+	return cn, nil
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (cn *conn) unlock() {
+	<-cn.sem
+}
+
+// reconnect opens a new session with the conn's host, unless we're still
+// backing off from a previous failure. The caller must hold cn.sem.
+func (c *Client) reconnect(ctx context.Context, cn *conn) error {
+	now := c.now()
+
+	cn.mu.Lock()
+	retryAt, creds := cn.retryAt, cn.creds
+	cn.mu.Unlock()
+	if now.Before(retryAt) {
+		return fmt.Errorf("not connecting to %q until %s: %w", cn.host, retryAt.Format(time.RFC3339), ErrBackoff)
+	}
+
+	ic, creds, err := c.connect(ctx, cn.host, creds)
+
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	if err != nil {
+		cn.failures++
+		cn.retryAt = now.Add(backoff(cn.failures))
+		cn.state = SessionBackoff
+		cn.lastErr = err
+		return err
+	}
+	if cn.connects > 0 {
+		cn.reconnects++
+	}
+	cn.connects++
+	cn.failures = 0
+	cn.retryAt = time.Time{}
+	cn.state = SessionConnected
+	cn.lastErr = nil
+
+	cn.ic = ic
+	cn.lastUsed = now
+	cn.creds = creds
+	return nil
+}
+
+// probe checks that the conn's session is still alive. The caller must hold
+// cn.sem.
+func (c *Client) probe(cn *conn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	if _, err := cn.ic.GetCurrentSessionInfo(ctx); err != nil {
+		return err
+	}
+	cn.lastUsed = c.now()
+	return nil
+}
+
+// evict closes and drops the conn's session. The caller must hold cn.sem.
+func (c *Client) evict(cn *conn, reason error) {
+	log.Printf("dropping IPMI session with %q: %v", cn.host, reason)
+
+	if err := closeSession(cn.ic); err != nil {
+		log.Printf("failed to close IPMI session with %q: %v", cn.host, err)
+	}
+	cn.ic = nil
+
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	cn.evictions++
+	cn.state = SessionDisconnected
+	cn.lastErr = reason
+}
+
+func closeSession(ic *ipmi.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return ic.Close(ctx)
+}
+
+// backoff returns how long to wait before connecting again after the given
+// number of consecutive failures.
+func backoff(failures int) time.Duration {
+	d := minBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,147 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{6, 320 * time.Second},
+		{7, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, test := range tests {
+		if got := backoff(test.failures); got != test.want {
+			t.Errorf("backoff(%d) = %s, want %s", test.failures, got, test.want)
+		}
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	port := closedUDPPort(t)
+
+	c := New("root", "calvin", WithHostConfig("127.0.0.1", Config{Port: port}))
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	checkStats := func(wantState SessionState, wantFailures int) {
+		t.Helper()
+		stats := c.SessionStats()
+		if len(stats) != 1 {
+			t.Fatalf("got stats for %d hosts, want 1", len(stats))
+		}
+		s := stats[0]
+		if s.Host != "127.0.0.1" {
+			t.Errorf("Host = %q, want 127.0.0.1", s.Host)
+		}
+		if s.State != wantState {
+			t.Errorf("State = %s, want %s", s.State, wantState)
+		}
+		if s.ConsecutiveFailures != wantFailures {
+			t.Errorf("ConsecutiveFailures = %d, want %d", s.ConsecutiveFailures, wantFailures)
+		}
+		if s.LastError == "" {
+			t.Error("LastError is empty")
+		}
+	}
+
+	_, err := c.AmbientTemp(ctx, "127.0.0.1")
+	if err == nil || errors.Is(err, ErrBackoff) {
+		t.Fatalf("first AmbientTemp returned %v, want a connection error", err)
+	}
+	checkStats(SessionBackoff, 1)
+
+	// We shouldn't try again until the backoff is up.
+	if _, err := c.AmbientTemp(ctx, "127.0.0.1"); !errors.Is(err, ErrBackoff) {
+		t.Fatalf("AmbientTemp during backoff returned %v, want ErrBackoff", err)
+	}
+	checkStats(SessionBackoff, 1)
+
+	now = now.Add(minBackoff)
+	if _, err := c.AmbientTemp(ctx, "127.0.0.1"); err == nil || errors.Is(err, ErrBackoff) {
+		t.Fatalf("AmbientTemp after backoff returned %v, want a connection error", err)
+	}
+	checkStats(SessionBackoff, 2)
+	if got, want := c.SessionStats()[0].RetryAt, now.Add(2*minBackoff); !got.Equal(want) {
+		t.Errorf("RetryAt = %s, want %s", got, want)
+	}
+}
+
+func TestLockCanceled(t *testing.T) {
+	c := New("root", "calvin")
+
+	// Hold the host's session, so the next caller has to wait.
+	cn := &conn{host: "127.0.0.1", sem: make(chan struct{}, 1)}
+	cn.sem <- struct{}{}
+	c.conns["127.0.0.1"] = cn
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.AmbientTemp(ctx, "127.0.0.1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("AmbientTemp returned %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestCloseWhileInUse(t *testing.T) {
+	c := New("root", "calvin")
+
+	// Hold the host's session, like a caller in the middle of reconnecting.
+	cn := &conn{host: "127.0.0.1", sem: make(chan struct{}, 1)}
+	cn.sem <- struct{}{}
+	c.conns["127.0.0.1"] = cn
+
+	closed := make(chan error)
+	go func() { closed <- c.Close() }()
+
+	// Reconnecting needs the client's credentials, which Close mustn't be
+	// holding up while it waits for us.
+	got := make(chan []Credentials)
+	go func() { got <- c.credentials("127.0.0.1", "") }()
+	select {
+	case <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out looking up credentials while closing")
+	}
+
+	cn.unlock()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Close")
+	}
+
+	// We don't reconnect once we're closed.
+	if _, err := c.AmbientTemp(context.Background(), "127.0.0.1"); !errors.Is(err, ErrClosed) {
+		t.Errorf("AmbientTemp after Close returned %v, want ErrClosed", err)
+	}
+}
+
+// closedUDPPort returns a local UDP port that nothing is listening on.
+func closedUDPPort(t *testing.T) int {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.ListenPacket: %v", err)
+	}
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	if err := pc.Close(); err != nil {
+		t.Fatalf("pc.Close: %v", err)
+	}
+	return port
+}
@@ -0,0 +1,291 @@
+package ipmi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/bougou/go-ipmi"
+)
+
+const (
+	cmdSetSessionPrivilegeLevel = 0x3b
+	cmdCloseSession             = 0x3c
+	cmdActivatePayload          = 0x48
+	cmdDeactivatePayload        = 0x49
+	cmdGetDeviceID              = 0x01
+)
+
+// The completion code for Activate Payload when the payload is already
+// active in another session, see table 24-2 of the IPMI spec.
+const completionPayloadAlreadyActive = 0x80
+
+const (
+	// We only ever use the first SOL instance, which is the only one iDRACs
+	// have.
+	solInstance = 0x01
+	// Encrypt and authenticate the SOL payload, and defer serial alerts while
+	// SOL is active.
+	solActivateAux = 0xc0 | 0x10
+
+	// Sent by the BMC when SOL is being deactivated, e.g. by another session
+	// taking it over.
+	solStatusDeactivating = 0x10
+)
+
+// ErrSOLActive is returned by SOL when another session already has SOL active
+// on the BMC, and we weren't asked to take it over.
+var ErrSOLActive = errors.New("SOL is already active in another session")
+
+// SOLOption configures a call to SOL.
+type SOLOption func(*solConfig)
+
+type solConfig struct {
+	takeover bool
+}
+
+// WithSOLTakeover deactivates SOL in whatever other session has it, rather
+// than failing with ErrSOLActive. The other session is told it's been
+// deactivated, so use this sparingly: it kicks out anyone watching the
+// console with something like ipmitool sol activate.
+func WithSOLTakeover() SOLOption {
+	return func(sc *solConfig) {
+		sc.takeover = true
+	}
+}
+
+// SOLSession is a Serial over LAN session with a host's BMC, which streams
+// the host's serial console. It only supports the lanplus interface.
+//
+// Reads return console output as the BMC sends it. SOL is receive-only:
+// nothing we read is ever written back to the console.
+type SOLSession struct {
+	host string
+	rs   *rmcpSession
+
+	mu sync.Mutex
+	// buf holds console output that hasn't been read yet.
+	buf bytes.Buffer
+	// lastSeq is the sequence number of the last SOL packet we accepted, so
+	// we can ignore retransmissions.
+	lastSeq uint8
+	// eof is set once the BMC says it's deactivating SOL.
+	eof bool
+	// ready is signalled whenever buf or eof changes.
+	ready chan struct{}
+
+	closeOnce sync.Once
+}
+
+// SOL opens a Serial over LAN session with the host, trying each of its
+// credentials in turn like any other session. The SOL session is separate
+// from the one used for everything else, so it doesn't hold up polling, and
+// it must be closed when it's no longer needed.
+//
+// Only one session can have SOL active on a BMC, so if something else (like
+// an ipmitool sol activate) already has it, SOL fails with ErrSOLActive unless
+// it's called WithSOLTakeover.
+func (c *Client) SOL(ctx context.Context, host string, opts ...SOLOption) (*SOLSession, error) {
+	var sc solConfig
+	for _, opt := range opts {
+		opt(&sc)
+	}
+
+	cfg := c.Config(host)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid IPMI config for %q: %w", host, err)
+	}
+	if ipmi.Interface(cfg.Interface) != ipmi.InterfaceLanplus {
+		return nil, fmt.Errorf("SOL needs the lanplus interface, %q is configured for %s", host, cfg.Interface)
+	}
+
+	var errs []error
+	for _, cred := range c.credentials(host, c.AcceptedCredentials(host)) {
+		sess, err := c.openSOL(ctx, host, cfg, cred, sc)
+		if err == nil {
+			return sess, nil
+		}
+		errs = append(errs, fmt.Errorf("with %s credentials: %w", cred.Name, err))
+		if Unreachable(err) || errors.Is(err, ErrSOLActive) {
+			// Neither will other credentials.
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (c *Client) openSOL(ctx context.Context, host string, cfg Config, cred Credentials, sc solConfig) (*SOLSession, error) {
+	log.Printf("opening SOL session to host %q with %s credentials", host, cred.Name)
+
+	rs, err := openRMCPSession(ctx, host, cfg, cred)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open RMCP+ session with %q: %w", host, err)
+	}
+	sess := &SOLSession{host: host, rs: rs, ready: make(chan struct{}, 1)}
+	rs.mu.Lock()
+	rs.onSOL = sess.receive
+	rs.mu.Unlock()
+
+	if err := sess.activate(ctx, cfg.Port, sc.takeover); err != nil {
+		sess.closeSession()
+		return nil, err
+	}
+	return sess, nil
+}
+
+// activate activates the SOL payload. If another session has it, we
+// deactivate it there first if takeover is set, and fail otherwise.
+func (s *SOLSession) activate(ctx context.Context, port int, takeover bool) error {
+	req := []byte{payloadSOL, solInstance, solActivateAux, 0, 0, 0}
+	resp, err := s.rs.command(ctx, netFnApp, cmdActivatePayload, req)
+	var ccErr *completionError
+	if errors.As(err, &ccErr) && ccErr.code == completionPayloadAlreadyActive {
+		if !takeover {
+			return fmt.Errorf("failed to activate SOL on %q: %w", s.host, ErrSOLActive)
+		}
+		log.Printf("SOL is already active on %q, taking it over", s.host)
+		if _, err := s.rs.command(ctx, netFnApp, cmdDeactivatePayload, []byte{payloadSOL, solInstance, 0, 0, 0, 0}); err != nil {
+			return fmt.Errorf("failed to deactivate existing SOL session: %w", err)
+		}
+		resp, err = s.rs.command(ctx, netFnApp, cmdActivatePayload, req)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to activate SOL: %w", err)
+	}
+	if len(resp) < 12 {
+		return fmt.Errorf("failed to activate SOL: response too short (%d bytes)", len(resp))
+	}
+	// The BMC can ask us to send SOL packets to a different port, which
+	// iDRACs don't, so we don't bother supporting it.
+	if p := int(resp[8]) | int(resp[9])<<8; p != port {
+		return fmt.Errorf("BMC wants SOL on port %d, which isn't supported", p)
+	}
+	return nil
+}
+
+// receive handles an SOL packet from the BMC, see section 15.9 of the IPMI
+// spec. It's called from the session's read loop.
+func (s *SOLSession) receive(payload []byte) {
+	if len(payload) < 4 {
+		return
+	}
+	seq, status, data := payload[0]&0x0f, payload[3], payload[4:]
+	if seq == 0 {
+		// Just an ACK for something we sent, which we never do.
+		return
+	}
+
+	s.mu.Lock()
+	if seq != s.lastSeq {
+		s.lastSeq = seq
+		s.buf.Write(data)
+	}
+	if status&solStatusDeactivating != 0 {
+		s.eof = true
+	}
+	s.mu.Unlock()
+	s.notify()
+
+	// ACK everything, including retransmissions, which probably mean our last
+	// ACK got lost. Otherwise the BMC will keep retransmitting until it gives
+	// up on the session.
+	if err := s.rs.send(payloadSOL, []byte{0, seq, byte(len(data)), 0}); err != nil {
+		log.Printf("failed to ACK SOL packet from %q: %v", s.host, err)
+	}
+}
+
+func (s *SOLSession) notify() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Read reads console output, blocking until there is some. It returns io.EOF
+// once the BMC deactivates SOL, and an error if the session is closed or
+// fails.
+func (s *SOLSession) Read(p []byte) (int, error) {
+	for {
+		s.mu.Lock()
+		if s.buf.Len() > 0 {
+			n, _ := s.buf.Read(p)
+			s.mu.Unlock()
+			return n, nil
+		}
+		eof := s.eof
+		s.mu.Unlock()
+		if eof {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-s.rs.done:
+			// Don't lose anything that arrived just before the session ended.
+			s.mu.Lock()
+			n, _ := s.buf.Read(p)
+			s.mu.Unlock()
+			if n > 0 {
+				return n, nil
+			}
+			return 0, fmt.Errorf("SOL session with %q ended: %w", s.host, s.rs.err)
+		case <-s.ready:
+		}
+	}
+}
+
+// Ping checks that the BMC is still answering, by sending it Get Device ID
+// over the session. BMCs forget their sessions when they're reset, and
+// there's nothing else to tell us that's what happened: the console is just
+// quiet.
+func (s *SOLSession) Ping(ctx context.Context) error {
+	if _, err := s.rs.command(ctx, netFnApp, cmdGetDeviceID, nil); err != nil {
+		return fmt.Errorf("SOL session with %q isn't responding: %w", s.host, err)
+	}
+	return nil
+}
+
+// Close deactivates SOL and closes the session. Any blocked Reads return an
+// error.
+func (s *SOLSession) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		s.mu.Lock()
+		eof := s.eof
+		s.mu.Unlock()
+		select {
+		case <-s.rs.done:
+			// The session's already dead, there's no one to tell.
+		default:
+			if eof {
+				// The BMC already deactivated SOL for us, and deactivating it
+				// now would kick out whoever has it instead.
+				break
+			}
+			if _, derr := s.rs.command(ctx, netFnApp, cmdDeactivatePayload, []byte{payloadSOL, solInstance, 0, 0, 0, 0}); derr != nil {
+				err = fmt.Errorf("failed to deactivate SOL: %w", derr)
+			}
+		}
+		s.closeSession()
+	})
+	return err
+}
+
+// closeSession closes the RMCP+ session, ignoring any errors, since there's
+// nothing we can do about them.
+func (s *SOLSession) closeSession() {
+	select {
+	case <-s.rs.done:
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		s.rs.command(ctx, netFnApp, cmdCloseSession, binary.LittleEndian.AppendUint32(nil, s.rs.bmcID))
+	}
+	s.rs.close()
+}
@@ -0,0 +1,168 @@
+package ipmi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+)
+
+func TestSOL(t *testing.T) {
+	for _, suite := range []int{0, 3, 17} {
+		bmc := ipmisim.New(t, "root", "calvin")
+		c := newSimClient(bmc, "root", "calvin", WithDefaults(Config{CipherSuiteID: suite}))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		sess, err := c.SOL(ctx, bmc.Host)
+		if err != nil {
+			t.Fatalf("cipher suite %d: SOL: %v", suite, err)
+		}
+		if !bmc.SOLActive() {
+			t.Fatalf("cipher suite %d: SOL wasn't activated", suite)
+		}
+
+		// More than fits in one SOL packet.
+		want := make([]byte, 500)
+		for i := range want {
+			want[i] = 'a' + byte(i%26)
+		}
+		if !bmc.WriteConsole(want) {
+			t.Fatalf("cipher suite %d: WriteConsole found no SOL session", suite)
+		}
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(sess, got); err != nil {
+			t.Fatalf("cipher suite %d: ReadFull: %v", suite, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("cipher suite %d: console output = %q, want %q", suite, got, want)
+		}
+		waitFor(t, func() bool { return bmc.ConsoleAcks() == 3 })
+
+		if err := sess.Ping(ctx); err != nil {
+			t.Errorf("cipher suite %d: Ping: %v", suite, err)
+		}
+		if err := sess.Close(); err != nil {
+			t.Errorf("cipher suite %d: Close: %v", suite, err)
+		}
+		if bmc.SOLActive() || bmc.ActiveSessions() != 0 {
+			t.Errorf("cipher suite %d: SOL still active after Close", suite)
+		}
+		if _, err := sess.Read(got); err == nil {
+			t.Errorf("cipher suite %d: Read after Close succeeded", suite)
+		}
+	}
+}
+
+func TestSOLTakeover(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	first, err := c.SOL(ctx, bmc.Host)
+	if err != nil {
+		t.Fatalf("SOL: %v", err)
+	}
+	defer first.Close()
+	if _, err := c.SOL(ctx, bmc.Host); !errors.Is(err, ErrSOLActive) {
+		t.Fatalf("SOL while already active = %v, want ErrSOLActive", err)
+	}
+	if bmc.ActiveSessions() != 1 {
+		t.Errorf("BMC has %d sessions after failing to activate SOL, want 1", bmc.ActiveSessions())
+	}
+
+	second, err := c.SOL(ctx, bmc.Host, WithSOLTakeover())
+	if err != nil {
+		t.Fatalf("SOL while already active: %v", err)
+	}
+	defer second.Close()
+
+	// The first session should be told SOL was deactivated.
+	if _, err := first.Read(make([]byte, 10)); err != io.EOF {
+		t.Errorf("Read on the session that was taken over = %v, want io.EOF", err)
+	}
+	// Closing it shouldn't deactivate SOL for the session that has it now.
+	if err := first.Close(); err != nil {
+		t.Errorf("Close on the session that was taken over: %v", err)
+	}
+
+	bmc.WriteConsole([]byte("hello"))
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(second, got); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("console output = %q, want %q", got, "hello")
+	}
+}
+
+func TestSOLBMCReset(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sess, err := c.SOL(ctx, bmc.Host)
+	if err != nil {
+		t.Fatalf("SOL: %v", err)
+	}
+	defer sess.Close()
+
+	bmc.Reset()
+	err = sess.Ping(ctx)
+	var netErr interface{ Timeout() bool }
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Ping after reset = %v, want a timeout", err)
+	}
+
+	// Closing should unblock reads.
+	errc := make(chan error, 1)
+	go func() {
+		_, err := sess.Read(make([]byte, 10))
+		errc <- err
+	}()
+	sess.Close()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("Read on closed session succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read didn't return after Close")
+	}
+
+	// And we can open a new one.
+	sess, err = c.SOL(ctx, bmc.Host)
+	if err != nil {
+		t.Fatalf("SOL after reset: %v", err)
+	}
+	sess.Close()
+}
+
+func TestSOLWrongPassword(t *testing.T) {
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(bmc, "root", "wrong", WithFallbackCredentials(Credentials{Name: "dell-default", User: "root", Password: "calvin"}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sess, err := c.SOL(ctx, bmc.Host)
+	if err != nil {
+		t.Fatalf("SOL: %v", err)
+	}
+	sess.Close()
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
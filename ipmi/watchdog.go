@@ -0,0 +1,91 @@
+package ipmi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bougou/go-ipmi"
+)
+
+// Watchdog is the state of a host's BMC watchdog timer, which the host's OS
+// (or BIOS) has to keep resetting, or the BMC takes Action.
+type Watchdog struct {
+	// Running is whether the timer is counting down. If it isn't, the
+	// watchdog won't do anything, no matter what Action says.
+	Running bool
+	// Use is what the timer was last set up for, one of "bios-frb2",
+	// "bios-post", "os-load", "sms-os" (i.e. a watchdog daemon on the host) or
+	// "oem", or "" if it's never been set up.
+	Use string
+	// Action is what the BMC does when the timer expires, one of "none",
+	// "hard-reset", "power-down" or "power-cycle".
+	Action string
+	// Initial is what the timer counts down from, and Remaining is how long
+	// it has left.
+	Initial   time.Duration
+	Remaining time.Duration
+	// Expired lists the uses (see Use) that the timer has expired for since
+	// the flags were last cleared, which is usually when the host's OS sets up
+	// the watchdog again.
+	Expired []string
+}
+
+var timerUses = map[ipmi.TimerUse]string{
+	ipmi.TimerUseBIOSFRB2: "bios-frb2",
+	ipmi.TimerUseBIOSPOST: "bios-post",
+	ipmi.TimerUseOSLoad:   "os-load",
+	ipmi.TimerUseSMSOS:    "sms-os",
+	ipmi.TimerUseOEM:      "oem",
+}
+
+// TimerUses are the possible values of Watchdog.Use, in order.
+var TimerUses = []string{"bios-frb2", "bios-post", "os-load", "sms-os", "oem"}
+
+var timeoutActions = map[ipmi.TimeoutAction]string{
+	ipmi.TimeoutActionNoAction:   "none",
+	ipmi.TimeoutActionHardReset:  "hard-reset",
+	ipmi.TimeoutActionPowerDown:  "power-down",
+	ipmi.TimeoutActionPowerCycle: "power-cycle",
+}
+
+// Watchdog countdowns are in tenths of a second.
+const watchdogTick = 100 * time.Millisecond
+
+// Watchdog reads the state of the host's BMC watchdog timer, with Get
+// Watchdog Timer.
+func (c *Client) Watchdog(ctx context.Context, host string) (*Watchdog, error) {
+	var res *ipmi.GetWatchdogTimerResponse
+	err := c.do(ctx, host, func(cn *conn) error {
+		var err error
+		if res, err = cn.ic.GetWatchdogTimer(ctx); err != nil {
+			return fmt.Errorf("failed to get watchdog timer: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromIPMIWatchdog(res), nil
+}
+
+func fromIPMIWatchdog(res *ipmi.GetWatchdogTimerResponse) *Watchdog {
+	action, ok := timeoutActions[res.TimeoutAction]
+	if !ok {
+		action = fmt.Sprintf("unknown-%#02x", uint8(res.TimeoutAction))
+	}
+	wd := &Watchdog{
+		Running:   res.TimerIsStarted,
+		Use:       timerUses[res.TimerUse],
+		Action:    action,
+		Initial:   time.Duration(res.InitialCountdown) * watchdogTick,
+		Remaining: time.Duration(res.PresentCountdown) * watchdogTick,
+	}
+	// Bit N of the expiration flags is for timer use N.
+	for i, use := range TimerUses {
+		if res.ExpirationFlags&(1<<(i+1)) != 0 {
+			wd.Expired = append(wd.Expired, use)
+		}
+	}
+	return wd
+}
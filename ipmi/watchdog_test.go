@@ -0,0 +1,51 @@
+package ipmi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/ipmi/ipmisim"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWatchdog(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   ipmisim.Watchdog
+		want *Watchdog
+	}{
+		{
+			desc: "never set up",
+			want: &Watchdog{Action: "none"},
+		},
+		{
+			desc: "armed by a watchdog daemon",
+			in:   ipmisim.Watchdog{Running: true, Use: 0x04, Action: 0x01, InitialCountdown: 3000, PresentCountdown: 2875},
+			want: &Watchdog{Running: true, Use: "sms-os", Action: "hard-reset", Initial: 5 * time.Minute, Remaining: 287500 * time.Millisecond},
+		},
+		{
+			desc: "fired, and reset the host during POST",
+			in:   ipmisim.Watchdog{Use: 0x04, Action: 0x03, ExpirationFlags: 0x10 | 0x04, InitialCountdown: 600},
+			want: &Watchdog{Use: "sms-os", Action: "power-cycle", Initial: time.Minute, Expired: []string{"bios-post", "sms-os"}},
+		},
+	}
+
+	bmc := ipmisim.New(t, "root", "calvin")
+	c := newSimClient(bmc, "root", "calvin")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			bmc.SetWatchdog(test.in)
+			got, err := c.Watchdog(ctx, bmc.Host)
+			if err != nil {
+				t.Fatalf("Watchdog: %v", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("unexpected watchdog (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package racadm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// WithCacheTTL caches responses to the given racadm subcommand (e.g.
+// "getsysinfo") for the given duration. Responses are cached per full command,
+// so e.g. "getniccfg" results are cached separately for each slot. Concurrent
+// calls for the same command share a single request to the CMC.
+//
+// Cached responses are shared between callers, and must not be modified.
+func WithCacheTTL(subcommand string, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cache.ttls[subcommand] = ttl
+	}
+}
+
+// Invalidate drops any cached responses for the given racadm subcommand.
+func (c *Client) Invalidate(subcommand string) {
+	c.cache.invalidate(func(cmd string) bool {
+		return subcommandOf(cmd) == subcommand
+	})
+}
+
+// InvalidateAll drops all cached responses.
+func (c *Client) InvalidateAll() {
+	c.cache.invalidate(func(string) bool { return true })
+}
+
+type cache struct {
+	ttls  map[string]time.Duration
+	group singleflight.Group
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// gen is bumped on every invalidation, so that requests that were already
+	// in flight don't put stale responses back in the cache.
+	gen uint64
+}
+
+type cacheEntry struct {
+	val     any
+	expires time.Time
+}
+
+func newCache() *cache {
+	return &cache{
+		ttls:    make(map[string]time.Duration),
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// get returns the cached response for cmd if there is one, and otherwise calls
+// fetch to load it.
+func (ca *cache) get(cmd string, fetch func() (any, error)) (any, error) {
+	ttl, ok := ca.ttls[subcommandOf(cmd)]
+	if !ok || ttl <= 0 {
+		return fetch()
+	}
+
+	ca.mu.Lock()
+	if e, ok := ca.entries[cmd]; ok && ca.now().Before(e.expires) {
+		ca.mu.Unlock()
+		return e.val, nil
+	}
+	ca.mu.Unlock()
+
+	v, err, _ := ca.group.Do(cmd, func() (any, error) {
+		ca.mu.Lock()
+		gen := ca.gen
+		ca.mu.Unlock()
+
+		v, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		ca.mu.Lock()
+		defer ca.mu.Unlock()
+		if ca.gen == gen {
+			ca.entries[cmd] = cacheEntry{val: v, expires: ca.now().Add(ttl)}
+		}
+		return v, nil
+	})
+	return v, err
+}
+
+func (ca *cache) invalidate(match func(cmd string) bool) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	ca.gen++
+	for cmd := range ca.entries {
+		if match(cmd) {
+			delete(ca.entries, cmd)
+		}
+	}
+}
+
+// runParse runs cmd and parses its output with parse, going through the
+// response cache.
+func runParse[T any](c *Client, cmd string, parse func(io.Reader) (T, error)) (T, error) {
+	v, err := c.cache.get(cmd, func() (any, error) {
+		var resp T
+		err := c.runCommand(cmd, func(r io.Reader) error {
+			var err error
+			if resp, err = parse(r); err != nil {
+				return fmt.Errorf("failed to parse output: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// subcommandOf returns the racadm subcommand of a command like
+// "racadm <subcommand> [args]".
+func subcommandOf(cmd string) string {
+	fs := strings.Fields(cmd)
+	if len(fs) < 2 {
+		return ""
+	}
+	return fs[1]
+}
@@ -0,0 +1,115 @@
+package racadm
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	f := newFakeCMC(t)
+	c := f.dial(t, WithCacheTTL("getniccfg", time.Minute))
+
+	now := time.Now()
+	c.cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetNICConfig(1); err != nil {
+			t.Fatalf("GetNICConfig: %v", err)
+		}
+		if _, err := c.GetSysInfo(); err != nil {
+			t.Fatalf("GetSysInfo: %v", err)
+		}
+	}
+	if n := f.execCount("racadm getniccfg -m server-1"); n != 1 {
+		t.Errorf("getniccfg ran %d times, want 1", n)
+	}
+	if n := f.execCount("racadm getsysinfo"); n != 3 {
+		t.Errorf("uncached getsysinfo ran %d times, want 3", n)
+	}
+
+	// Once the TTL is up, we should go back to the CMC.
+	now = now.Add(2 * time.Minute)
+	if _, err := c.GetNICConfig(1); err != nil {
+		t.Fatalf("GetNICConfig: %v", err)
+	}
+	if n := f.execCount("racadm getniccfg -m server-1"); n != 2 {
+		t.Errorf("getniccfg ran %d times after expiry, want 2", n)
+	}
+
+	c.Invalidate("getniccfg")
+	if _, err := c.GetNICConfig(1); err != nil {
+		t.Fatalf("GetNICConfig: %v", err)
+	}
+	if n := f.execCount("racadm getniccfg -m server-1"); n != 3 {
+		t.Errorf("getniccfg ran %d times after invalidation, want 3", n)
+	}
+}
+
+func TestCacheErrorsNotCached(t *testing.T) {
+	f := newFakeCMC(t)
+	c := f.dial(t, WithCacheTTL("getniccfg", time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetNICConfig(2); err == nil {
+			t.Fatal("GetNICConfig for unknown slot succeeded, want an error")
+		}
+	}
+	if n := f.execCount("racadm getniccfg -m server-2"); n != 2 {
+		t.Errorf("getniccfg ran %d times, want 2", n)
+	}
+}
+
+func TestCacheCollapsesConcurrentRequests(t *testing.T) {
+	f := newFakeCMC(t)
+	f.setDelay("racadm getsysinfo", 100*time.Millisecond)
+	c := f.dial(t, WithCacheTTL("getsysinfo", time.Minute))
+
+	var wg sync.WaitGroup
+	results := make([]*GetSysInfo, 10)
+	for i := range results {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := c.GetSysInfo()
+			if err != nil {
+				t.Errorf("GetSysInfo: %v", err)
+				return
+			}
+			results[i] = info
+		}()
+	}
+	wg.Wait()
+
+	if n := f.execCount("racadm getsysinfo"); n != 1 {
+		t.Errorf("getsysinfo ran %d times, want 1", n)
+	}
+	for i, info := range results {
+		if info != results[0] {
+			t.Errorf("result %d wasn't the shared response", i)
+		}
+	}
+}
+
+func TestInvalidateAll(t *testing.T) {
+	f := newFakeCMC(t)
+	c := f.dial(t, WithCacheTTL("getsysinfo", time.Minute), WithCacheTTL("getpbinfo", time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetSysInfo(); err != nil {
+			t.Fatalf("GetSysInfo: %v", err)
+		}
+		if _, err := c.GetPowerBudgetInfo(); err != nil {
+			t.Fatalf("GetPowerBudgetInfo: %v", err)
+		}
+		c.InvalidateAll()
+	}
+
+	if n := f.execCount("racadm getsysinfo"); n != 2 {
+		t.Errorf("getsysinfo ran %d times, want 2", n)
+	}
+	if n := f.execCount("racadm getpbinfo"); n != 2 {
+		t.Errorf("getpbinfo ran %d times, want 2", n)
+	}
+}
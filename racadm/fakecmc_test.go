@@ -0,0 +1,226 @@
+package racadm
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	fakeCMCUser = "root"
+	fakeCMCPass = "calvin"
+)
+
+// fakeCMC is a minimal SSH server that answers racadm commands with canned
+// output, both as one-off exec requests and in an interactive shell.
+type fakeCMC struct {
+	addr string
+
+	mu       sync.Mutex
+	outputs  map[string]string
+	delays   map[string]time.Duration
+	sessions int
+	maxSeen  int
+	execs    map[string]int
+}
+
+func newFakeCMC(tb testing.TB) *fakeCMC {
+	tb.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		tb.Fatalf("ed25519.GenerateKey: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		tb.Fatalf("ssh.NewSignerFromKey: %v", err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(md ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if md.User() != fakeCMCUser || string(pass) != fakeCMCPass {
+				return nil, errors.New("bad credentials")
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("net.Listen: %v", err)
+	}
+	tb.Cleanup(func() { l.Close() })
+
+	f := &fakeCMC{
+		addr: l.Addr().String(),
+		outputs: map[string]string{
+			"racadm getsensorinfo":         savedOutput(tb, "getsensorinfo", "cmc-6.21"),
+			"racadm getsysinfo":            savedOutput(tb, "getsysinfo", "cmc-6.21"),
+			"racadm getpbinfo":             savedOutput(tb, "getpbinfo", "cmc-6.21"),
+			"racadm getioinfo":             savedOutput(tb, "getioinfo", "cmc-6.21"),
+			"racadm getniccfg -m server-1": savedOutput(tb, "getniccfg", "cmc-6.21"),
+		},
+		delays: make(map[string]time.Duration),
+		execs:  make(map[string]int),
+	}
+	go f.serve(l, cfg)
+	return f
+}
+
+func (f *fakeCMC) dial(tb testing.TB, opts ...Option) *Client {
+	tb.Helper()
+	c, err := Dial(fakeCMCUser, fakeCMCPass, f.addr, opts...)
+	if err != nil {
+		tb.Fatalf("Dial: %v", err)
+	}
+	tb.Cleanup(func() { c.Close() })
+	return c
+}
+
+// setDelay makes the fake CMC wait before answering the given command.
+func (f *fakeCMC) setDelay(cmd string, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delays[cmd] = d
+}
+
+// maxSessions returns the most sessions that were ever open at once.
+func (f *fakeCMC) maxSessions() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.maxSeen
+}
+
+// execCount returns how many times the given command was run.
+func (f *fakeCMC) execCount(cmd string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.execs[cmd]
+}
+
+func (f *fakeCMC) serve(l net.Listener, cfg *ssh.ServerConfig) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go f.handleConn(conn, cfg)
+	}
+}
+
+func (f *fakeCMC) handleConn(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go f.handleSession(ch, chReqs)
+	}
+}
+
+func (f *fakeCMC) handleSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	f.mu.Lock()
+	f.sessions++
+	if f.sessions > f.maxSeen {
+		f.maxSeen = f.sessions
+	}
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.sessions--
+		f.mu.Unlock()
+		ch.Close()
+		go ssh.DiscardRequests(reqs)
+	}()
+
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+			status := f.exec(ch, payload.Command)
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		case "shell":
+			req.Reply(true, nil)
+			f.shell(ch)
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func (f *fakeCMC) exec(w io.Writer, cmd string) uint32 {
+	f.mu.Lock()
+	out, ok := f.outputs[cmd]
+	delay := f.delays[cmd]
+	f.execs[cmd]++
+	f.mu.Unlock()
+
+	time.Sleep(delay)
+	if !ok {
+		fmt.Fprintf(w, "ERROR: Invalid subcommand specified.\n")
+		return 1
+	}
+	io.WriteString(w, out)
+	return 0
+}
+
+// shell emulates the CMC's interactive shell, including the echo and CRLF line
+// endings a PTY adds.
+func (f *fakeCMC) shell(ch ssh.Channel) {
+	io.WriteString(ch, "Welcome to the CMC firmware version 6.21.A00.201912111232\r\n\r\n$ ")
+
+	sc := bufio.NewScanner(ch)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		io.WriteString(ch, line+"\r\n")
+		if line == "exit" {
+			return
+		}
+		if line != "" {
+			f.exec(crlfWriter{ch}, line)
+		}
+		io.WriteString(ch, "$ ")
+	}
+}
+
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(c.w, strings.ReplaceAll(string(p), "\n", "\r\n")); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
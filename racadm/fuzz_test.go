@@ -0,0 +1,158 @@
+package racadm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Each parser gets a fuzz target, seeded with everything in
+// testdata/outputs/<subcommand>. On top of not panicking, the parsers must
+// return either a complete result or an error, never a half-parsed result with
+// a nil error.
+
+func FuzzParseGetSensorInfo(f *testing.F) {
+	addSeeds(f, "getsensorinfo")
+	f.Fuzz(func(t *testing.T, in []byte) {
+		got, err := ParseGetSensorInfo(bytes.NewReader(in))
+		if !checkResult(t, got, err) {
+			return
+		}
+		if len(got.Fans) == 0 || len(got.AmbientTemp) == 0 {
+			t.Errorf("got %d fans and %d temps with no error", len(got.Fans), len(got.AmbientTemp))
+		}
+		for _, ss := range [][]*Sensor{got.Fans, got.AmbientTemp} {
+			for _, s := range ss {
+				if s == nil {
+					t.Error("got nil sensor")
+				}
+			}
+		}
+		for _, p := range got.PowerSupplies {
+			if p == nil {
+				t.Error("got nil power supply")
+			}
+		}
+		for _, c := range got.Cables {
+			if c == nil {
+				t.Error("got nil cable")
+			}
+		}
+	})
+}
+
+func FuzzParseGetSysInfo(f *testing.F) {
+	addSeeds(f, "getsysinfo")
+	f.Fuzz(func(t *testing.T, in []byte) {
+		got, err := ParseGetSysInfo(bytes.NewReader(in))
+		if !checkResult(t, got, err) {
+			return
+		}
+		if got.ChassisName == "" {
+			t.Error("got no chassis name with no error")
+		}
+	})
+}
+
+func FuzzParseGetPowerBudgetInfo(f *testing.F) {
+	addSeeds(f, "getpbinfo")
+	f.Fuzz(func(t *testing.T, in []byte) {
+		got, err := ParseGetPowerBudgetInfo(bytes.NewReader(in))
+		if !checkResult(t, got, err) {
+			return
+		}
+		if len(got.ServerPowerInfo) == 0 {
+			t.Error("got no servers with no error")
+		}
+		for _, s := range got.ServerPowerInfo {
+			if s == nil {
+				t.Error("got nil server")
+			}
+		}
+	})
+}
+
+func FuzzParseGetNICConfig(f *testing.F) {
+	addSeeds(f, "getniccfg")
+	f.Fuzz(func(t *testing.T, in []byte) {
+		got, err := ParseGetNICConfig(bytes.NewReader(in))
+		if !checkResult(t, got, err) {
+			return
+		}
+		if got.IPAddress == nil {
+			t.Error("got no IP address with no error")
+		}
+	})
+}
+
+func FuzzParseGetIOInfo(f *testing.F) {
+	addSeeds(f, "getioinfo")
+	f.Fuzz(func(t *testing.T, in []byte) {
+		got, err := ParseGetIOInfo(bytes.NewReader(in))
+		if !checkResult(t, got, err) {
+			return
+		}
+		if len(got.IOModules) == 0 {
+			t.Error("got no I/O modules with no error")
+		}
+		for _, m := range got.IOModules {
+			if m == nil {
+				t.Error("got nil I/O module")
+			}
+		}
+	})
+}
+
+func FuzzParseIDRACSensorInfo(f *testing.F) {
+	addSeeds(f, "getsensorinfo")
+	f.Fuzz(func(t *testing.T, in []byte) {
+		got, err := ParseIDRACSensorInfo(bytes.NewReader(in))
+		if !checkResult(t, got, err) {
+			return
+		}
+		if len(got.Sensors) == 0 {
+			t.Error("got no sensors with no error")
+		}
+		for _, s := range got.Sensors {
+			if s == nil {
+				t.Error("got nil sensor")
+			}
+		}
+	})
+}
+
+// addSeeds adds all the saved outputs for the given subcommand to the seed
+// corpus.
+func addSeeds(f *testing.F, subcommand string) {
+	f.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "outputs", subcommand, "*.txt"))
+	if err != nil {
+		f.Fatalf("filepath.Glob: %v", err)
+	}
+	if len(paths) == 0 {
+		f.Fatalf("no saved outputs for %q", subcommand)
+	}
+	for _, p := range paths {
+		dat, err := os.ReadFile(p)
+		if err != nil {
+			f.Fatalf("os.ReadFile: %v", err)
+		}
+		f.Add(dat)
+	}
+}
+
+// checkResult checks that a parser returned exactly one of a result or an
+// error, and reports whether there's a result to check further.
+func checkResult[T any](t *testing.T, got *T, err error) bool {
+	t.Helper()
+	switch {
+	case err != nil && got != nil:
+		t.Errorf("got a result alongside error %v", err)
+		return false
+	case err == nil && got == nil:
+		t.Error("got neither a result nor an error")
+		return false
+	}
+	return err == nil
+}
@@ -0,0 +1,131 @@
+package racadm
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IDRACSensorInfo is the output of `racadm getsensorinfo` on a blade's iDRAC,
+// which looks nothing like the CMC's.
+type IDRACSensorInfo struct {
+	Sensors []*IDRACSensor
+}
+
+type IDRACSensor struct {
+	// Type is the section the sensor is listed under, e.g. "TEMPERATURE",
+	// "FAN", "VOLTAGE" or "CURRENT".
+	Type   string
+	Name   string
+	Status string
+	// Reading is the reading as printed, e.g. "23C", "5880RPM", "126Watts" or
+	// "Good". It's empty for sections without a reading column, like POWER.
+	Reading string
+	// Value and Units are Reading split into a number and what comes after
+	// it, e.g. 23 and "C", if HasValue is true.
+	Value    float64
+	Units    string
+	HasValue bool
+}
+
+// GetIDRACSensorInfo runs `racadm getsensorinfo` on a client that's connected
+// to a blade's iDRAC, rather than the CMC.
+func (c *Client) GetIDRACSensorInfo() (*IDRACSensorInfo, error) {
+	return runParse(c, "racadm getsensorinfo", ParseIDRACSensorInfo)
+}
+
+var (
+	idracSectionPrefix = "Sensor Type :"
+	// Column headers look like "<Sensor Name>    <Status>    <Reading>".
+	idracHeaderRE = regexp.MustCompile(`<([^>]*)>`)
+	// Columns are padded with spaces, but names and some readings (e.g.
+	// "Presence Detected", "3C [N]") have single spaces in them.
+	spacedColumnRE = regexp.MustCompile(`\s{2,}`)
+	idracReadingRE = regexp.MustCompile(`^(-?[0-9]+(?:\.[0-9]+)?)\s*([A-Za-z%]*)$`)
+)
+
+// ParseIDRACSensorInfo parses the output of `racadm getsensorinfo` from an
+// iDRAC.
+func ParseIDRACSensorInfo(r io.Reader) (*IDRACSensorInfo, error) {
+	var out IDRACSensorInfo
+
+	section := ""
+	// readingCol is the index of the <Reading> column in the current section,
+	// or -1 if it doesn't have one.
+	readingCol := -1
+	err := parseOutput(r, parseConfig{
+		splitFn: func(in string) (string, []string, error) {
+			txt := strings.TrimSpace(in)
+			if txt == "" {
+				return "", nil, errSkip
+			}
+
+			if strings.HasPrefix(txt, idracSectionPrefix) {
+				section = strings.TrimSpace(strings.TrimPrefix(txt, idracSectionPrefix))
+				readingCol = -1
+				return "", nil, errSkip
+			}
+
+			if strings.HasPrefix(txt, "<") {
+				for i, m := range idracHeaderRE.FindAllStringSubmatch(txt, -1) {
+					if m[1] == "Reading" {
+						readingCol = i
+					}
+				}
+				return "", nil, errSkip
+			}
+
+			if section == "" {
+				return "", nil, fmt.Errorf("sensor %q isn't under a %q line", txt, idracSectionPrefix)
+			}
+			cols := spacedColumnRE.Split(txt, -1)
+			if len(cols) < 2 {
+				return "", nil, fmt.Errorf("expected at least two columns, got %d", len(cols))
+			}
+			reading := ""
+			if readingCol >= 0 && readingCol < len(cols) {
+				reading = cols[readingCol]
+			}
+			return "sensor", []string{section, cols[0], cols[1], reading}, nil
+		},
+		extractors: map[string]extract{
+			"sensor": allowMultiple(extract{
+				fn: func(vals []string) error {
+					s, err := parseIDRACSensor(vals)
+					if err != nil {
+						return err
+					}
+					out.Sensors = append(out.Sensors, s)
+					return nil
+				},
+			}),
+		},
+		required: []string{"sensor"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse output: %w", err)
+	}
+	return &out, nil
+}
+
+func parseIDRACSensor(vals []string) (*IDRACSensor, error) {
+	if len(vals) != 4 {
+		return nil, fmt.Errorf("unexpected number of values %d, wanted 4", len(vals))
+	}
+	s := &IDRACSensor{
+		Type:    vals[0],
+		Name:    vals[1],
+		Status:  vals[2],
+		Reading: vals[3],
+	}
+	if m := idracReadingRE.FindStringSubmatch(s.Reading); m != nil {
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse reading %q: %w", s.Reading, err)
+		}
+		s.Value, s.Units, s.HasValue = v, m[2], true
+	}
+	return s, nil
+}
@@ -0,0 +1,73 @@
+package racadm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GetIOInfo is the output of `racadm getioinfo`, which lists the chassis' I/O
+// modules (switches and pass-throughs).
+type GetIOInfo struct {
+	IOModules []*IOModule
+}
+
+type IOModule struct {
+	// Slot is the I/O module bay, e.g. "switch-1" for fabric A1.
+	Slot string
+	// Name, Type, POST, Power and Role are "N/A" (and Type is "None") for empty
+	// bays.
+	Name    string
+	Type    string
+	Present bool
+	POST    string
+	Power   string
+	// Role is the module's role in its stack, e.g. "Master" or "Member".
+	Role string
+}
+
+func (c *Client) GetIOInfo() (*GetIOInfo, error) {
+	return runParse(c, "racadm getioinfo", ParseGetIOInfo)
+}
+
+// ParseGetIOInfo parses the output of `racadm getioinfo`.
+func ParseGetIOInfo(r io.Reader) (*GetIOInfo, error) {
+	var out GetIOInfo
+	err := parseOutput(r, parseConfig{
+		splitFn: func(in string) (string, []string, error) {
+			txt := strings.TrimSpace(in)
+			// Skip blank lines and the header row.
+			if txt == "" || strings.HasPrefix(txt, "<") {
+				return "", nil, errSkip
+			}
+			// Like the iDRAC's sensor names, module names and types have single
+			// spaces in them, so columns are split on runs of spaces.
+			cols := spacedColumnRE.Split(txt, -1)
+			if len(cols) != 7 {
+				return "", nil, fmt.Errorf("got %d, expected 7 fields", len(cols))
+			}
+			return "modules", cols, nil
+		},
+		extractors: map[string]extract{
+			"modules": allowMultiple(extract{
+				fn: func(vals []string) error {
+					out.IOModules = append(out.IOModules, &IOModule{
+						Slot:    vals[0],
+						Name:    vals[1],
+						Type:    vals[2],
+						Present: vals[3] == "Present",
+						POST:    vals[4],
+						Power:   vals[5],
+						Role:    vals[6],
+					})
+					return nil
+				},
+			}),
+		},
+		required: []string{"modules"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse output: %w", err)
+	}
+	return &out, nil
+}
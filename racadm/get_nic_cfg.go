@@ -1,6 +1,7 @@
 package racadm
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -24,23 +25,25 @@ type GetNICConfig struct {
 	VLANpriority int    // 0
 }
 
-func (c *Client) GetNICConfig(slotNum int) (*GetNICConfig, error) {
-	var resp *GetNICConfig
-	err := c.runCommand(fmt.Sprintf("racadm getniccfg -m server-%d", slotNum), func(r io.Reader) error {
-		var err error
-		if resp, err = parseGetNICConfig(r); err != nil {
-			return fmt.Errorf("failed to parse output: %w", err)
-		}
-		return nil
+// MarshalJSON renders the subnet mask like an IP, rather than base64-encoded
+// bytes.
+func (g *GetNICConfig) MarshalJSON() ([]byte, error) {
+	type alias GetNICConfig
+	return json.Marshal(struct {
+		*alias
+		SubnetMask string
+	}{
+		alias:      (*alias)(g),
+		SubnetMask: maskString(g.SubnetMask),
 	})
-	if err != nil {
-		return nil, err
-	}
+}
 
-	return resp, nil
+func (c *Client) GetNICConfig(slotNum int) (*GetNICConfig, error) {
+	return runParse(c, fmt.Sprintf("racadm getniccfg -m server-%d", slotNum), ParseGetNICConfig)
 }
 
-func parseGetNICConfig(r io.Reader) (*GetNICConfig, error) {
+// ParseGetNICConfig parses the output of `racadm getniccfg -m server-<n>`.
+func ParseGetNICConfig(r io.Reader) (*GetNICConfig, error) {
 	var out GetNICConfig
 	err := parseOutput(r, parseConfig{
 		splitFn: func(in string) (string, []string, error) {
@@ -72,9 +75,10 @@ func parseGetNICConfig(r io.Reader) (*GetNICConfig, error) {
 			"VLAN ID":       setInt(&out.VLANID),
 			"VLAN priority": setInt(&out.VLANpriority),
 		},
+		required: []string{"IP Address"},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse output: %w", err)
 	}
 	return &out, nil
-}
\ No newline at end of file
+}
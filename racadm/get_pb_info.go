@@ -22,19 +22,7 @@ type ServerPowerInfo struct {
 }
 
 func (c *Client) GetPowerBudgetInfo() (*GetPowerBudgetInfo, error) {
-	var resp *GetPowerBudgetInfo
-	err := c.runCommand("racadm getpbinfo", func(r io.Reader) error {
-		var err error
-		if resp, err = parseGetPowerBudgetInfo(r); err != nil {
-			return fmt.Errorf("failed to parse output: %w", err)
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	return resp, nil
+	return runParse(c, "racadm getpbinfo", ParseGetPowerBudgetInfo)
 }
 
 // pbBlock indicates what block of output text we're parsing
@@ -47,7 +35,8 @@ const (
 	pbBlockServerPower
 )
 
-func parseGetPowerBudgetInfo(r io.Reader) (*GetPowerBudgetInfo, error) {
+// ParseGetPowerBudgetInfo parses the output of `racadm getpbinfo`.
+func ParseGetPowerBudgetInfo(r io.Reader) (*GetPowerBudgetInfo, error) {
 	var out GetPowerBudgetInfo
 
 	currentBlock := pbBlockNone
@@ -101,6 +90,7 @@ func parseGetPowerBudgetInfo(r io.Reader) (*GetPowerBudgetInfo, error) {
 				allowMultiple: true,
 			},
 		},
+		required: []string{"servers"},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse output: %w", err)
@@ -36,19 +36,7 @@ type CableInfo struct {
 }
 
 func (c *Client) GetSensorInfo() (*GetSensorInfo, error) {
-	var resp *GetSensorInfo
-	err := c.runCommand("racadm getsensorinfo", func(r io.Reader) error {
-		var err error
-		if resp, err = parseGetSensorInfo(r); err != nil {
-			return fmt.Errorf("failed to parse output: %w", err)
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	return resp, nil
+	return runParse(c, "racadm getsensorinfo", ParseGetSensorInfo)
 }
 
 func parseSensor(vals []string) (*Sensor, error) {
@@ -106,7 +94,8 @@ func parseCable(vals []string) (*CableInfo, error) {
 	}, nil
 }
 
-func parseGetSensorInfo(r io.Reader) (*GetSensorInfo, error) {
+// ParseGetSensorInfo parses the output of `racadm getsensorinfo`.
+func ParseGetSensorInfo(r io.Reader) (*GetSensorInfo, error) {
 	var out GetSensorInfo
 	err := parseOutput(r, parseConfig{
 		splitFn: func(in string) (string, []string, error) {
@@ -169,6 +158,7 @@ func parseGetSensorInfo(r io.Reader) (*GetSensorInfo, error) {
 				allowMultiple: true,
 			},
 		},
+		required: []string{"FanSpeed", "Temp"},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse output: %w", err)
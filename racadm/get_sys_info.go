@@ -1,6 +1,7 @@
 package racadm
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -53,23 +54,27 @@ type GetSysInfo struct {
 	SystemID               string
 }
 
-func (c *Client) GetSysInfo() (*GetSysInfo, error) {
-	var resp *GetSysInfo
-	err := c.runCommand("racadm getsysinfo", func(r io.Reader) error {
-		var err error
-		if resp, err = parseGetSysInfo(r); err != nil {
-			return fmt.Errorf("failed to parse output: %w", err)
-		}
-		return nil
+// MarshalJSON renders the netmask like an IP and the MAC address in the usual
+// colon-separated form, rather than base64-encoded bytes.
+func (g *GetSysInfo) MarshalJSON() ([]byte, error) {
+	type alias GetSysInfo
+	return json.Marshal(struct {
+		*alias
+		MACAddress       string
+		CurrentIPNetmask string
+	}{
+		alias:            (*alias)(g),
+		MACAddress:       g.MACAddress.String(),
+		CurrentIPNetmask: maskString(g.CurrentIPNetmask),
 	})
-	if err != nil {
-		return nil, err
-	}
+}
 
-	return resp, nil
+func (c *Client) GetSysInfo() (*GetSysInfo, error) {
+	return runParse(c, "racadm getsysinfo", ParseGetSysInfo)
 }
 
-func parseGetSysInfo(r io.Reader) (*GetSysInfo, error) {
+// ParseGetSysInfo parses the output of `racadm getsysinfo`.
+func ParseGetSysInfo(r io.Reader) (*GetSysInfo, error) {
 	var out GetSysInfo
 	err := parseOutput(r, parseConfig{
 		splitFn: func(in string) (string, []string, error) {
@@ -129,6 +134,7 @@ func parseGetSysInfo(r io.Reader) (*GetSysInfo, error) {
 			"Power Status":             setString(&out.PowerStatus),
 			"System ID":                setString(&out.SystemID),
 		},
+		required: []string{"Chassis Name"},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse output: %w", err)
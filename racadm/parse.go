@@ -0,0 +1,60 @@
+package racadm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrUnknownOutput is returned by DetectSubcommand when the output doesn't look
+// like anything we know how to parse.
+var ErrUnknownOutput = errors.New("unrecognized racadm output")
+
+// Subcommands we know how to parse, along with a line that only shows up in
+// their output. Checked in order. Output from a blade's iDRAC is prefixed with
+// "idrac-", since the same subcommands print something else there.
+var outputMarkers = []struct {
+	subcommand string
+	marker     string
+}{
+	{subcommand: "getsensorinfo", marker: "<senType>"},
+	{subcommand: "getpbinfo", marker: "[Server Module Power Allocation Table]"},
+	{subcommand: "getsysinfo", marker: "CMC Date/Time"},
+	{subcommand: "getniccfg", marker: "LOM Model Name"},
+	{subcommand: "getioinfo", marker: "<IO>"},
+	{subcommand: "idrac-getsensorinfo", marker: "Sensor Type :"},
+}
+
+// DetectSubcommand guesses which racadm subcommand (e.g. "getsysinfo") produced
+// the given output.
+func DetectSubcommand(out []byte) (string, error) {
+	for _, m := range outputMarkers {
+		if bytes.Contains(out, []byte(m.marker)) {
+			return m.subcommand, nil
+		}
+	}
+	return "", ErrUnknownOutput
+}
+
+// Parse parses saved output of the given racadm subcommand, returning the same
+// type as the corresponding Client method, e.g. *GetSysInfo for "getsysinfo",
+// or *IDRACSensorInfo for "idrac-getsensorinfo".
+func Parse(subcommand string, r io.Reader) (any, error) {
+	switch subcommand {
+	case "getsensorinfo":
+		return ParseGetSensorInfo(r)
+	case "getpbinfo":
+		return ParseGetPowerBudgetInfo(r)
+	case "getsysinfo":
+		return ParseGetSysInfo(r)
+	case "getniccfg":
+		return ParseGetNICConfig(r)
+	case "getioinfo":
+		return ParseGetIOInfo(r)
+	case "idrac-getsensorinfo":
+		return ParseIDRACSensorInfo(r)
+	default:
+		return nil, fmt.Errorf("no parser for subcommand %q", subcommand)
+	}
+}
@@ -0,0 +1,85 @@
+package racadm
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDetectSubcommand(t *testing.T) {
+	for _, subcommand := range []string{"getsensorinfo", "getpbinfo", "getsysinfo", "getniccfg", "getioinfo"} {
+		out := savedOutput(t, subcommand, "cmc-6.21")
+		got, err := DetectSubcommand([]byte(out))
+		if err != nil {
+			t.Errorf("DetectSubcommand(%s output): %v", subcommand, err)
+			continue
+		}
+		if got != subcommand {
+			t.Errorf("DetectSubcommand(%s output) = %q", subcommand, got)
+		}
+		if _, err := Parse(got, strings.NewReader(out)); err != nil {
+			t.Errorf("Parse(%q): %v", got, err)
+		}
+	}
+}
+
+func TestDetectIDRACSubcommand(t *testing.T) {
+	out := savedOutput(t, "getsensorinfo", "idrac-2.75")
+	got, err := DetectSubcommand([]byte(out))
+	if err != nil {
+		t.Fatalf("DetectSubcommand(iDRAC getsensorinfo output): %v", err)
+	}
+	if got != "idrac-getsensorinfo" {
+		t.Errorf("DetectSubcommand(iDRAC getsensorinfo output) = %q, want %q", got, "idrac-getsensorinfo")
+	}
+	if _, err := Parse(got, strings.NewReader(out)); err != nil {
+		t.Errorf("Parse(%q): %v", got, err)
+	}
+}
+
+func TestDetectSubcommandUnknown(t *testing.T) {
+	_, err := DetectSubcommand([]byte("ERROR: Invalid subcommand specified.\n"))
+	if !errors.Is(err, ErrUnknownOutput) {
+		t.Errorf("DetectSubcommand returned %v, want ErrUnknownOutput", err)
+	}
+}
+
+func TestJSONAddresses(t *testing.T) {
+	nicCfg, err := ParseGetNICConfig(strings.NewReader(savedOutput(t, "getniccfg", "cmc-6.21")))
+	if err != nil {
+		t.Fatalf("ParseGetNICConfig: %v", err)
+	}
+	sysInfo, err := ParseGetSysInfo(strings.NewReader(savedOutput(t, "getsysinfo", "cmc-6.21")))
+	if err != nil {
+		t.Fatalf("ParseGetSysInfo: %v", err)
+	}
+
+	var gotNIC struct{ IPAddress, SubnetMask string }
+	roundTrip(t, nicCfg, &gotNIC)
+	if gotNIC.IPAddress != "192.168.2.16" || gotNIC.SubnetMask != "255.255.255.0" {
+		t.Errorf("got NIC config %+v, want IP 192.168.2.16, mask 255.255.255.0", gotNIC)
+	}
+
+	var gotSys struct{ ChassisName, MACAddress, CurrentIPNetmask string }
+	roundTrip(t, sysInfo, &gotSys)
+	want := struct{ ChassisName, MACAddress, CurrentIPNetmask string }{
+		ChassisName:      "CMC-ABCDEFG",
+		MACAddress:       "00:11:22:33:44:55",
+		CurrentIPNetmask: "255.255.255.0",
+	}
+	if gotSys != want {
+		t.Errorf("got sys info %+v, want %+v", gotSys, want)
+	}
+}
+
+func roundTrip(t *testing.T, in, out any) {
+	t.Helper()
+	dat, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if err := json.Unmarshal(dat, out); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"regexp"
 	"strconv"
 	"sync"
 	"time"
@@ -29,14 +30,91 @@ type Client struct {
 	pass string
 	addr string
 
-	done chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 
 	mu     sync.RWMutex
 	client *ssh.Client
+
+	maxSessions int
+	priorities  map[string]Priority
+	sched       *scheduler
+
+	cache *cache
+
+	// dialTimeout limits how long connecting takes, if it's non-zero.
+	dialTimeout time.Duration
+
+	// Only used when useShell is set, see WithShellSession.
+	useShell     bool
+	shellPrompt  *regexp.Regexp
+	shellTimeout time.Duration
+	shellMu      sync.Mutex
+	shell        *shell
+}
+
+// Option configures optional behavior of a Client.
+type Option func(*Client)
+
+// WithShellSession makes the client keep a single long-lived interactive
+// session open on the CMC and write every command to it, instead of opening a
+// new SSH session per command. The CMC only allows a handful of concurrent
+// sessions, so this leaves room for humans to log in.
+func WithShellSession() Option {
+	return func(c *Client) {
+		c.useShell = true
+	}
+}
+
+// WithShellPrompt overrides the regular expression used to detect the shell
+// prompt in shell session mode. It is matched against the last, unterminated
+// line of output. The default matches the CMC's "$ " prompt.
+func WithShellPrompt(re *regexp.Regexp) Option {
+	return func(c *Client) {
+		c.shellPrompt = re
+	}
+}
+
+// WithShellTimeout sets how long to wait for the prompt to come back after
+// writing a command in shell session mode.
+func WithShellTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.shellTimeout = d
+	}
 }
 
-func Dial(user, pass, addr string) (*Client, error) {
-	c := &Client{user: user, pass: pass, addr: addr, done: make(chan struct{})}
+// WithDialTimeout limits how long connecting (and reconnecting) to the host
+// can take, including the SSH handshake. By default there's no limit.
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.dialTimeout = d
+	}
+}
+
+func Dial(user, pass, addr string, opts ...Option) (*Client, error) {
+	c := &Client{
+		user:         user,
+		pass:         pass,
+		addr:         addr,
+		done:         make(chan struct{}),
+		maxSessions:  defaultMaxSessions,
+		priorities:   make(map[string]Priority),
+		cache:        newCache(),
+		shellPrompt:  defaultShellPrompt,
+		shellTimeout: defaultShellTimeout,
+	}
+	for k, v := range defaultPriorities {
+		c.priorities[k] = v
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.useShell {
+		// There's only the one session, but going through the scheduler still
+		// gets commands run in priority order.
+		c.maxSessions = 1
+	}
+	c.sched = newScheduler(c.maxSessions)
 	if err := c.connect(); err != nil {
 		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
@@ -52,6 +130,9 @@ func (c *Client) refreshSession() {
 		select {
 		case <-t.C:
 			c.mu.Lock()
+			// Nobody can be using the shell while we hold the write lock, see
+			// runShellCommand.
+			c.closeShell()
 			if err := c.client.Close(); err != nil {
 				log.Printf("failed to close old client while refreshing: %v", err)
 			}
@@ -65,14 +146,31 @@ func (c *Client) refreshSession() {
 	}
 }
 
+// Close closes the connection to the CMC. Closing it again does nothing.
 func (c *Client) Close() error {
-	return c.client.Close()
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.closeShell()
+		err = c.client.Close()
+	})
+	return err
 }
 
 func (c *Client) runCommand(cmd string, fn func(r io.Reader) error) error {
+	c.sched.acquire(c.priority(cmd))
+	defer c.sched.release()
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	if c.useShell {
+		return c.runShellCommand(cmd, fn)
+	}
+
 	sess, err := c.client.NewSession()
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
@@ -99,7 +197,7 @@ func (c *Client) runCommand(cmd string, fn func(r io.Reader) error) error {
 }
 
 func (c *Client) connect() error {
-	client, err := ssh.Dial("tcp", c.addr, &ssh.ClientConfig{
+	cfg := &ssh.ClientConfig{
 		User: c.user,
 		Auth: []ssh.AuthMethod{
 			ssh.Password(c.pass),
@@ -108,11 +206,36 @@ func (c *Client) connect() error {
 			// TODO: Consider allowing enforcing the key that comes back here.
 			return nil
 		},
-	})
+	}
+	if c.dialTimeout == 0 {
+		client, err := ssh.Dial("tcp", c.addr, cfg)
+		if err != nil {
+			return fmt.Errorf("failed to connect to SSH: %w", err)
+		}
+		c.client = client
+		return nil
+	}
+
+	// ssh.ClientConfig.Timeout only covers the TCP connection, not the
+	// handshake, which is where a wedged host hangs.
+	conn, err := net.DialTimeout("tcp", c.addr, c.dialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SSH: %w", err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(c.dialTimeout)); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set handshake deadline: %w", err)
+	}
+	sc, chans, reqs, err := ssh.NewClientConn(conn, c.addr, cfg)
 	if err != nil {
+		conn.Close()
 		return fmt.Errorf("failed to connect to SSH: %w", err)
 	}
-	c.client = client
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		sc.Close()
+		return fmt.Errorf("failed to clear handshake deadline: %w", err)
+	}
+	c.client = ssh.NewClient(sc, chans, reqs)
 	return nil
 }
 
@@ -120,6 +243,11 @@ type parseConfig struct {
 	splitFn func(string) (string, []string, error)
 
 	extractors map[string]extract
+
+	// required lists keys that must show up in the output at least once. If
+	// they don't, the output was likely truncated or garbled, and we return an
+	// error rather than a half-filled result.
+	required []string
 }
 
 type extractFn func(vals []string) error
@@ -140,6 +268,23 @@ func singleValueExtract(fn func(in string) error) extract {
 	}
 }
 
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func allowMultiple(ex extract) extract {
+	ex.allowMultiple = true
+	return ex
+}
+
 func setString(v *string) extract {
 	return singleValueExtract(func(in string) error {
 		*v = in
@@ -216,12 +361,22 @@ func setIPMask(v *net.IPMask) extract {
 	})
 }
 
+// maskString formats masks parsed by setIPMask the same way they showed up in
+// the output, e.g. "255.255.255.0".
+func maskString(m net.IPMask) string {
+	if m == nil {
+		return ""
+	}
+	return net.IP(m).String()
+}
+
 var errSkip = errors.New("skip")
 
 func parseOutput(r io.Reader, cfg parseConfig) error {
 	sc := bufio.NewScanner(r)
 
-	seen := make(map[string]bool)
+	// seen holds the values of each key we've extracted.
+	seen := make(map[string][]string)
 	for sc.Scan() {
 		key, vals, err := cfg.splitFn(sc.Text())
 		if errors.Is(err, errSkip) {
@@ -233,13 +388,28 @@ func parseOutput(r io.Reader, cfg parseConfig) error {
 		if !ok {
 			continue
 		}
-		if seen[key] && !ex.allowMultiple {
-			return fmt.Errorf("key %q occurred at least twice", key)
+		if prev, ok := seen[key]; ok && !ex.allowMultiple {
+			// Some firmware versions print the same line twice, which is
+			// harmless. Two different values means we can't tell which is right.
+			if !sameValues(prev, vals) {
+				return fmt.Errorf("key %q occurred twice, with values %v and %v", key, prev, vals)
+			}
+			continue
 		}
 		if err := ex.fn(vals); err != nil {
 			return fmt.Errorf("failed to extract value(s) from %v for key %q: %w", vals, key, err)
 		}
+		seen[key] = vals
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read output: %w", err)
+	}
+
+	for _, key := range cfg.required {
+		if _, ok := seen[key]; !ok {
+			return fmt.Errorf("missing required key %q", key)
+		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -2,6 +2,8 @@ package racadm
 
 import (
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -10,37 +12,11 @@ import (
 )
 
 func TestParseGetSensorInfo(t *testing.T) {
-	in := strings.NewReader(`
-<senType>       <Num>   <sensorName>    <status>        <reading>       <units>         <LC>    <UC>
-FanSpeed        1       Fan-1           OK              1000            rpm             1000    14500
-FanSpeed        2       Fan-2           OK              2000            rpm             1000    14500
-FanSpeed        3       Fan-3           OK              3000            rpm             2000    14500
-FanSpeed        4       Fan-4           OK              4000            rpm             1000    14500
-FanSpeed        5       Fan-5           OK              5000            rpm             1000    14500
-FanSpeed        6       Fan-6           OK              6000            rpm             2000    14500
-FanSpeed        7       Fan-7           OK              7000            rpm             2000    9835
-FanSpeed        8       Fan-8           OK              8000            rpm             1000    14500
-FanSpeed        9       Fan-9           OK              9000            rpm             2000    14500
-
-<senType>       <Num>   <sensorName>    <status>        <reading>       <units>         <LC>    <UC>
-Temp            1       Ambient_Temp    OK              20              Celsius         N/A     40
-
-<senType>       <Num>   <sensorName>    <status>        <health>
-PWR             1       PS-1            Online          OK
-PWR             2       PS-2            Online          OK
-PWR             3       PS-3            Online          OK
-PWR             4       PS-4            Online          OK
-PWR             5       PS-5            Online          OK
-PWR             6       PS-6            Online          OK
-
-<senType>       <Num>   <sensorName>    <status>
-Cable           1       IO-Cable        OK
-Cable           2       FPC-Cable       OK
-`)
-
-	got, err := parseGetSensorInfo(in)
+	in := strings.NewReader(savedOutput(t, "getsensorinfo", "cmc-6.21"))
+
+	got, err := ParseGetSensorInfo(in)
 	if err != nil {
-		t.Fatalf("parseGetSensorInfo: %v", err)
+		t.Fatalf("ParseGetSensorInfo: %v", err)
 	}
 
 	want := &GetSensorInfo{
@@ -84,59 +60,11 @@ Cable           2       FPC-Cable       OK
 }
 
 func TestParseGetSysInfo(t *testing.T) {
-	in := strings.NewReader(`CMC Information:
-CMC Date/Time             = Tue Jan 04 2000 08:51
-Primary CMC Location      = CMC-1
-Primary CMC Version       = 6.21
-Standby CMC Version       = 6.21
-Last Firmware Update      = Fri Dec 31 1999 18:31
-Hardware Version          = A00
-
-CMC Network Information:
-NIC Enabled               = 1
-MAC Address               = 00:11:22:33:44:55
-Register DNS CMC Name     = 1
-DNS CMC Name              = cmc-ABCDEFG
-Current DNS Domain        =
-VLAN ID                   = 1
-VLAN Priority             = 2
-VLAN Priority             = 2
-VLAN Enabled              = 1
-
-CMC IPv4 Information:
-IPv4 Enabled              = 1
-Current IP Address        = 192.168.1.2
-Current IP Gateway        = 192.168.1.1
-Current IP Netmask        = 255.255.255.0
-DHCP Enabled              = 1
-Current DNS Server 1      = 0.0.0.0
-Current DNS Server 2      = 0.0.0.0
-DNS Servers from DHCP     = 1
-
-CMC IPv6 Information:
-IPv6 Enabled              = 1
-Autoconfiguration Enabled = 1
-Link Local Address        = ::
-Current IPv6 Address 1    = ::
-Current IPv6 Gateway      = ::
-Current IPv6 DNS Server 1 = ::
-Current IPv6 DNS Server 2 = ::
-DNS Servers from DHCPv6   = 1
-
-Chassis Information:
-System Model              = PowerEdge M1000e
-System AssetTag           = 00000
-Service Tag               = ABCDEFG
-Chassis Name              = CMC-ABCDEFG
-Chassis Location          = [UNDEFINED]
-Chassis Midplane Version  = 1.0
-Power Status              = ON
-System ID                 = 1234
-`)
-
-	got, err := parseGetSysInfo(in)
+	in := strings.NewReader(savedOutput(t, "getsysinfo", "cmc-6.21"))
+
+	got, err := ParseGetSysInfo(in)
 	if err != nil {
-		t.Fatalf("parseGetSysInfo: %v", err)
+		t.Fatalf("ParseGetSysInfo: %v", err)
 	}
 
 	want := &GetSysInfo{
@@ -186,66 +114,11 @@ System ID                 = 1234
 }
 
 func TestParseGetPowerBudgetInfo(t *testing.T) {
-	in := strings.NewReader(`
-
-[Power Budget Status]
-System Input Power                              = 2345 W
-Peak System Power                               = 3456 W
-Peak System Power Timestamp                     = 23:05:06 01/04/2000
-Minimum System Power                            = 1000 W
-Minimum System Power Timestamp                  = 18:02:55 12/31/1999
-Overall Power Health                            = OK
-Redundancy                                      = Yes
-System Input Power Cap                          = 16786 W
-Redundancy Policy                               = None
-Dynamic PSU Engagement Enabled                  = Yes
-System Input Max Power Capacity                 = 15678 W
-Input Redundancy Reserve                        = 0 W
-Input Power Allocated to Servers                = 100 W
-Input Power Allocated to Chassis Infrastructure = 678 W
-Total Input Power Available for Allocation      = 12456 W
-Standby Input Power Capacity                    = 0 W
-Server Based Power Management Mode              = Yes
-Max Power Conservation Mode                     = Yes
-Server Performance Over Power Redundancy        = Yes
-Power Available for Server Power-on             = 15432 W
-Extended Power Performance(EPP) Status          = Disabled
-Available Power in EPP Pool                     = 0 W (0 BTU/h)
-Used Power in EPP Pool                          = 0 W (0 BTU/h)
-EPP Percent - Available                         = 0.0
-
-[Chassis Power Supply Status Table]
-<Name>          <Model>         <Power State>          <Input Current> <Input Volts>   <Output Rated Power>
-PS1             111111          Online                 1.3 A                  239.1 V                2360 W
-PS2             222222          Online                 0.2 A                  238.2 V                2360 W
-PS3             333333          Online                 0.3 A                  240.3 V                2360 W
-PS4             444444          Online                 1.3 A                  238.4 V                2360 W
-PS5             555555          Online                 1.5 A                  241.5 V                2360 W
-PS6             666666          Online                 1.3 A                  239.6 V                2360 W
-
-[Server Module Power Allocation Table]
-<Slot#> <Server Name>  <Power State>   <Allocation>    <Priority>  <Blade Type>
-1       SLOT-01         OFF             0 W             1           PowerEdgeM610
-2       SLOT-02         OFF             0 W             1           PowerEdgeM610
-3       SLOT-03         OFF             0 W             1           PowerEdgeM610
-4       SLOT-04         OFF             0 W             1           PowerEdgeM610
-5       SLOT-05         OFF             0 W             1           PowerEdgeM610
-6       SLOT-06         OFF             0 W             1           PowerEdgeM610
-7       SLOT-07         OFF             0 W             1           PowerEdgeM610
-8       SLOT-08         OFF             0 W             1           PowerEdgeM610
-9       SLOT-09         OFF             0 W             1           PowerEdgeM610
-10      SLOT-10         OFF             0 W             1           PowerEdgeM610
-11      SLOT-11         OFF             0 W             1           PowerEdgeM610
-12      SLOT-12         OFF             0 W             1           PowerEdgeM610
-13      SLOT-13         OFF             0 W             1           PowerEdgeM610
-14      SLOT-14         ON              323 W           1           PowerEdgeM610
-15      SLOT-15         ON              323 W           1           PowerEdgeM610
-16      SLOT-16         ON              316 W           1           PowerEdgeM610
-`)
-
-	got, err := parseGetPowerBudgetInfo(in)
+	in := strings.NewReader(savedOutput(t, "getpbinfo", "cmc-6.21"))
+
+	got, err := ParseGetPowerBudgetInfo(in)
 	if err != nil {
-		t.Fatalf("parseGetPowerBudgetInfo: %v", err)
+		t.Fatalf("ParseGetPowerBudgetInfo: %v", err)
 	}
 
 	want := &GetPowerBudgetInfo{
@@ -274,24 +147,37 @@ PS6             666666          Online                 1.3 A                  23
 	}
 }
 
+func TestParseGetIOInfo(t *testing.T) {
+	in := strings.NewReader(savedOutput(t, "getioinfo", "cmc-6.21"))
+
+	got, err := ParseGetIOInfo(in)
+	if err != nil {
+		t.Fatalf("ParseGetIOInfo: %v", err)
+	}
+
+	empty := func(slot string) *IOModule {
+		return &IOModule{Slot: slot, Name: "N/A", Type: "None", POST: "N/A", Power: "N/A", Role: "N/A"}
+	}
+	want := &GetIOInfo{
+		IOModules: []*IOModule{
+			{Slot: "switch-1", Name: "Dell M8024-k 10GbE SW", Type: "10 GbE KR", Present: true, POST: "OK", Power: "ON", Role: "Master"},
+			{Slot: "switch-2", Name: "Dell M8024-k 10GbE SW", Type: "10 GbE KR", Present: true, POST: "OK", Power: "ON", Role: "Member"},
+			{Slot: "switch-3", Name: "Dell Ethernet Pass-through", Type: "Gigabit Ethernet", Present: true, POST: "OK", Power: "OFF", Role: "N/A"},
+			empty("switch-4"),
+			empty("switch-5"),
+			empty("switch-6"),
+		},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected GetIOInfo output (-want +got)\n%s", diff)
+	}
+}
+
 func TestParseGetNICInfo(t *testing.T) {
-	in := strings.NewReader(`LOM Model Name            = Embedded LOM
-LOM Fabric Type           = Gigabit Ethernet
-IPv4 Enabled              = 1
-DHCP Enabled              = 1
-IP Address                = 192.168.2.16
-Subnet Mask               = 255.255.255.0
-Gateway                   = 192.168.2.1
-IPv6 Enabled              = 1
-Autoconfiguration Enabled = 1
-Link local Address        =
-IPv6 Gateway              = ::
-VLAN Enable               = 1
-VLAN ID                   = 2
-VLAN priority             = 3
-`)
-
-	got, err := parseGetNICConfig(in)
+	in := strings.NewReader(savedOutput(t, "getniccfg", "cmc-6.21"))
+
+	got, err := ParseGetNICConfig(in)
 	if err != nil {
 		t.Fatalf("parseGetNICConfg: %v", err)
 	}
@@ -318,6 +204,121 @@ VLAN priority             = 3
 	}
 }
 
+func TestParseRepeatedKeys(t *testing.T) {
+	// The CMC 6.21 output prints VLAN Priority twice, with the same value.
+	if _, err := ParseGetSysInfo(strings.NewReader(savedOutput(t, "getsysinfo", "cmc-6.21"))); err != nil {
+		t.Errorf("ParseGetSysInfo with a repeated line: %v", err)
+	}
+
+	// But if the values differ, we don't know which one to believe.
+	in := strings.Replace(savedOutput(t, "getniccfg", "cmc-6.21"), "Gateway                   = 192.168.2.1\n", "Gateway                   = 192.168.2.1\nGateway                   = 192.168.2.254\n", 1)
+	if _, err := ParseGetNICConfig(strings.NewReader(in)); err == nil {
+		t.Error("ParseGetNICConfig with conflicting gateways succeeded, want an error")
+	}
+}
+
+func TestParseIDRACSensorInfo(t *testing.T) {
+	in := strings.NewReader(savedOutput(t, "getsensorinfo", "idrac-2.75"))
+
+	got, err := ParseIDRACSensorInfo(in)
+	if err != nil {
+		t.Fatalf("ParseIDRACSensorInfo: %v", err)
+	}
+
+	want := &IDRACSensorInfo{
+		Sensors: []*IDRACSensor{
+			{Type: "POWER", Name: "System Board PS Redundancy", Status: "Full Redundant"},
+			{Type: "TEMPERATURE", Name: "System Board Inlet Temp", Status: "Ok", Reading: "23C", Value: 23, Units: "C", HasValue: true},
+			{Type: "TEMPERATURE", Name: "CPU1 Temp", Status: "Ok", Reading: "47C", Value: 47, Units: "C", HasValue: true},
+			{Type: "TEMPERATURE", Name: "CPU2 Temp", Status: "Ok", Reading: "44C", Value: 44, Units: "C", HasValue: true},
+			{Type: "VOLTAGE", Name: "CPU1 VCORE PG", Status: "Ok", Reading: "Good"},
+			{Type: "VOLTAGE", Name: "System Board 3.3V PG", Status: "Ok", Reading: "Good"},
+			{Type: "VOLTAGE", Name: "System Board DIMM PG", Status: "Ok", Reading: "Good"},
+			{Type: "CURRENT", Name: "System Board Pwr Consumption", Status: "Ok", Reading: "126Watts", Value: 126, Units: "Watts", HasValue: true},
+			{Type: "PROCESSOR", Name: "CPU1 Status", Status: "Ok"},
+			{Type: "PROCESSOR", Name: "CPU2 Status", Status: "Ok"},
+			{Type: "MEMORY", Name: "DIMM A1", Status: "Ok"},
+			{Type: "MEMORY", Name: "DIMM B1", Status: "Ok"},
+			{Type: "BATTERY", Name: "System Board CMOS Battery", Status: "Ok", Reading: "Present"},
+			{Type: "PERFORMANCE", Name: "System Board Power Optimized", Status: "Ok", Reading: "Not Degraded"},
+			{Type: "SYSTEM PERFORMANCE", Name: "System Board CPU Usage", Status: "Ok", Reading: "2%", Value: 2, Units: "%", HasValue: true},
+			{Type: "SYSTEM PERFORMANCE", Name: "System Board IO Usage", Status: "Ok", Reading: "0%", Value: 0, Units: "%", HasValue: true},
+		},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected IDRACSensorInfo output (-want +got)\n%s", diff)
+	}
+
+	// Older iDRACs put a space between the number and the units.
+	got, err = ParseIDRACSensorInfo(strings.NewReader("Sensor Type : TEMPERATURE\n<Sensor Name>                 <Status>   <Reading>   <lc>   <uc>\nSystem Board Ambient Temp     Ok         24 C        3 C    42 C\n"))
+	if err != nil {
+		t.Fatalf("ParseIDRACSensorInfo: %v", err)
+	}
+	wantSensor := &IDRACSensor{Type: "TEMPERATURE", Name: "System Board Ambient Temp", Status: "Ok", Reading: "24 C", Value: 24, Units: "C", HasValue: true}
+	if diff := cmp.Diff([]*IDRACSensor{wantSensor}, got.Sensors); diff != "" {
+		t.Errorf("unexpected sensors (-want +got)\n%s", diff)
+	}
+
+	// The CMC's output isn't the iDRAC's.
+	if _, err := ParseIDRACSensorInfo(strings.NewReader(savedOutput(t, "getsensorinfo", "cmc-6.21"))); err == nil {
+		t.Error("ParseIDRACSensorInfo parsed the CMC's getsensorinfo output")
+	}
+}
+
+func TestGetIDRACSensorInfo(t *testing.T) {
+	idrac := newFakeCMC(t)
+	idrac.mu.Lock()
+	idrac.outputs["racadm getsensorinfo"] = savedOutput(t, "getsensorinfo", "idrac-2.75")
+	idrac.mu.Unlock()
+	c := idrac.dial(t)
+
+	got, err := c.GetIDRACSensorInfo()
+	if err != nil {
+		t.Fatalf("GetIDRACSensorInfo: %v", err)
+	}
+	if n := len(got.Sensors); n != 16 {
+		t.Errorf("got %d sensors, want 16", n)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	// Accepts connections, but never says anything.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	start := time.Now()
+	if _, err := Dial(fakeCMCUser, fakeCMCPass, l.Addr().String(), WithDialTimeout(100*time.Millisecond)); err == nil {
+		t.Fatal("Dial succeeded against a host that doesn't speak SSH")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Dial took %s to time out", elapsed)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	c := newFakeCMC(t).dial(t)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// This used to panic, closing the done channel again.
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
 func parseMAC(t *testing.T, in string) net.HardwareAddr {
 	t.Helper()
 	hw, err := net.ParseMAC(in)
@@ -341,3 +342,14 @@ func parseIPMask(t *testing.T, in string) net.IPMask {
 	ip := parseIP(t, in)
 	return net.IPMask(ip)
 }
+
+// savedOutput returns saved output of a racadm subcommand from
+// testdata/outputs/<subcommand>/<name>.txt.
+func savedOutput(tb testing.TB, subcommand, name string) string {
+	tb.Helper()
+	dat, err := os.ReadFile(filepath.Join("testdata", "outputs", subcommand, name+".txt"))
+	if err != nil {
+		tb.Fatalf("failed to read saved output: %v", err)
+	}
+	return string(dat)
+}
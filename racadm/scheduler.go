@@ -0,0 +1,217 @@
+package racadm
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Priority determines which queued command gets the next free session when the
+// client is at its session limit. Lower values go first.
+type Priority int
+
+const (
+	// PriorityCritical is for commands that alerting depends on.
+	PriorityCritical Priority = iota
+	PriorityNormal
+	// PriorityBulk is for slow inventory commands that nobody is waiting on.
+	PriorityBulk
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PriorityNormal:
+		return "normal"
+	case PriorityBulk:
+		return "bulk"
+	default:
+		return "unknown"
+	}
+}
+
+// The CMC refuses new sessions past a small limit, and humans need to be able
+// to log in too, so by default we only use a couple at a time.
+const defaultMaxSessions = 2
+
+// Keyed by racadm subcommand, anything not listed here is PriorityNormal.
+var defaultPriorities = map[string]Priority{
+	"getsensorinfo": PriorityCritical,
+	"getpbinfo":     PriorityNormal,
+	"getsysinfo":    PriorityNormal,
+	"getniccfg":     PriorityBulk,
+	"getioinfo":     PriorityBulk,
+	"getversion":    PriorityBulk,
+}
+
+// WithMaxSessions limits how many sessions the client will have open on the
+// CMC at once. Commands past the limit are queued by priority. It has no
+// effect in shell session mode, which only ever uses one session.
+func WithMaxSessions(n int) Option {
+	return func(c *Client) {
+		c.maxSessions = n
+	}
+}
+
+// WithPriority sets the priority for a racadm subcommand, e.g. "getsysinfo".
+func WithPriority(subcommand string, p Priority) Option {
+	return func(c *Client) {
+		c.priorities[subcommand] = p
+	}
+}
+
+// SchedulerStats is a snapshot of how commands are queueing up for sessions.
+type SchedulerStats struct {
+	MaxSessions    int
+	ActiveSessions int
+	ByPriority     map[Priority]PriorityStats
+}
+
+// PriorityStats contains stats for commands of a single priority.
+type PriorityStats struct {
+	// Queued is how many commands are currently waiting for a session.
+	Queued int
+	// Started is the total number of commands that have gotten a session.
+	Started uint64
+	// TotalWait and MaxWait are how long commands waited for a session.
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// SchedulerStats returns the current state of the client's command queue.
+func (c *Client) SchedulerStats() SchedulerStats {
+	return c.sched.stats()
+}
+
+func (c *Client) priority(cmd string) Priority {
+	if p, ok := c.priorities[subcommandOf(cmd)]; ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+// scheduler hands out up to max session slots, queueing everyone else by
+// priority, then by arrival order.
+type scheduler struct {
+	mu     sync.Mutex
+	max    int
+	active int
+	queue  waitQueue
+	seq    uint64
+
+	byPriority map[Priority]*PriorityStats
+}
+
+func newScheduler(max int) *scheduler {
+	if max < 1 {
+		max = 1
+	}
+	return &scheduler{
+		max:        max,
+		byPriority: make(map[Priority]*PriorityStats),
+	}
+}
+
+// acquire blocks until a session slot is available. Callers must call release
+// when they're done with it.
+func (s *scheduler) acquire(p Priority) {
+	start := time.Now()
+
+	s.mu.Lock()
+	if s.active < s.max && s.queue.Len() == 0 {
+		s.active++
+		s.recordStart(p, 0)
+		s.mu.Unlock()
+		return
+	}
+	w := &waiter{priority: p, seq: s.seq, ready: make(chan struct{})}
+	s.seq++
+	heap.Push(&s.queue, w)
+	s.statsFor(p).Queued++
+	s.mu.Unlock()
+
+	<-w.ready
+
+	s.mu.Lock()
+	s.recordStart(p, time.Since(start))
+	s.mu.Unlock()
+}
+
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.queue.Len() == 0 {
+		s.active--
+		return
+	}
+	// Hand our slot directly to whoever is next.
+	w := heap.Pop(&s.queue).(*waiter)
+	s.statsFor(w.priority).Queued--
+	close(w.ready)
+}
+
+func (s *scheduler) statsFor(p Priority) *PriorityStats {
+	ps, ok := s.byPriority[p]
+	if !ok {
+		ps = &PriorityStats{}
+		s.byPriority[p] = ps
+	}
+	return ps
+}
+
+func (s *scheduler) recordStart(p Priority, wait time.Duration) {
+	ps := s.statsFor(p)
+	ps.Started++
+	ps.TotalWait += wait
+	if wait > ps.MaxWait {
+		ps.MaxWait = wait
+	}
+}
+
+func (s *scheduler) stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := SchedulerStats{
+		MaxSessions:    s.max,
+		ActiveSessions: s.active,
+		ByPriority:     make(map[Priority]PriorityStats),
+	}
+	for p, ps := range s.byPriority {
+		out.ByPriority[p] = *ps
+	}
+	return out
+}
+
+type waiter struct {
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+}
+
+// waitQueue implements heap.Interface.
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority < q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *waitQueue) Push(x any) { *q = append(*q, x.(*waiter)) }
+
+func (q *waitQueue) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return w
+}
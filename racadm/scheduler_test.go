@@ -0,0 +1,116 @@
+package racadm
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSchedulerPriorityOrder(t *testing.T) {
+	s := newScheduler(1)
+	s.acquire(PriorityNormal)
+
+	var (
+		mu  sync.Mutex
+		got []Priority
+		wg  sync.WaitGroup
+	)
+	for i, p := range []Priority{PriorityBulk, PriorityNormal, PriorityCritical, PriorityBulk} {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.acquire(p)
+			mu.Lock()
+			got = append(got, p)
+			mu.Unlock()
+			s.release()
+		}()
+		waitForQueued(t, s, i+1)
+	}
+
+	s.release()
+	wg.Wait()
+
+	want := []Priority{PriorityCritical, PriorityNormal, PriorityBulk, PriorityBulk}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected run order (-want +got)\n%s", diff)
+	}
+
+	stats := s.stats()
+	if stats.ActiveSessions != 0 {
+		t.Errorf("got %d active sessions, want 0", stats.ActiveSessions)
+	}
+	bulk := stats.ByPriority[PriorityBulk]
+	if bulk.Started != 2 || bulk.Queued != 0 {
+		t.Errorf("got %d started, %d queued bulk commands, want 2 and 0", bulk.Started, bulk.Queued)
+	}
+	if bulk.TotalWait == 0 || bulk.MaxWait == 0 {
+		t.Errorf("expected bulk commands to have waited, got %+v", bulk)
+	}
+}
+
+func TestMaxSessions(t *testing.T) {
+	f := newFakeCMC(t)
+	f.setDelay("racadm getsensorinfo", 50*time.Millisecond)
+	c := f.dial(t, WithMaxSessions(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetSensorInfo(); err != nil {
+				t.Errorf("GetSensorInfo: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := f.maxSessions(); n > 2 {
+		t.Errorf("fake CMC saw %d concurrent sessions, want at most 2", n)
+	}
+	stats := c.SchedulerStats()
+	if n := stats.ByPriority[PriorityCritical].Started; n != 8 {
+		t.Errorf("got %d started getsensorinfo commands, want 8", n)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	c := &Client{priorities: map[string]Priority{"getsensorinfo": PriorityCritical}}
+
+	tests := []struct {
+		cmd  string
+		want Priority
+	}{
+		{cmd: "racadm getsensorinfo", want: PriorityCritical},
+		{cmd: "racadm getniccfg -m server-1", want: PriorityNormal},
+		{cmd: "racadm", want: PriorityNormal},
+	}
+	for _, test := range tests {
+		if got := c.priority(test.cmd); got != test.want {
+			t.Errorf("priority(%q) = %v, want %v", test.cmd, got, test.want)
+		}
+	}
+}
+
+// waitForQueued waits until n commands are waiting on the scheduler.
+func waitForQueued(t *testing.T, s *scheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		queued := 0
+		for _, ps := range s.stats().ByPriority {
+			queued += ps.Queued
+		}
+		if queued == n {
+			// Make sure the wait shows up in the stats.
+			time.Sleep(time.Millisecond)
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d commands to be queued", n)
+}
@@ -0,0 +1,285 @@
+package racadm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	defaultShellPrompt  = regexp.MustCompile(`\$ ?$`)
+	defaultShellTimeout = 30 * time.Second
+
+	// How long the shell has to stay quiet after a prompt before we consider
+	// it back in sync.
+	resyncQuietPeriod = 250 * time.Millisecond
+)
+
+var (
+	errShellDesync   = errors.New("shell output is out of sync with commands")
+	errPromptTimeout = errors.New("timed out waiting for prompt")
+)
+
+// shell is a long-lived interactive session on the CMC. Commands are written to
+// stdin one at a time, and the end of each command's output is found by
+// waiting for the prompt to come back.
+type shell struct {
+	sess    *ssh.Session
+	stdin   io.WriteCloser
+	prompt  *regexp.Regexp
+	timeout time.Duration
+
+	// chunks receives raw output from the session. It's closed once reading
+	// fails, after readErr has been set.
+	chunks  chan []byte
+	readErr error
+	done    chan struct{}
+
+	buf bytes.Buffer
+	// Set when a command timed out, meaning its output (and prompt) may still
+	// show up and get mistaken for the next command's.
+	needsResync bool
+}
+
+func openShell(client *ssh.Client, prompt *regexp.Regexp, timeout time.Duration) (*shell, error) {
+	sess, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	// A wide terminal keeps long lines from being wrapped.
+	if err := sess.RequestPty("vt100", 40, 512, ssh.TerminalModes{}); err != nil {
+		sess.Close()
+		return nil, fmt.Errorf("failed to request pty: %w", err)
+	}
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		sess.Close()
+		return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+	}
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		sess.Close()
+		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+	if err := sess.Shell(); err != nil {
+		sess.Close()
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	s := &shell{
+		sess:    sess,
+		stdin:   stdin,
+		prompt:  prompt,
+		timeout: timeout,
+		chunks:  make(chan []byte, 16),
+		done:    make(chan struct{}),
+	}
+	go s.readLoop(stdout)
+
+	// Skip past the login banner.
+	if _, err := s.readUntilPrompt(); err != nil {
+		s.close()
+		return nil, fmt.Errorf("failed to wait for initial prompt: %w", err)
+	}
+	return s, nil
+}
+
+func (s *shell) readLoop(r io.Reader) {
+	defer close(s.chunks)
+	for {
+		b := make([]byte, 4096)
+		n, err := r.Read(b)
+		if n > 0 {
+			select {
+			case s.chunks <- b[:n]:
+			case <-s.done:
+				return
+			}
+		}
+		if err != nil {
+			s.readErr = err
+			return
+		}
+	}
+}
+
+// run writes the command to the shell and returns its output, minus the echoed
+// command and the trailing prompt.
+func (s *shell) run(cmd string) ([]byte, error) {
+	if s.needsResync || s.hasStrayOutput() {
+		if err := s.resync(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := io.WriteString(s.stdin, cmd+"\n"); err != nil {
+		return nil, fmt.Errorf("failed to write command: %w", err)
+	}
+	out, err := s.readUntilPrompt()
+	if errors.Is(err, errPromptTimeout) {
+		s.needsResync = true
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The PTY echoes the command back before its output.
+	echo, rest, _ := bytes.Cut(out, []byte("\n"))
+	if !bytes.HasSuffix(bytes.TrimSpace(echo), []byte(cmd)) {
+		s.needsResync = true
+		return nil, fmt.Errorf("%w: expected echo of %q, got %q", errShellDesync, cmd, echo)
+	}
+	return rest, nil
+}
+
+// resync gets the shell back to a known state, e.g. after a command timed out
+// and its output showed up late. It sends an empty line and discards
+// everything until a prompt is followed by a short quiet period.
+func (s *shell) resync() error {
+	if _, err := io.WriteString(s.stdin, "\n"); err != nil {
+		return fmt.Errorf("failed to write newline: %w", err)
+	}
+
+	deadline := time.NewTimer(s.timeout + resyncQuietPeriod)
+	defer deadline.Stop()
+	for {
+		if _, err := s.readUntilPrompt(); err != nil {
+			return fmt.Errorf("failed to resync shell: %w", err)
+		}
+		select {
+		case b, ok := <-s.chunks:
+			if !ok {
+				return fmt.Errorf("shell closed while resyncing: %w", s.readErr)
+			}
+			s.write(b)
+		case <-time.After(resyncQuietPeriod):
+			s.needsResync = false
+			return nil
+		case <-deadline.C:
+			return fmt.Errorf("failed to resync shell: %w", errPromptTimeout)
+		}
+	}
+}
+
+// hasStrayOutput reports whether the shell has produced any output we didn't
+// ask for, which means we're no longer in step with it.
+func (s *shell) hasStrayOutput() bool {
+	for {
+		select {
+		case b, ok := <-s.chunks:
+			if !ok {
+				return true
+			}
+			s.write(b)
+			continue
+		default:
+		}
+		break
+	}
+	if len(bytes.TrimSpace(s.buf.Bytes())) == 0 {
+		s.buf.Reset()
+		return false
+	}
+	return true
+}
+
+func (s *shell) readUntilPrompt() ([]byte, error) {
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+
+	for {
+		if out, ok := s.cutPrompt(); ok {
+			return out, nil
+		}
+		select {
+		case b, ok := <-s.chunks:
+			if !ok {
+				return nil, fmt.Errorf("shell closed: %w", s.readErr)
+			}
+			s.write(b)
+		case <-timer.C:
+			return nil, errPromptTimeout
+		}
+	}
+}
+
+// cutPrompt checks if the buffered output ends with a prompt. If it does, it
+// returns everything before the prompt and empties the buffer.
+func (s *shell) cutPrompt() ([]byte, bool) {
+	data := s.buf.Bytes()
+	start := bytes.LastIndexByte(data, '\n') + 1
+	if !s.prompt.Match(data[start:]) {
+		return nil, false
+	}
+	out := append([]byte(nil), data[:start]...)
+	s.buf.Reset()
+	return out, true
+}
+
+func (s *shell) write(b []byte) {
+	// PTYs translate newlines to CRLF, which the parsers don't need to see.
+	s.buf.Write(bytes.ReplaceAll(b, []byte("\r"), nil))
+}
+
+func (s *shell) close() error {
+	close(s.done)
+	return s.sess.Close()
+}
+
+// runShellCommand is runCommand for shell session mode. It must be called with
+// c.mu held for reading.
+func (c *Client) runShellCommand(cmd string, fn func(r io.Reader) error) error {
+	c.shellMu.Lock()
+	defer c.shellMu.Unlock()
+
+	out, err := c.shellOutput(cmd)
+	if err != nil {
+		return err
+	}
+	if err := fn(bytes.NewReader(out)); err != nil {
+		return fmt.Errorf("error in parse fn: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) shellOutput(cmd string) ([]byte, error) {
+	if c.shell == nil {
+		s, err := openShell(c.client, c.shellPrompt, c.shellTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open shell: %w", err)
+		}
+		c.shell = s
+	}
+
+	out, err := c.shell.run(cmd)
+	if errors.Is(err, errShellDesync) {
+		// Give it one more shot, run will resync first.
+		out, err = c.shell.run(cmd)
+	}
+	if err != nil && !errors.Is(err, errPromptTimeout) {
+		// We don't know what state the shell is in, start over next time.
+		c.closeShell()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run command in shell: %w", err)
+	}
+	return out, nil
+}
+
+// closeShell closes the shell session, if there is one. Callers must either
+// hold c.shellMu, or hold c.mu for writing.
+func (c *Client) closeShell() {
+	if c.shell == nil {
+		return
+	}
+	if err := c.shell.close(); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("failed to close shell session: %v", err)
+	}
+	c.shell = nil
+}
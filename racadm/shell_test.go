@@ -0,0 +1,116 @@
+package racadm
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestShellSession(t *testing.T) {
+	f := newFakeCMC(t)
+	c := f.dial(t, WithShellSession())
+
+	// Run everything twice to make sure commands don't bleed into each other.
+	for i := 0; i < 2; i++ {
+		sInfo, err := c.GetSensorInfo()
+		if err != nil {
+			t.Fatalf("GetSensorInfo: %v", err)
+		}
+		if len(sInfo.Fans) != 9 {
+			t.Errorf("got %d fans, want 9", len(sInfo.Fans))
+		}
+
+		sysInfo, err := c.GetSysInfo()
+		if err != nil {
+			t.Fatalf("GetSysInfo: %v", err)
+		}
+		if sysInfo.ChassisName != "CMC-ABCDEFG" {
+			t.Errorf("got chassis name %q, want %q", sysInfo.ChassisName, "CMC-ABCDEFG")
+		}
+
+		nicCfg, err := c.GetNICConfig(1)
+		if err != nil {
+			t.Fatalf("GetNICConfig: %v", err)
+		}
+		want, err := ParseGetNICConfig(strings.NewReader(savedOutput(t, "getniccfg", "cmc-6.21")))
+		if err != nil {
+			t.Fatalf("ParseGetNICConfig: %v", err)
+		}
+		if diff := cmp.Diff(want, nicCfg); diff != "" {
+			t.Errorf("unexpected GetNICConfig output (-want +got)\n%s", diff)
+		}
+	}
+
+	if n := f.maxSessions(); n != 1 {
+		t.Errorf("fake CMC saw %d concurrent sessions, want 1", n)
+	}
+}
+
+func TestShellSessionRecoversFromTimeout(t *testing.T) {
+	f := newFakeCMC(t)
+	f.setDelay("racadm getpbinfo", 500*time.Millisecond)
+	c := f.dial(t, WithShellSession(), WithShellTimeout(100*time.Millisecond))
+
+	_, err := c.GetPowerBudgetInfo()
+	if !errors.Is(err, errPromptTimeout) {
+		t.Fatalf("GetPowerBudgetInfo returned %v, want a prompt timeout", err)
+	}
+
+	// Wait for the late output to show up, it should get thrown away instead of
+	// being parsed as the next command's output.
+	time.Sleep(time.Second)
+	f.setDelay("racadm getpbinfo", 0)
+
+	sysInfo, err := c.GetSysInfo()
+	if err != nil {
+		t.Fatalf("GetSysInfo: %v", err)
+	}
+	if sysInfo.ChassisName != "CMC-ABCDEFG" {
+		t.Errorf("got chassis name %q, want %q", sysInfo.ChassisName, "CMC-ABCDEFG")
+	}
+}
+
+func TestShellSessionUnknownCommand(t *testing.T) {
+	f := newFakeCMC(t)
+	c := f.dial(t, WithShellSession())
+
+	err := c.runCommand("racadm bogus", func(r io.Reader) error {
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if got, want := string(out), "ERROR: Invalid subcommand specified.\n"; got != want {
+			t.Errorf("got output %q, want %q", got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runCommand: %v", err)
+	}
+}
+
+func BenchmarkGetSysInfo(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "PerCommandSession"},
+		{name: "ShellSession", opts: []Option{WithShellSession()}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			f := newFakeCMC(b)
+			c := f.dial(b, bm.opts...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.GetSysInfo(); err != nil {
+					b.Fatalf("GetSysInfo: %v", err)
+				}
+			}
+		})
+	}
+}
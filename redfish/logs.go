@@ -0,0 +1,130 @@
+package redfish
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LogService is one of a manager's logs. iDRACs have the SEL, with ID "SEL",
+// and the Lifecycle Controller log, with ID "LC".
+type LogService struct {
+	ID                 string `json:"Id"`
+	Name               string
+	MaxNumberOfRecords int
+	OverWritePolicy    string
+	ServiceEnabled     bool
+	Status             Status
+
+	// entries is the path of the log's entries, which iDRACs don't keep
+	// under the log service.
+	entries string
+}
+
+type logServiceJSON struct {
+	LogService
+	Entries link
+}
+
+// LogEntry is a single entry in a log.
+type LogEntry struct {
+	ID      string `json:"Id"`
+	Created time.Time
+	// Severity is "OK", "Warning" or "Critical".
+	Severity string
+	Message  string
+	// EntryType is e.g. "SEL".
+	EntryType string
+	// SensorType is the type of sensor that logged a SEL entry, e.g.
+	// "Temperature" or "Memory".
+	SensorType   string `json:"-"`
+	SensorNumber int
+}
+
+type logEntryJSON struct {
+	LogEntry
+	// Depending on the firmware, this is either a plain string or a list of
+	// {"Member": "..."}.
+	SensorType interface{}
+}
+
+// LogServices returns the manager's logs. For a blade's iDRAC, the manager ID
+// is "iDRAC.Embedded.1", see System.Managers.
+func (c *Client) LogServices(ctx context.Context, managerID string) ([]*LogService, error) {
+	paths, err := c.members(ctx, "/redfish/v1/Managers/"+managerID+"/LogServices")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log services: %w", err)
+	}
+	var out []*LogService
+	for _, path := range paths {
+		var lj logServiceJSON
+		if err := c.get(ctx, path, &lj); err != nil {
+			return nil, fmt.Errorf("failed to load log service: %w", err)
+		}
+		ls := lj.LogService
+		ls.entries = lj.Entries.ID
+		out = append(out, &ls)
+	}
+	return out, nil
+}
+
+// LogEntries returns every entry in the log, in the order the service lists
+// them, which for iDRACs is newest first.
+func (c *Client) LogEntries(ctx context.Context, ls *LogService) ([]*LogEntry, error) {
+	return c.logEntries(ctx, ls, time.Time{})
+}
+
+// LogEntriesSince returns the entries in the log that were created at or
+// after since, newest first. It relies on the service listing entries newest
+// first, like iDRACs do, so that it can stop paging through a big log once it
+// gets to the old ones.
+//
+// iDRACs only log Created to the second, so more entries can turn up with the
+// same time as the newest one a caller has seen. Those are included, and
+// callers should skip the ones they've already seen by ID.
+func (c *Client) LogEntriesSince(ctx context.Context, ls *LogService, since time.Time) ([]*LogEntry, error) {
+	return c.logEntries(ctx, ls, since)
+}
+
+func (c *Client) logEntries(ctx context.Context, ls *LogService, since time.Time) ([]*LogEntry, error) {
+	if ls.entries == "" {
+		return nil, fmt.Errorf("log service %q has no entries", ls.ID)
+	}
+	var out []*LogEntry
+	path := ls.entries
+	for path != "" {
+		var page struct {
+			Members  []*logEntryJSON
+			NextLink string `json:"Members@odata.nextLink"`
+		}
+		if err := c.get(ctx, path, &page); err != nil {
+			return nil, fmt.Errorf("failed to load %s log entries: %w", ls.ID, err)
+		}
+		for _, ej := range page.Members {
+			e := ej.LogEntry
+			if e.Created.Before(since) {
+				return out, nil
+			}
+			e.SensorType = sensorType(ej.SensorType)
+			out = append(out, &e)
+		}
+		path = page.NextLink
+	}
+	return out, nil
+}
+
+func sensorType(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []interface{}:
+		for _, m := range v {
+			if obj, ok := m.(map[string]interface{}); ok {
+				if s, ok := obj["Member"].(string); ok {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
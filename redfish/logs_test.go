@@ -0,0 +1,117 @@
+package redfish
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/redfish/redfishsim"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestLogServices(t *testing.T) {
+	c, srv := newTestClient(t)
+	ctx := context.Background()
+
+	services, err := c.LogServices(ctx, "iDRAC.Embedded.1")
+	if err != nil {
+		t.Fatalf("LogServices: %v", err)
+	}
+	ok := Status{Health: "OK", State: "Enabled"}
+	want := []*LogService{
+		{ID: "LC", Name: "LifeCycle Controller Log Service", MaxNumberOfRecords: 2147483647, OverWritePolicy: "WrapsWhenFull", ServiceEnabled: true, Status: ok},
+		{ID: "SEL", Name: "SEL Log Service", MaxNumberOfRecords: 1024, OverWritePolicy: "WrapsWhenFull", ServiceEnabled: true, Status: ok},
+	}
+	if diff := cmp.Diff(want, services, cmpopts.IgnoreUnexported(LogService{})); diff != "" {
+		t.Fatalf("unexpected log services (-want +got)\n%s", diff)
+	}
+
+	// The SEL's entries come in two pages.
+	entries, err := c.LogEntries(ctx, services[1])
+	if err != nil {
+		t.Fatalf("LogEntries: %v", err)
+	}
+	cst := time.FixedZone("", -6*60*60)
+	wantEntries := []*LogEntry{
+		{
+			ID:         "3",
+			Created:    time.Date(2024, 3, 4, 8, 55, 31, 0, cst),
+			Severity:   "Critical",
+			Message:    "Multi-bit memory errors detected on a memory device at location(s) DIMM A1.",
+			EntryType:  "SEL",
+			SensorType: "Memory",
+		},
+		{
+			ID:         "2",
+			Created:    time.Date(2024, 3, 2, 14, 22, 10, 0, cst),
+			Severity:   "Warning",
+			Message:    "The system inlet temperature is greater than the upper warning threshold.",
+			EntryType:  "SEL",
+			SensorType: "Temperature",
+		},
+		{
+			ID:         "1",
+			Created:    time.Date(2024, 3, 2, 14, 21, 7, 0, cst),
+			Severity:   "OK",
+			Message:    "Log cleared.",
+			EntryType:  "SEL",
+			SensorType: "Event Logging Disabled",
+		},
+	}
+	if diff := cmp.Diff(wantEntries, entries, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("unexpected SEL entries (-want +got)\n%s", diff)
+	}
+
+	// The LC log's entries aren't recorded.
+	if _, err := c.LogEntries(ctx, services[0]); !IsNotFound(err) {
+		t.Errorf("LogEntries for the LC log returned %v, want a not found error", err)
+	}
+
+	// New entries show up at the front.
+	srv.AddSELEntry(redfishsim.SELEntry{
+		Created:    "2024-03-05T10:00:00-06:00",
+		Severity:   "Warning",
+		Message:    "Correctable memory error rate exceeded for DIMM A1.",
+		SensorType: "Memory",
+	})
+	entries, err = c.LogEntries(ctx, services[1])
+	if err != nil {
+		t.Fatalf("LogEntries after adding an entry: %v", err)
+	}
+	if len(entries) != 4 || entries[0].ID != "4" || entries[0].Severity != "Warning" {
+		t.Errorf("newest entry is %+v of %d, want entry 4 of 4", entries[0], len(entries))
+	}
+
+	// Asking for entries since the third one doesn't need the second page.
+	before := srv.Requests(redfishsim.SELPath)
+	since, err := c.LogEntriesSince(ctx, services[1], time.Date(2024, 3, 4, 8, 55, 31, 0, cst))
+	if err != nil {
+		t.Fatalf("LogEntriesSince: %v", err)
+	}
+	// Entry 3 is included, in case something else was logged in the same
+	// second.
+	if len(since) != 2 || since[0].ID != "4" || since[1].ID != "3" {
+		t.Errorf("got %d entries since entry 3, want entries 4 and 3", len(since))
+	}
+	if n := srv.Requests(redfishsim.SELPath) - before; n != 1 {
+		t.Errorf("loaded the first page of the SEL %d times, want 1", n)
+	}
+}
+
+func TestSensorType(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{"Temperature", "Temperature"},
+		{[]interface{}{map[string]interface{}{"Member": "Memory"}}, "Memory"},
+		{[]interface{}{}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := sensorType(test.in); got != test.want {
+			t.Errorf("sensorType(%v) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
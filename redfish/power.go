@@ -0,0 +1,62 @@
+package redfish
+
+import (
+	"context"
+	"fmt"
+)
+
+// Power is a chassis' power draw, voltage sensors and power supplies. Blades
+// are powered by the chassis, so their iDRACs don't list any power supplies.
+type Power struct {
+	PowerControl  []*PowerControl
+	Voltages      []*Voltage
+	PowerSupplies []*PowerSupply
+}
+
+type PowerControl struct {
+	MemberID string `json:"MemberId"`
+	Name     string
+	// PowerConsumedWatts is the current draw.
+	PowerConsumedWatts  *float64
+	PowerCapacityWatts  *float64
+	PowerAllocatedWatts *float64
+	// PowerMetrics are over the last IntervalInMin minutes.
+	PowerMetrics struct {
+		IntervalInMin        int
+		MinConsumedWatts     *float64
+		MaxConsumedWatts     *float64
+		AverageConsumedWatts *float64
+	}
+}
+
+type Voltage struct {
+	MemberID     string `json:"MemberId"`
+	Name         string
+	SensorNumber int
+	// ReadingVolts is nil if the sensor doesn't have a reading. iDRACs report
+	// their "PG" (power good) sensors as 1 when they're good.
+	ReadingVolts    *float64
+	PhysicalContext string
+	Status          Status
+}
+
+type PowerSupply struct {
+	MemberID             string `json:"MemberId"`
+	Name                 string
+	Model                string
+	SerialNumber         string
+	FirmwareVersion      string
+	PowerCapacityWatts   *float64
+	LastPowerOutputWatts *float64
+	Status               Status
+}
+
+// Power returns the chassis' power readings. For a blade's iDRAC, the chassis
+// ID is "System.Embedded.1", see System.Chassis.
+func (c *Client) Power(ctx context.Context, chassisID string) (*Power, error) {
+	var p Power
+	if err := c.get(ctx, "/redfish/v1/Chassis/"+chassisID+"/Power", &p); err != nil {
+		return nil, fmt.Errorf("failed to load power info: %w", err)
+	}
+	return &p, nil
+}
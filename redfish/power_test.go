@@ -0,0 +1,50 @@
+package redfish
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPower(t *testing.T) {
+	c, _ := newTestClient(t)
+	got, err := c.Power(context.Background(), "System.Embedded.1")
+	if err != nil {
+		t.Fatalf("Power: %v", err)
+	}
+
+	float := func(v float64) *float64 { return &v }
+	pc := &PowerControl{
+		MemberID:            "PowerControl",
+		Name:                "System Power Control",
+		PowerConsumedWatts:  float(126),
+		PowerCapacityWatts:  float(1316),
+		PowerAllocatedWatts: float(1316),
+	}
+	pc.PowerMetrics.IntervalInMin = 1
+	pc.PowerMetrics.MinConsumedWatts = float(120)
+	pc.PowerMetrics.MaxConsumedWatts = float(168)
+	pc.PowerMetrics.AverageConsumedWatts = float(131)
+	volt := func(member, name string, num int) *Voltage {
+		return &Voltage{
+			MemberID:        "iDRAC.Embedded.1#" + member,
+			Name:            name,
+			SensorNumber:    num,
+			ReadingVolts:    float(1),
+			PhysicalContext: "SystemBoard",
+			Status:          Status{Health: "OK", State: "Enabled"},
+		}
+	}
+	want := &Power{
+		PowerControl: []*PowerControl{pc},
+		Voltages: []*Voltage{
+			volt("CPU1VCOREPG", "CPU1 VCORE PG", 32),
+			volt("SystemBoard3.3VPG", "System Board 3.3V PG", 36),
+		},
+		PowerSupplies: []*PowerSupply{},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected power info (-want +got)\n%s", diff)
+	}
+}
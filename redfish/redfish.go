@@ -0,0 +1,368 @@
+// Package redfish is a client for the Redfish API that newer blades' iDRACs
+// (iDRAC7 with 2.x firmware and later) serve, which knows a lot more about a
+// blade than IPMI's sensors do.
+package redfish
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client talks to a single Redfish service, e.g. a blade's iDRAC. It's safe
+// for concurrent use.
+type Client struct {
+	// base is the scheme and host of the service, e.g. "https://10.0.0.2".
+	base string
+	user string
+	pass string
+
+	http           *http.Client
+	sessionAuth    bool
+	timeout        time.Duration
+	tlsConfig      *tls.Config
+	userHTTPClient bool
+
+	mu sync.Mutex
+	// token and session are the X-Auth-Token and URI of our session, if we're
+	// using session auth and have logged in.
+	token   string
+	session string
+}
+
+// Option configures optional behavior of a Client.
+type Option func(*Client)
+
+// WithSessionAuth makes the client log in once and use the session's token
+// for every request, rather than sending the username and password each
+// time. It's faster on iDRACs, which take a while to check a password, but
+// sessions count against the iDRAC's limit, so call Close when you're done.
+func WithSessionAuth() Option {
+	return func(c *Client) {
+		c.sessionAuth = true
+	}
+}
+
+// WithInsecureSkipVerify turns off TLS certificate verification, which is
+// needed for iDRACs that still have their factory self-signed certificates.
+func WithInsecureSkipVerify() Option {
+	return func(c *Client) {
+		c.tlsConfig.InsecureSkipVerify = true
+	}
+}
+
+// WithRootCAs verifies the service's certificate against the given CAs,
+// instead of the system's.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(c *Client) {
+		c.tlsConfig.RootCAs = pool
+	}
+}
+
+// WithTimeout limits how long each request can take, 30 seconds by default.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithHTTPClient makes the client send requests with the given HTTP client,
+// in which case WithInsecureSkipVerify, WithRootCAs and WithTimeout are
+// ignored.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+		c.userHTTPClient = true
+	}
+}
+
+const defaultTimeout = 30 * time.Second
+
+// New returns a client for the Redfish service at addr, which is a host or
+// host:port to connect to over HTTPS, or a URL like "https://host:port". It
+// doesn't connect until the first request.
+func New(addr, user, pass string, opts ...Option) *Client {
+	base := addr
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	c := &Client{
+		base:      strings.TrimSuffix(base, "/"),
+		user:      user,
+		pass:      pass,
+		timeout:   defaultTimeout,
+		tlsConfig: &tls.Config{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if !c.userHTTPClient {
+		c.http = &http.Client{
+			Timeout: c.timeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     c.tlsConfig,
+				TLSHandshakeTimeout: 10 * time.Second,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		}
+	}
+	return c
+}
+
+// Error is returned when the service answers a request with an error status.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Message is the service's explanation of what went wrong, if it gave
+	// one.
+	Message string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a 404 from the service, e.g. because
+// it's an older iDRAC that doesn't have the resource.
+func IsNotFound(err error) bool {
+	var rerr *Error
+	return errors.As(err, &rerr) && rerr.StatusCode == http.StatusNotFound
+}
+
+// errorBody is what Redfish services send back with errors.
+type errorBody struct {
+	Error struct {
+		Message      string
+		ExtendedInfo []struct {
+			Message string
+		} `json:"@Message.ExtendedInfo"`
+	} `json:"error"`
+}
+
+func (b *errorBody) message() string {
+	// The extended info is more specific, e.g. "The authentication
+	// credentials included with this request are missing or invalid." rather
+	// than "A general error has occurred. See ExtendedInfo for more
+	// information."
+	var msgs []string
+	for _, info := range b.Error.ExtendedInfo {
+		if info.Message != "" {
+			msgs = append(msgs, info.Message)
+		}
+	}
+	if len(msgs) > 0 {
+		return strings.Join(msgs, " ")
+	}
+	return b.Error.Message
+}
+
+// get loads the resource at path, which is absolute, e.g.
+// "/redfish/v1/Systems", into v.
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// do sends the request, logging in first if we need to, and returns an *Error
+// if it didn't succeed. The caller closes the response body.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	if !c.sessionAuth {
+		return c.send(ctx, method, path, body, "")
+	}
+
+	token, err := c.sessionToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, method, path, body, token)
+	var rerr *Error
+	if !errors.As(err, &rerr) || rerr.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// Our session expired, or the iDRAC was reset. Log in again and retry,
+	// once.
+	c.mu.Lock()
+	if c.token == token {
+		c.token, c.session = "", ""
+	}
+	c.mu.Unlock()
+	if token, err = c.sessionToken(ctx); err != nil {
+		return nil, err
+	}
+	return c.send(ctx, method, path, body, token)
+}
+
+// send sends a single request, with the session token if there is one and
+// basic auth otherwise.
+func (c *Client) send(ctx context.Context, method, path string, body interface{}, token string) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+		r = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Auth-Token", token)
+	} else {
+		req.SetBasicAuth(c.user, c.pass)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	rerr := &Error{Method: method, Path: path, StatusCode: resp.StatusCode}
+	var eb errorBody
+	// Not every error comes with a body, so we don't complain if it doesn't
+	// decode.
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&eb); err == nil {
+		rerr.Message = eb.message()
+	}
+	return nil, rerr
+}
+
+const sessionsPath = "/redfish/v1/SessionService/Sessions"
+
+// sessionToken returns our session's token, logging in if we don't have one.
+func (c *Client) sessionToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" {
+		return c.token, nil
+	}
+
+	// Logging in is the one request that doesn't need auth.
+	resp, err := c.send(ctx, http.MethodPost, sessionsPath, map[string]string{
+		"UserName": c.user,
+		"Password": c.pass,
+	}, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to log in: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	token := resp.Header.Get("X-Auth-Token")
+	if token == "" {
+		return "", errors.New("failed to log in: no X-Auth-Token in response")
+	}
+	c.token = token
+	c.session = sessionPath(resp.Header.Get("Location"))
+	return c.token, nil
+}
+
+// sessionPath returns the path of the session from the Location header we
+// got when logging in, which some services make absolute.
+func sessionPath(loc string) string {
+	if i := strings.Index(loc, "://"); i >= 0 {
+		rest := loc[i+3:]
+		if j := strings.Index(rest, "/"); j >= 0 {
+			return rest[j:]
+		}
+		return ""
+	}
+	return loc
+}
+
+// Close logs out of our session, if we have one, so it doesn't count against
+// the service's session limit until it times out. The client can still be
+// used afterwards, and will log in again.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	token, session := c.token, c.session
+	c.token, c.session = "", ""
+	c.mu.Unlock()
+	if token == "" || session == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := c.send(ctx, http.MethodDelete, session, nil, token)
+	if err != nil {
+		return fmt.Errorf("failed to log out: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// link is a reference to another resource.
+type link struct {
+	ID string `json:"@odata.id"`
+}
+
+// collection is the first page of any collection of resources.
+type collection struct {
+	Members  []link
+	NextLink string `json:"Members@odata.nextLink"`
+}
+
+// members returns the paths of every member of the collection at path,
+// following the service's paging.
+func (c *Client) members(ctx context.Context, path string) ([]string, error) {
+	var out []string
+	for path != "" {
+		var coll collection
+		if err := c.get(ctx, path, &coll); err != nil {
+			return nil, err
+		}
+		for _, m := range coll.Members {
+			out = append(out, m.ID)
+		}
+		path = coll.NextLink
+	}
+	return out, nil
+}
+
+// Status is the status of a resource, e.g. a sensor.
+type Status struct {
+	// Health is "OK", "Warning" or "Critical", or empty if the service
+	// doesn't know.
+	Health string
+	// State is e.g. "Enabled", "Absent" or "StandbyOffline".
+	State string
+}
+
+// lastSegment returns the last element of a path, which for Redfish
+// resources is their ID.
+func lastSegment(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	return path[strings.LastIndex(path, "/")+1:]
+}
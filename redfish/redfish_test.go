@@ -0,0 +1,147 @@
+package redfish
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcspragu/m1000e-prom/redfish/redfishsim"
+)
+
+const (
+	testUser = "root"
+	testPass = "calvin"
+)
+
+// newTestClient starts a simulated iDRAC, and returns a client for it that
+// trusts its certificate.
+func newTestClient(t testing.TB, opts ...Option) (*Client, *redfishsim.Server) {
+	t.Helper()
+	srv := redfishsim.New(t, testUser, testPass)
+	return New(srv.URL, testUser, testPass, append([]Option{WithRootCAs(srv.CertPool())}, opts...)...), srv
+}
+
+func TestBasicAuth(t *testing.T) {
+	c, srv := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.Systems(ctx); err != nil {
+		t.Fatalf("Systems: %v", err)
+	}
+	if n := srv.Logins(); n != 0 {
+		t.Errorf("basic auth client logged in %d times, want 0", n)
+	}
+
+	_, err := New(srv.URL, testUser, "wrong", WithRootCAs(srv.CertPool())).Systems(ctx)
+	var rerr *Error
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Systems with a bad password returned %v, want an *Error", err)
+	}
+	if rerr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status code = %d, want 401", rerr.StatusCode)
+	}
+	if want := "The authentication credentials included with this request are missing or invalid."; rerr.Message != want {
+		t.Errorf("message = %q, want %q", rerr.Message, want)
+	}
+}
+
+func TestSessionAuth(t *testing.T) {
+	c, srv := newTestClient(t, WithSessionAuth())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Systems(ctx); err != nil {
+			t.Fatalf("Systems: %v", err)
+		}
+	}
+	if n := srv.Logins(); n != 1 {
+		t.Errorf("logged in %d times, want 1", n)
+	}
+
+	// When the session goes away, we log in again.
+	srv.ExpireSessions()
+	if _, err := c.Systems(ctx); err != nil {
+		t.Fatalf("Systems after the session expired: %v", err)
+	}
+	if n := srv.Logins(); n != 2 {
+		t.Errorf("logged in %d times, want 2", n)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if logouts, active := srv.Logouts(), srv.ActiveSessions(); logouts != 1 || active != 0 {
+		t.Errorf("after Close, got %d logouts and %d open sessions, want 1 and 0", logouts, active)
+	}
+	// Closing again is a no-op.
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+
+	bad := New(srv.URL, testUser, "wrong", WithSessionAuth(), WithInsecureSkipVerify())
+	if _, err := bad.Systems(ctx); err == nil || !strings.Contains(err.Error(), "failed to log in") {
+		t.Errorf("Systems with a bad password returned %v, want a login error", err)
+	}
+}
+
+func TestTLSVerification(t *testing.T) {
+	srv := redfishsim.New(t, testUser, testPass)
+	ctx := context.Background()
+
+	// The simulated iDRAC's certificate is self-signed, like a factory
+	// iDRAC's.
+	if _, err := New(srv.URL, testUser, testPass).Systems(ctx); err == nil {
+		t.Error("Systems succeeded against an untrusted certificate")
+	}
+	if _, err := New(srv.URL, testUser, testPass, WithInsecureSkipVerify()).Systems(ctx); err != nil {
+		t.Errorf("Systems with verification off: %v", err)
+	}
+	if _, err := New(srv.URL, testUser, testPass, WithRootCAs(srv.CertPool())).Systems(ctx); err != nil {
+		t.Errorf("Systems with the certificate trusted: %v", err)
+	}
+	if _, err := New(srv.URL, testUser, testPass, WithHTTPClient(srv.Client())).Systems(ctx); err != nil {
+		t.Errorf("Systems with the server's HTTP client: %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	hung := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer srv.Close()
+	defer close(hung)
+
+	c := New(srv.URL, testUser, testPass, WithInsecureSkipVerify(), WithTimeout(50*time.Millisecond))
+	if _, err := c.Systems(context.Background()); err == nil {
+		t.Error("Systems against a hung server succeeded")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	c, _ := newTestClient(t)
+	_, err := c.Thermal(context.Background(), "CMC.Integrated.1")
+	if !IsNotFound(err) {
+		t.Errorf("Thermal for a missing chassis returned %v, want a not found error", err)
+	}
+}
+
+func TestNewAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"10.0.0.2", "https://10.0.0.2"},
+		{"10.0.0.2:8443", "https://10.0.0.2:8443"},
+		{"http://10.0.0.2/", "http://10.0.0.2"},
+	}
+	for _, test := range tests {
+		if got := New(test.addr, "", "").base; got != test.want {
+			t.Errorf("New(%q).base = %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
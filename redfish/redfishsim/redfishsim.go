@@ -0,0 +1,359 @@
+// Package redfishsim is a simulated iDRAC Redfish service, for testing Redfish
+// clients without a blade. It serves responses recorded from an M630's iDRAC8
+// (firmware 2.75), which tests can change, and does basic and session auth
+// the way the iDRAC does.
+package redfishsim
+
+import (
+	"crypto/x509"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//go:embed recorded
+var recorded embed.FS
+
+const (
+	recordedDir  = "recorded/idrac-2.75"
+	sessionsPath = "/redfish/v1/SessionService/Sessions"
+
+	// The resources that the Set* methods change.
+	SystemPath  = "/redfish/v1/Systems/System.Embedded.1"
+	ThermalPath = "/redfish/v1/Chassis/System.Embedded.1/Thermal"
+	PowerPath   = "/redfish/v1/Chassis/System.Embedded.1/Power"
+	SELPath     = "/redfish/v1/Managers/iDRAC.Embedded.1/Logs/Sel"
+)
+
+// Server is a simulated iDRAC listening on a local HTTPS port, with a
+// self-signed certificate.
+type Server struct {
+	// URL is the server's base URL, e.g. "https://127.0.0.1:43615", and Host
+	// and Port are the same thing split up.
+	URL  string
+	Host string
+	Port int
+
+	srv *httptest.Server
+	tb  testing.TB
+
+	mu sync.Mutex
+	// resources are keyed by path, with any query string appended after an
+	// underscore, since embedded files can't have a "?" in their names.
+	resources map[string][]byte
+	failures  map[string]int
+	users     map[string]string
+	sessions  map[string]bool
+	lastID    int
+	logins    int
+	logouts   int
+	requests  map[string]int
+}
+
+// New starts a simulated iDRAC that accepts the given credentials. It's shut
+// down when the test finishes.
+func New(tb testing.TB, user, pass string) *Server {
+	tb.Helper()
+
+	s := &Server{
+		tb:        tb,
+		resources: make(map[string][]byte),
+		failures:  make(map[string]int),
+		users:     map[string]string{user: pass},
+		sessions:  make(map[string]bool),
+		requests:  make(map[string]int),
+	}
+	err := fs.WalkDir(recorded, recordedDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		buf, err := recorded.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		s.resources[strings.TrimSuffix(strings.TrimPrefix(p, recordedDir), ".json")] = buf
+		return nil
+	})
+	if err != nil {
+		tb.Fatalf("failed to load recorded responses: %v", err)
+	}
+
+	s.srv = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	tb.Cleanup(s.srv.Close)
+	s.URL = s.srv.URL
+	host, port, err := net.SplitHostPort(s.srv.Listener.Addr().String())
+	if err != nil {
+		tb.Fatalf("failed to parse listener address: %v", err)
+	}
+	s.Host = host
+	if s.Port, err = strconv.Atoi(port); err != nil {
+		tb.Fatalf("failed to parse listener port: %v", err)
+	}
+	return s
+}
+
+// CertPool returns a pool with the server's certificate in it, for clients
+// that verify it.
+func (s *Server) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.srv.Certificate())
+	return pool
+}
+
+// Certificate returns the server's self-signed certificate.
+func (s *Server) Certificate() *x509.Certificate {
+	return s.srv.Certificate()
+}
+
+// Client returns an HTTP client that trusts the server's certificate.
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+// Set replaces the resource at path with v, marshaled to JSON. A nil v
+// removes it, so it's a 404.
+func (s *Server) Set(path string, v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v == nil {
+		delete(s.resources, path)
+		return
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		s.tb.Fatalf("failed to marshal %s: %v", path, err)
+	}
+	s.resources[path] = buf
+}
+
+// update decodes the resource at path, lets fn change it, and stores it
+// again.
+func (s *Server) update(path string, fn func(res map[string]interface{})) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res map[string]interface{}
+	if err := json.Unmarshal(s.resources[path], &res); err != nil {
+		s.tb.Fatalf("failed to unmarshal %s: %v", path, err)
+	}
+	fn(res)
+	buf, err := json.Marshal(res)
+	if err != nil {
+		s.tb.Fatalf("failed to marshal %s: %v", path, err)
+	}
+	s.resources[path] = buf
+}
+
+// SetPowerState sets the blade's power state, e.g. "On" or "Off".
+func (s *Server) SetPowerState(state string) {
+	s.update(SystemPath, func(res map[string]interface{}) {
+		res["PowerState"] = state
+	})
+}
+
+// SetTemperature sets the reading of the named temperature sensor, e.g.
+// "System Board Inlet Temp".
+func (s *Server) SetTemperature(name string, celsius float64) {
+	s.update(ThermalPath, func(res map[string]interface{}) {
+		temps, _ := res["Temperatures"].([]interface{})
+		for _, t := range temps {
+			if t, ok := t.(map[string]interface{}); ok && t["Name"] == name {
+				t["ReadingCelsius"] = celsius
+				return
+			}
+		}
+		s.tb.Fatalf("no temperature sensor named %q", name)
+	})
+}
+
+// SetPowerConsumed sets the blade's current power draw.
+func (s *Server) SetPowerConsumed(watts float64) {
+	s.update(PowerPath, func(res map[string]interface{}) {
+		pcs, _ := res["PowerControl"].([]interface{})
+		for _, pc := range pcs {
+			if pc, ok := pc.(map[string]interface{}); ok {
+				pc["PowerConsumedWatts"] = watts
+			}
+		}
+	})
+}
+
+// SELEntry is an entry to add to the blade's SEL with AddSELEntry.
+type SELEntry struct {
+	// Created is when the entry was logged, in RFC 3339 format.
+	Created    string
+	Severity   string
+	Message    string
+	SensorType string
+}
+
+// AddSELEntry adds an entry to the front of the first page of the blade's
+// SEL, since the iDRAC lists the newest entries first.
+func (s *Server) AddSELEntry(e SELEntry) {
+	s.update(SELPath, func(res map[string]interface{}) {
+		members, _ := res["Members"].([]interface{})
+		count, _ := res["Members@odata.count"].(float64)
+		id := strconv.Itoa(int(count) + 1)
+		entry := map[string]interface{}{
+			"@odata.id":  SELPath + "/" + id,
+			"Id":         id,
+			"Name":       "Log Entry " + id,
+			"Created":    e.Created,
+			"EntryType":  "SEL",
+			"Severity":   e.Severity,
+			"Message":    e.Message,
+			"SensorType": []interface{}{map[string]interface{}{"Member": e.SensorType}},
+		}
+		res["Members"] = append([]interface{}{entry}, members...)
+		res["Members@odata.count"] = count + 1
+	})
+}
+
+// Fail makes requests for the resource at path fail with the given HTTP
+// status code, or succeed again if it's zero.
+func (s *Server) Fail(path string, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if code == 0 {
+		delete(s.failures, path)
+		return
+	}
+	s.failures[path] = code
+}
+
+// ExpireSessions logs every session out, like an iDRAC reset does.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]bool)
+}
+
+// Logins returns how many sessions have been created.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// Logouts returns how many sessions have been deleted by their clients.
+func (s *Server) Logouts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logouts
+}
+
+// ActiveSessions returns how many sessions are currently open.
+func (s *Server) ActiveSessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Requests returns how many authorized GETs have been made for the resource
+// at path.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == sessionsPath && r.Method == http.MethodPost {
+		s.login(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "The authentication credentials included with this request are missing or invalid.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasPrefix(r.URL.Path, sessionsPath+"/") && r.Method == http.MethodDelete {
+		delete(s.sessions, r.Header.Get("X-Auth-Token"))
+		s.logouts++
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	s.requests[r.URL.Path]++
+	if code, ok := s.failures[r.URL.Path]; ok {
+		writeError(w, code, "A general error has occurred.")
+		return
+	}
+	key := r.URL.Path
+	if r.URL.RawQuery != "" {
+		key += "_" + r.URL.RawQuery
+	}
+	buf, ok := s.resources[path.Clean(key)]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource at the URI %s was not found.", r.URL.Path))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json;odata.metadata=minimal;charset=utf-8")
+	w.Write(buf)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tok := r.Header.Get("X-Auth-Token"); tok != "" {
+		return s.sessions[tok]
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok && s.users[user] == pass
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserName string
+		Password string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "The request body submitted was malformed JSON.")
+		return
+	}
+
+	s.mu.Lock()
+	pass, ok := s.users[req.UserName]
+	if !ok || pass != req.Password {
+		s.mu.Unlock()
+		writeError(w, http.StatusUnauthorized, "The authentication credentials included with this request are missing or invalid.")
+		return
+	}
+	s.lastID++
+	s.logins++
+	id := s.lastID
+	tok := fmt.Sprintf("%032x", id)
+	s.sessions[tok] = true
+	s.mu.Unlock()
+
+	loc := fmt.Sprintf("%s/%d", sessionsPath, id)
+	w.Header().Set("X-Auth-Token", tok)
+	w.Header().Set("Location", loc)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"@odata.id":%q,"Id":"%d","Name":"User Session","UserName":%q}`, loc, id, req.UserName)
+}
+
+// writeError writes an error the way the iDRAC does.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if msg == "" {
+		return
+	}
+	fmt.Fprintf(w, `{"error":{"@Message.ExtendedInfo":[{"Message":%q,"MessageId":"Base.1.0.GeneralError","Severity":"Critical"}],"code":"Base.1.0.GeneralError","message":"A general error has occurred. See ExtendedInfo for more information"}}`, msg)
+}
@@ -0,0 +1,76 @@
+package redfish
+
+import (
+	"context"
+	"fmt"
+)
+
+// System is a computer system, which for a blade's iDRAC is the blade itself,
+// "System.Embedded.1".
+type System struct {
+	ID           string `json:"Id"`
+	Name         string
+	HostName     string
+	Manufacturer string
+	Model        string
+	// SKU is the Dell service tag.
+	SKU          string
+	SerialNumber string
+	BiosVersion  string
+	// PowerState is "On", "Off", "PoweringOn" or "PoweringOff".
+	PowerState string
+	Status     Status
+
+	ProcessorSummary struct {
+		Count int
+		Model string
+	}
+	MemorySummary struct {
+		TotalSystemMemoryGiB float64
+	}
+
+	// Chassis and Managers are the IDs of the system's chassis, which is where
+	// its Thermal and Power live, and of what manages it, which is where the
+	// SEL lives.
+	Chassis  []string `json:"-"`
+	Managers []string `json:"-"`
+}
+
+type systemJSON struct {
+	System
+	Links struct {
+		Chassis   []link
+		ManagedBy []link
+	}
+}
+
+// Systems returns every system the service knows about, which for a blade's
+// iDRAC is just the one.
+func (c *Client) Systems(ctx context.Context) ([]*System, error) {
+	paths, err := c.members(ctx, "/redfish/v1/Systems")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list systems: %w", err)
+	}
+	var out []*System
+	for _, path := range paths {
+		var sj systemJSON
+		if err := c.get(ctx, path, &sj); err != nil {
+			return nil, fmt.Errorf("failed to load system: %w", err)
+		}
+		sys := sj.System
+		for _, l := range sj.Links.Chassis {
+			sys.Chassis = append(sys.Chassis, lastSegment(l.ID))
+		}
+		for _, l := range sj.Links.ManagedBy {
+			sys.Managers = append(sys.Managers, lastSegment(l.ID))
+		}
+		out = append(out, &sys)
+	}
+	return out, nil
+}
+
+// On reports whether the system is powered on, or in the middle of powering
+// off.
+func (s *System) On() bool {
+	return s.PowerState == "On" || s.PowerState == "PoweringOff"
+}
@@ -0,0 +1,40 @@
+package redfish
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSystems(t *testing.T) {
+	c, _ := newTestClient(t)
+	got, err := c.Systems(context.Background())
+	if err != nil {
+		t.Fatalf("Systems: %v", err)
+	}
+
+	sys := &System{
+		ID:           "System.Embedded.1",
+		Name:         "System",
+		HostName:     "db-1",
+		Manufacturer: "Dell Inc.",
+		Model:        "PowerEdge M630",
+		SKU:          "ABC1234",
+		SerialNumber: "CN7016358E0123",
+		BiosVersion:  "2.11.0",
+		PowerState:   "On",
+		Status:       Status{Health: "OK", State: "Enabled"},
+		Chassis:      []string{"System.Embedded.1"},
+		Managers:     []string{"iDRAC.Embedded.1"},
+	}
+	sys.ProcessorSummary.Count = 2
+	sys.ProcessorSummary.Model = "Intel(R) Xeon(R) CPU E5-2660 v3 @ 2.60GHz"
+	sys.MemorySummary.TotalSystemMemoryGiB = 128
+	if diff := cmp.Diff([]*System{sys}, got); diff != "" {
+		t.Errorf("unexpected systems (-want +got)\n%s", diff)
+	}
+	if !got[0].On() {
+		t.Error("system isn't on")
+	}
+}
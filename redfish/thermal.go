@@ -0,0 +1,56 @@
+package redfish
+
+import (
+	"context"
+	"fmt"
+)
+
+// Thermal is a chassis' temperature sensors and fans. Blades don't have fans
+// of their own, so their iDRACs only report temperatures.
+type Thermal struct {
+	Temperatures []*Temperature
+	Fans         []*Fan
+}
+
+type Temperature struct {
+	// MemberID is unique within the chassis, e.g.
+	// "iDRAC.Embedded.1#SystemBoardInletTemp".
+	MemberID     string `json:"MemberId"`
+	Name         string
+	SensorNumber int
+	// ReadingCelsius is nil if the sensor doesn't have a reading, e.g.
+	// because the blade is off.
+	ReadingCelsius *float64
+	// PhysicalContext is where the sensor is, e.g. "Intake" or "CPU".
+	PhysicalContext           string
+	UpperThresholdNonCritical *float64
+	UpperThresholdCritical    *float64
+	Status                    Status
+}
+
+type Fan struct {
+	MemberID string `json:"MemberId"`
+	// Name is the fan's name, which older services put in FanName.
+	Name         string
+	FanName      string
+	SensorNumber int
+	Reading      *float64
+	// ReadingUnits is "RPM" or "Percent".
+	ReadingUnits string
+	Status       Status
+}
+
+// Thermal returns the chassis' thermal readings. For a blade's iDRAC, the
+// chassis ID is "System.Embedded.1", see System.Chassis.
+func (c *Client) Thermal(ctx context.Context, chassisID string) (*Thermal, error) {
+	var th Thermal
+	if err := c.get(ctx, "/redfish/v1/Chassis/"+chassisID+"/Thermal", &th); err != nil {
+		return nil, fmt.Errorf("failed to load thermal info: %w", err)
+	}
+	for _, f := range th.Fans {
+		if f.Name == "" {
+			f.Name = f.FanName
+		}
+	}
+	return &th, nil
+}
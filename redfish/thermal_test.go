@@ -0,0 +1,40 @@
+package redfish
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestThermal(t *testing.T) {
+	c, _ := newTestClient(t)
+	got, err := c.Thermal(context.Background(), "System.Embedded.1")
+	if err != nil {
+		t.Fatalf("Thermal: %v", err)
+	}
+
+	temp := func(member, name string, num int, reading, warn, crit float64, ctx string) *Temperature {
+		return &Temperature{
+			MemberID:                  "iDRAC.Embedded.1#" + member,
+			Name:                      name,
+			SensorNumber:              num,
+			ReadingCelsius:            &reading,
+			PhysicalContext:           ctx,
+			UpperThresholdNonCritical: &warn,
+			UpperThresholdCritical:    &crit,
+			Status:                    Status{Health: "OK", State: "Enabled"},
+		}
+	}
+	want := &Thermal{
+		Temperatures: []*Temperature{
+			temp("SystemBoardInletTemp", "System Board Inlet Temp", 4, 23, 42, 47, "Intake"),
+			temp("CPU1Temp", "CPU1 Temp", 14, 47, 88, 93, "CPU"),
+			temp("CPU2Temp", "CPU2 Temp", 15, 44, 88, 93, "CPU"),
+		},
+		Fans: []*Fan{},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected thermal info (-want +got)\n%s", diff)
+	}
+}